@@ -0,0 +1,140 @@
+package autostart
+
+import (
+	"fmt"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/rpc/client"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// readEntries reads the autostart entries of a YAML file, holding either one entry or a list.
+func readEntries(file string) ([]models.AutoStart, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var entries []models.AutoStart
+	if err = yaml.UnmarshalStrict(data, &entries); err == nil {
+		return entries, nil
+	}
+	var entry models.AutoStart
+	if err = yaml.UnmarshalStrict(data, &entry); err != nil {
+		return nil, err
+	}
+	return []models.AutoStart{entry}, nil
+}
+
+func Prepare(app *kingpin.Application) map[string]func() error {
+	autostart := app.Command("autostart", "autostart operation")
+	rpcAddress := autostart.Flag("rpc-address", "the urcf serve rpc address").
+		Default("localhost:8228").TCP()
+
+	list := autostart.Command("list", "list the autostart entries")
+
+	add := autostart.Command("add", "add the autostart entries of a YAML file")
+	addFile := add.Arg("file", "YAML file").Required().ExistingFile()
+
+	remove := autostart.Command("remove", "remove an autostart entry")
+	removeID := remove.Arg("id", "entry id").Required().Int64()
+
+	enable := autostart.Command("enable", "enable an autostart entry")
+	enableID := enable.Arg("id", "entry id").Required().Int64()
+
+	disable := autostart.Command("disable", "disable an autostart entry")
+	disableID := disable.Arg("id", "entry id").Required().Int64()
+
+	enableAll := autostart.Command("enable-all", "enable every autostart entry")
+
+	disableAll := autostart.Command("disable-all", "disable every autostart entry")
+
+	startAll := autostart.Command("start-all", "start every enabled autostart entry")
+
+	connect := func() (*client.AutoStartRPC, error) {
+		return client.NewAutoStartRPC((*rpcAddress).String())
+	}
+
+	return map[string]func() error{
+		list.FullCommand(): func() error {
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			entries, err := rpc.List()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tPRIORITY\tPARALLEL\tENABLE\tAFTER\tREQUIRES\tCMD")
+			for _, as := range entries {
+				fmt.Fprintf(w, "%d\t%s\t%d\t%t\t%t\t%s\t%s\t%s\n", as.ID, as.Name, as.Priority, as.Parallel,
+					as.Enable, strings.Join(as.After, ","), strings.Join(as.Requires, ","), as.Cmd)
+			}
+			return w.Flush()
+		},
+		add.FullCommand(): func() error {
+			entries, err := readEntries(*addFile)
+			if err != nil {
+				return err
+			}
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			for i := range entries {
+				id, err := rpc.Add(&entries[i])
+				if err != nil {
+					return fmt.Errorf("add %s: %v", entries[i].Name, err)
+				}
+				fmt.Printf("Added %s as %d\n", entries[i].Name, id)
+			}
+			return nil
+		},
+		remove.FullCommand(): func() error {
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			return rpc.Remove(*removeID)
+		},
+		enable.FullCommand(): func() error {
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			return rpc.Enable(*enableID)
+		},
+		disable.FullCommand(): func() error {
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			return rpc.Disable(*disableID)
+		},
+		enableAll.FullCommand(): func() error {
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			return rpc.EnableAll()
+		},
+		disableAll.FullCommand(): func() error {
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			return rpc.DisableAll()
+		},
+		startAll.FullCommand(): func() error {
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			return rpc.StartAll()
+		},
+	}
+}
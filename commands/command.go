@@ -3,8 +3,12 @@ package commands
 import (
 	"fmt"
 	"github.com/zhsyourai/URCF-engine/commands/account"
+	"github.com/zhsyourai/URCF-engine/commands/autostart"
 	"github.com/zhsyourai/URCF-engine/commands/kill"
+	"github.com/zhsyourai/URCF-engine/commands/logs"
+	"github.com/zhsyourai/URCF-engine/commands/processes"
 	"github.com/zhsyourai/URCF-engine/commands/serve"
+	"github.com/zhsyourai/URCF-engine/commands/upgrade"
 	"github.com/zhsyourai/URCF-engine/commands/version"
 	"gopkg.in/alecthomas/kingpin.v2"
 	"os"
@@ -28,6 +32,10 @@ func init() {
 	register(serve.Prepare(app))
 	register(kill.Prepare(app))
 	register(account.Prepare(app))
+	register(processes.Prepare(app))
+	register(autostart.Prepare(app))
+	register(upgrade.Prepare(app))
+	register(logs.Prepare(app))
 }
 
 func Run() int {
@@ -0,0 +1,229 @@
+package logs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/rpc/client"
+	"github.com/zhsyourai/URCF-engine/services/log/export"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const timeFormat = "2006-01-02 15:04:05"
+
+var (
+	errPatternExport = errors.New("--pattern only applies to showing logs, export with --contains or --match")
+	errBadField      = errors.New("--field must be key=value")
+)
+
+func printLog(log *models.Log) {
+	fields := make([]string, 0, len(log.Fields))
+	for k, v := range log.Fields {
+		fields = append(fields, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(fields)
+	line := fmt.Sprintf("%s %-7s %s: %s", log.CreateTime.Local().Format(timeFormat),
+		strings.ToUpper(log.Level.String()), log.Name, log.Message)
+	if len(fields) > 0 {
+		line += " " + strings.Join(fields, " ")
+	}
+	fmt.Println(line)
+}
+
+// tail prints the logs of a tail until it is dropped or interrupted.
+func tail(rpc *client.LogRPC, filter models.LogTailFilter, lines int, follow bool) error {
+	reply, err := rpc.Tail(filter, lines)
+	if err != nil {
+		return err
+	}
+	for i := range reply.History {
+		printLog(&reply.History[i])
+	}
+	if !follow {
+		return rpc.CloseTail(reply.Session)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			logs, err := rpc.Next(reply.Session)
+			if err != nil {
+				failed <- err
+				return
+			}
+			for i := range logs {
+				printLog(&logs[i])
+			}
+		}
+	}()
+	select {
+	case err = <-failed:
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	case <-interrupt:
+		return rpc.CloseTail(reply.Session)
+	}
+}
+
+func Prepare(app *kingpin.Application) map[string]func() error {
+	logs := app.Command("logs", "logs operation")
+	rpcAddress := logs.Flag("rpc-address", "the urcf serve rpc address").
+		Default("localhost:8228").TCP()
+
+	show := logs.Command("show", "show the last logs, then the new ones with -f").Default()
+	showFollow := logs.Flag("follow", "keep showing the new logs").Short('f').Bool()
+	showName := logs.Flag("name", "only the logs of this logger").String()
+	showLevel := logs.Flag("level", "only the logs at least this severe").String()
+	showPattern := logs.Flag("pattern", "only the logs with a message matching this regular expression").
+		String()
+	showLines := logs.Flag("lines", "how many of the last logs to show").Short('n').Default("10").Int()
+
+	exportCmd := logs.Command("export", "export the logs matching --name, --level and the filters to a file")
+	exportNames := exportCmd.Arg("names", "bundle the logs of each name in a tar.gz with a manifest").
+		Strings()
+	exportFormat := exportCmd.Flag("format", "jsonl, csv or text").Default(export.JSONL).
+		Enum(export.JSONL, export.CSV, export.Text)
+	exportOutput := exportCmd.Flag("output", "the file to write, - for the standard output").Short('o').
+		Default("-").String()
+	exportFrom := exportCmd.Flag("from", "only the logs from this time, in RFC 3339").String()
+	exportTo := exportCmd.Flag("to", "only the logs before this time, in RFC 3339").String()
+	exportTraceID := exportCmd.Flag("trace-id", "only the logs of this trace").String()
+	exportContains := exportCmd.Flag("contains", "only the logs with a message containing this").String()
+	exportMatch := exportCmd.Flag("match", "only the logs with a message matching this full-text query").
+		String()
+	exportFields := exportCmd.Flag("field", "only the logs with this field, as key=value").Strings()
+
+	levels := logs.Command("levels", "show the default level and the level of each logger")
+
+	level := logs.Command("level", "set the level of a logger, or the default level without a name")
+	levelLevel := level.Arg("level", "panic, fatal, error, warning, info or debug").Required().String()
+	levelName := level.Arg("name", "logger name, a process or plugin name").String()
+
+	reset := logs.Command("reset-level", "make a logger follow the default level again")
+	resetName := reset.Arg("name", "logger name").Required().String()
+
+	connect := func() (*client.LogRPC, error) {
+		return client.NewLogRPC((*rpcAddress).String())
+	}
+
+	return map[string]func() error{
+		show.FullCommand(): func() error {
+			filter := models.LogTailFilter{Name: *showName, Pattern: *showPattern}
+			if *showLevel != "" {
+				level, err := models.ParseLevel(*showLevel)
+				if err != nil {
+					return err
+				}
+				filter.MinLevel = &level
+			}
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			return tail(rpc, filter, *showLines, *showFollow)
+		},
+		exportCmd.FullCommand(): func() error {
+			if *showPattern != "" {
+				return errPatternExport
+			}
+			query := models.LogQuery{
+				Name:     *showName,
+				TraceID:  *exportTraceID,
+				Contains: *exportContains,
+				Match:    *exportMatch,
+				Fields:   make(map[string]string, len(*exportFields)),
+			}
+			var err error
+			if *showLevel != "" {
+				level, err := models.ParseLevel(*showLevel)
+				if err != nil {
+					return err
+				}
+				query.MinLevel = &level
+			}
+			if *exportFrom != "" {
+				query.From, err = time.Parse(time.RFC3339, *exportFrom)
+				if err != nil {
+					return err
+				}
+			}
+			if *exportTo != "" {
+				query.To, err = time.Parse(time.RFC3339, *exportTo)
+				if err != nil {
+					return err
+				}
+			}
+			for _, field := range *exportFields {
+				kv := strings.SplitN(field, "=", 2)
+				if len(kv) != 2 {
+					return errBadField
+				}
+				query.Fields[kv[0]] = kv[1]
+			}
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			out := os.Stdout
+			if *exportOutput != "-" {
+				out, err = os.Create(*exportOutput)
+				if err != nil {
+					return err
+				}
+				defer out.Close()
+			}
+			source := export.Paged(rpc.SearchAfter)
+			if len(*exportNames) > 0 {
+				return export.Bundle(out, *exportFormat, query, *exportNames, source)
+			}
+			_, err = export.Export(out, *exportFormat, query, source)
+			return err
+		},
+		levels.FullCommand(): func() error {
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			result, err := rpc.Levels()
+			if err != nil {
+				return err
+			}
+			names := make([]string, 0, len(result.Levels))
+			for name := range result.Levels {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tLEVEL")
+			fmt.Fprintf(w, "%s\t%s\n", "(default)", result.Default)
+			for _, name := range names {
+				fmt.Fprintf(w, "%s\t%s\n", name, result.Levels[name])
+			}
+			return w.Flush()
+		},
+		level.FullCommand(): func() error {
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			return rpc.SetLevel(*levelName, *levelLevel)
+		},
+		reset.FullCommand(): func() error {
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			return rpc.ResetLevel(*resetName)
+		},
+	}
+}
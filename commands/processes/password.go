@@ -0,0 +1,55 @@
+package processes
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// passwordEnv holds the operator password, so that it doesn't show in the arguments.
+const passwordEnv = "URCF_PASSWORD"
+
+// operatorPassword returns the password from passwordEnv, or else prompts for it on the
+// terminal. Stdin is left alone, it is what attach sends to the process.
+func operatorPassword(user string) (string, error) {
+	if password, ok := os.LookupEnv(passwordEnv); ok {
+		return password, nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal to ask the password on, set %s: %v", passwordEnv, err)
+	}
+	defer tty.Close()
+
+	var state syscall.Termios
+	err = ioctlTermios(tty, syscall.TCGETS, &state)
+	if err != nil {
+		return "", err
+	}
+	noEcho := state
+	noEcho.Lflag &^= syscall.ECHO
+	err = ioctlTermios(tty, syscall.TCSETS, &noEcho)
+	if err != nil {
+		return "", err
+	}
+	defer ioctlTermios(tty, syscall.TCSETS, &state)
+
+	fmt.Fprintf(tty, "Password for %s: ", user)
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	fmt.Fprintln(tty)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func ioctlTermios(tty *os.File, request uintptr, state *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, tty.Fd(), request, uintptr(unsafe.Pointer(state)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package processes
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/zhsyourai/URCF-engine/rpc/client"
+	"github.com/zhsyourai/URCF-engine/services/processes/types"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// attachKeepAlive is how often attach renews its session, well within its lease.
+const attachKeepAlive = 10 * time.Second
+
+// printGroups prints the aggregated status of groups as a table.
+func printGroups(groups []*types.ProcessGroup) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tMEMBERS\tSTATUS\tREADY\tRESTARTS\tCPU%\tRSS")
+	for _, group := range groups {
+		statuses := make([]string, 0, len(group.Statuses))
+		for status, count := range group.Statuses {
+			statuses = append(statuses, fmt.Sprintf("%s=%d", status, count))
+		}
+		sort.Strings(statuses)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d/%d\t%d\t%.1f\t%d\n", group.Name, strings.Join(group.Members, ","),
+			strings.Join(statuses, ","), group.Ready, len(group.Members), group.Restarts, group.Usage.CPUPercent,
+			group.Usage.RSS)
+	}
+	return w.Flush()
+}
+
+func Prepare(app *kingpin.Application) map[string]func() error {
+	processes := app.Command("processes", "processes operation")
+	rpcAddress := processes.Flag("rpc-address", "the urcf serve rpc address").
+		Default("localhost:8228").TCP()
+
+	attach := processes.Command("attach", "send the standard input to a running process")
+	attachName := attach.Arg("name", "process name").Required().String()
+	attachMode := attach.Flag("mode", "line or raw").Default("line").Enum("line", "raw")
+	attachUser := attach.Flag("user", "operator account, recorded in the audit log, its password is "+
+		"read from "+passwordEnv+" or asked on the terminal").Default(os.Getenv("USER")).String()
+
+	group := processes.Command("group", "process group operation")
+	groupList := group.Command("list", "show the status of every process group")
+	groupStatus := group.Command("status", "show the status of a process group")
+	groupStatusName := groupStatus.Arg("group", "group name").Required().String()
+	groupStart := group.Command("start", "start the processes of a group")
+	groupStartName := groupStart.Arg("group", "group name").Required().String()
+	groupStop := group.Command("stop", "stop the processes of a group")
+	groupStopName := groupStop.Arg("group", "group name").Required().String()
+	groupRestart := group.Command("restart", "restart the processes of a group")
+	groupRestartName := groupRestart.Arg("group", "group name").Required().String()
+	groupUser := group.Flag("user", "operator account, recorded as the stop requester, its password is "+
+		"read from "+passwordEnv+" or asked on the terminal").Default(os.Getenv("USER")).String()
+
+	connect := func() (*client.ProcessesRPC, error) {
+		return client.NewProcessesRPC((*rpcAddress).String())
+	}
+
+	return map[string]func() error{
+		groupList.FullCommand(): func() error {
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			groups, err := rpc.ListGroups()
+			if err != nil {
+				return err
+			}
+			return printGroups(groups)
+		},
+		groupStatus.FullCommand(): func() error {
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			result, err := rpc.FindGroup(*groupStatusName)
+			if err != nil {
+				return err
+			}
+			return printGroups([]*types.ProcessGroup{result})
+		},
+		groupStart.FullCommand(): func() error {
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			return rpc.StartGroup(*groupStartName)
+		},
+		groupStop.FullCommand(): func() error {
+			password, err := operatorPassword(*groupUser)
+			if err != nil {
+				return err
+			}
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			results, err := rpc.StopGroup(*groupStopName, *groupUser, password)
+			for name, result := range results {
+				fmt.Printf("%s: %s\n", name, result)
+			}
+			return err
+		},
+		groupRestart.FullCommand(): func() error {
+			password, err := operatorPassword(*groupUser)
+			if err != nil {
+				return err
+			}
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			return rpc.RestartGroup(*groupRestartName, *groupUser, password)
+		},
+		attach.FullCommand(): func() error {
+			password, err := operatorPassword(*attachUser)
+			if err != nil {
+				return err
+			}
+			rpc, err := connect()
+			if err != nil {
+				return err
+			}
+			session, err := rpc.Attach(*attachName, *attachUser, password, *attachMode)
+			if err != nil {
+				return err
+			}
+			defer rpc.Detach(session)
+
+			// Stdin may stay quiet for long, so the session is kept alive apart from the writes.
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				ticker := time.NewTicker(attachKeepAlive)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-done:
+						return
+					case <-ticker.C:
+						rpc.KeepAlive(session)
+					}
+				}
+			}()
+
+			if *attachMode == "line" {
+				scanner := bufio.NewScanner(os.Stdin)
+				for scanner.Scan() {
+					_, err = rpc.Write(session, scanner.Bytes())
+					if err != nil {
+						return err
+					}
+				}
+				return scanner.Err()
+			}
+			buf := make([]byte, 4096)
+			for {
+				n, err := os.Stdin.Read(buf)
+				if n > 0 {
+					_, werr := rpc.Write(session, buf[:n])
+					if werr != nil {
+						return werr
+					}
+				}
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+			}
+		},
+	}
+}
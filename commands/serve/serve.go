@@ -14,6 +14,8 @@ import (
 	"github.com/zhsyourai/URCF-engine/services/plugin"
 	"github.com/zhsyourai/URCF-engine/services/processes"
 	"github.com/zhsyourai/URCF-engine/services/processes/autostart"
+	"github.com/zhsyourai/URCF-engine/services/processes/manifest"
+	"github.com/zhsyourai/URCF-engine/services/processes/scheduler"
 	"github.com/zhsyourai/URCF-engine/services/processes/watchdog"
 	"gopkg.in/alecthomas/kingpin.v2"
 	"os"
@@ -36,6 +38,10 @@ func Prepare(app *kingpin.Application) map[string]func() error {
 			gConfServ := global_configuration.GetGlobalConfig()
 			gConfServ.Initialize(*configFile)
 			defer gConfServ.UnInitialize(*configFile)
+			if daemon.IsUpgrade() {
+				// Started by an upgrading daemon, which already went through the daemon setup.
+				return run(*startAsDaemon)
+			}
 			if *startAsDaemon {
 				ctx := daemon.GetCtx()
 				defer ctx.Release()
@@ -68,21 +74,47 @@ func Prepare(app *kingpin.Application) map[string]func() error {
 }
 
 func run(isDaemon bool) error {
+	sigKill := make(chan os.Signal, 1)
+	signal.Notify(sigKill, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	sigUpgrade := make(chan os.Signal, 1)
+	signal.Notify(sigUpgrade, syscall.SIGUSR2)
+
 	err := start()
 	if err != nil {
 		log.Fatal(err)
 		return err
 	}
-	if isDaemon {
+	if isDaemon || daemon.IsUpgrade() {
 		sendSignal(os.Getppid(), syscall.SIGUSR1)
 		log.Info("Server daemon started")
 	}
+	if isDaemon && daemon.IsUpgrade() {
+		pidFile, err := daemon.TakeOverPidFile()
+		if err != nil {
+			log.Errorf("pid file error: %v", err)
+		} else {
+			defer pidFile.Remove()
+		}
+	}
 
-	sigKill := make(chan os.Signal, 1)
-	signal.Notify(sigKill, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-	<-sigKill
-	log.Info("Received signal to stop...")
-	err = stop()
+	handOver := false
+wait:
+	for {
+		select {
+		case <-sigKill:
+			log.Info("Received signal to stop...")
+			break wait
+		case <-sigUpgrade:
+			log.Info("Received signal to upgrade...")
+			if err := upgrade(); err != nil {
+				log.Errorf("upgrade error: %v", err)
+				continue
+			}
+			handOver = true
+			break wait
+		}
+	}
+	err = stop(handOver)
 	if err != nil {
 		log.Fatal(err)
 		return err
@@ -90,6 +122,42 @@ func run(isDaemon bool) error {
 	return nil
 }
 
+// upgrade hands the listeners and the process pipes over to a new instance of the binary,
+// and returns once it is ready. The old instance stops watching the processes and reading
+// their output first, so that the new one is the only one to restart them and gets every
+// line. Until the old instance stopped its servers, both accept requests and run the
+// scheduled jobs, the manifests and the probes.
+func upgrade() error {
+	files, err := daemon.ListenerFiles()
+	if err != nil {
+		return err
+	}
+	processesServ := processes.GetInstance()
+	processFiles, err := processesServ.HandOver()
+	if err != nil {
+		processesServ.Resume()
+		return err
+	}
+	// The handed over files are duplicates.
+	handoff := make(map[string]*os.File, len(files)+len(processFiles))
+	for name, f := range files {
+		handoff[name] = f
+	}
+	for name, f := range processFiles {
+		handoff[name] = f
+	}
+	defer func() {
+		for _, f := range handoff {
+			f.Close()
+		}
+	}()
+	err = daemon.Upgrade(handoff)
+	if err != nil {
+		processesServ.Resume()
+	}
+	return err
+}
+
 func start() (err error) {
 	confServ := configuration.GetInstance()
 	confServ.Initialize()
@@ -107,6 +175,17 @@ func start() (err error) {
 	processesServ.Initialize()
 	pluginServ := plugin.GetInstance()
 	pluginServ.Initialize()
+	if err := autostartServ.StartAll(); err != nil {
+		log.Errorf("autostart error: %v", err)
+	}
+	manifestServ := manifest.GetInstance()
+	if err := manifestServ.Initialize(); err != nil {
+		log.Errorf("manifest error: %v", err)
+	}
+	schedulerServ := scheduler.GetInstance()
+	if err := schedulerServ.Initialize(); err != nil {
+		log.Errorf("scheduler error: %v", err)
+	}
 	go func() {
 		err = rpc.StartRPCServer()
 	}()
@@ -116,15 +195,25 @@ func start() (err error) {
 	return
 }
 
-func stop() (err error) {
+// stop stops the services. When handing over to a new instance, the processes and the
+// plugins are left running for it to take over.
+func stop(handOver bool) (err error) {
 	err = rpc.StopRPCServer()
 	err = http.StopHTTPServer()
-	pluginServ := plugin.GetInstance()
-	pluginServ.UnInitialize()
+	if !handOver {
+		pluginServ := plugin.GetInstance()
+		pluginServ.UnInitialize()
+	}
+	schedulerServ := scheduler.GetInstance()
+	schedulerServ.UnInitialize()
+	manifestServ := manifest.GetInstance()
+	manifestServ.UnInitialize()
+	if !handOver {
+		autostartServ := autostart.GetInstance()
+		autostartServ.UnInitialize()
+	}
 	processesServ := processes.GetInstance()
 	processesServ.UnInitialize()
-	autostartServ := autostart.GetInstance()
-	autostartServ.UnInitialize()
 	watchdogServ := watchdog.GetInstance()
 	watchdogServ.UnInitialize()
 	netfilterServ := netfilter.GetInstance()
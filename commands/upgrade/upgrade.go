@@ -0,0 +1,56 @@
+package upgrade
+
+import (
+	"github.com/kataras/iris/core/errors"
+	godaemon "github.com/sevlyar/go-daemon"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhsyourai/URCF-engine/daemon"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"os"
+	"syscall"
+	"time"
+)
+
+func Prepare(app *kingpin.Application) map[string]func() error {
+	upgrade := app.Command("upgrade", "Replace daemon URCF by the installed binary, keeping its listeners and processes.")
+	configFile := upgrade.Flag("config-file", "Config file location").String()
+	timeout := upgrade.Flag("timeout", "Seconds to wait for the new daemon").Default("90").Int64()
+
+	return map[string]func() error{
+		upgrade.FullCommand(): func() error {
+			if *configFile == "" {
+				folderPath := os.Getenv("HOME") + "/.URCF"
+				*configFile = folderPath + "/config.yml"
+				os.MkdirAll(folderPath, 0755)
+			}
+			gConfServ := global_configuration.GetGlobalConfig()
+			gConfServ.Initialize(*configFile)
+			defer gConfServ.UnInitialize(*configFile)
+			ctx := daemon.GetCtx()
+			ok, p, err := daemon.IsDaemonRunning(ctx)
+			if !ok {
+				if err == nil {
+					return errors.New("Search server instance error")
+				}
+				log.Info("Server Instance is not running.")
+				return err
+			}
+			log.Info("Upgrading server daemon ...")
+			if err := p.Signal(syscall.SIGUSR2); err != nil {
+				return err
+			}
+			// The new daemon writes the pid file once the old one exited.
+			deadline := time.Now().Add(time.Second * time.Duration(*timeout))
+			for time.Now().Before(deadline) {
+				<-time.After(500 * time.Millisecond)
+				pid, err := godaemon.ReadPidFile(ctx.PidFileName)
+				if err == nil && pid != p.Pid && syscall.Kill(pid, 0) == nil {
+					log.Infof("Server daemon upgraded (pid %d)", pid)
+					return nil
+				}
+			}
+			return errors.New("Server daemon upgrade did not complete, detail see log file")
+		},
+	}
+}
@@ -0,0 +1,200 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sevlyar/go-daemon"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+)
+
+// handoffEnv holds the path of the handoff file of an instance started by an upgrade.
+const handoffEnv = "URCF_UPGRADE_HANDOFF"
+
+// UpgradeTimeout is how long the new instance has to tell it is ready.
+const UpgradeTimeout = 60 * time.Second
+
+var ErrUpgradeFailed = errors.New("new instance exited before being ready")
+var ErrUpgradeTimeout = errors.New("new instance was not ready in time")
+
+// executable is resolved at startup, once the binary is replaced /proc/self/exe points to the
+// deleted one.
+var executable, _ = os.Executable()
+
+var (
+	inheritOnce sync.Once
+	inherited   map[string]*os.File
+	listenLock  sync.Mutex
+	listeners   = make(map[string]net.Listener)
+)
+
+// IsUpgrade reports whether this instance was started by an upgrading one.
+func IsUpgrade() bool {
+	return os.Getenv(handoffEnv) != ""
+}
+
+func loadHandoff() {
+	inherited = make(map[string]*os.File)
+	file := os.Getenv(handoffEnv)
+	if file == "" {
+		return
+	}
+	defer os.Remove(file)
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Errorf("upgrade handoff read error: %v", err)
+		return
+	}
+	var fds map[string]int
+	err = json.Unmarshal(content, &fds)
+	if err != nil {
+		log.Errorf("upgrade handoff read error: %v", err)
+		return
+	}
+	for name, fd := range fds {
+		syscall.CloseOnExec(fd)
+		inherited[name] = os.NewFile(uintptr(fd), name)
+	}
+}
+
+// Inherited returns the file the previous instance handed over under name, or nil. A file
+// is only returned once.
+func Inherited(name string) *os.File {
+	inheritOnce.Do(loadHandoff)
+	listenLock.Lock()
+	defer listenLock.Unlock()
+	f := inherited[name]
+	delete(inherited, name)
+	return f
+}
+
+// Listen listens on address, or takes over the listener of the previous instance with the
+// same name. The listener is handed over to the next instance on upgrade.
+func Listen(name string, network string, address string) (l net.Listener, err error) {
+	if f := Inherited("listener/" + name); f != nil {
+		l, err = net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("listener %s inherited at %s", name, l.Addr())
+	} else {
+		l, err = net.Listen(network, address)
+		if err != nil {
+			return nil, err
+		}
+	}
+	listenLock.Lock()
+	listeners[name] = l
+	listenLock.Unlock()
+	return l, nil
+}
+
+// ListenerFiles returns the files of the listeners opened by Listen, for Upgrade.
+func ListenerFiles() (map[string]*os.File, error) {
+	listenLock.Lock()
+	defer listenLock.Unlock()
+	files := make(map[string]*os.File, len(listeners))
+	for name, l := range listeners {
+		filer, ok := l.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
+			continue
+		}
+		f, err := filer.File()
+		if err != nil {
+			return nil, err
+		}
+		files["listener/"+name] = f
+	}
+	return files, nil
+}
+
+// Upgrade starts a new instance of the binary with the same arguments, handing it files, and
+// waits for it to tell it is ready by SIGUSR1. The caller then exits without stopping what it
+// handed over. The new instance is killed if it is not ready in time.
+//
+// Both instances run from the start of the new one until the caller exits: whatever the
+// caller keeps running meanwhile, such as serving the listeners, runs twice. The caller stops
+// reading the handed over files before, or they are read by both.
+func Upgrade(files map[string]*os.File) error {
+	// The instance inherits stdio, the handed over files follow.
+	procFiles := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+	fds := make(map[string]int, len(files))
+	for name, f := range files {
+		fds[name] = len(procFiles)
+		procFiles = append(procFiles, f)
+	}
+	content, err := json.Marshal(fds)
+	if err != nil {
+		return err
+	}
+	workPath := global_configuration.GetGlobalConfig().Get().Sys.WorkPath
+	handoff := path.Join(workPath, "upgrade.json")
+	err = ioutil.WriteFile(handoff, content, 0600)
+	if err != nil {
+		return err
+	}
+
+	// The instance is a daemon already, it must not go through go-daemon again.
+	env := []string{handoffEnv + "=" + handoff}
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, daemon.MARK_NAME+"=") && !strings.HasPrefix(e, handoffEnv+"=") {
+			env = append(env, e)
+		}
+	}
+
+	ready := make(chan os.Signal, 1)
+	signal.Notify(ready, syscall.SIGUSR1)
+	defer signal.Stop(ready)
+
+	wd, _ := os.Getwd()
+	proc, err := os.StartProcess(executable, os.Args, &os.ProcAttr{
+		Dir:   wd,
+		Env:   env,
+		Files: procFiles,
+	})
+	if err != nil {
+		os.Remove(handoff)
+		return err
+	}
+	exited := make(chan struct{})
+	go func() {
+		proc.Wait()
+		close(exited)
+	}()
+
+	select {
+	case <-ready:
+		log.Infof("new instance (pid %d) is ready", proc.Pid)
+		return nil
+	case <-exited:
+		os.Remove(handoff)
+		return ErrUpgradeFailed
+	case <-time.After(UpgradeTimeout):
+		proc.Kill()
+		os.Remove(handoff)
+		return ErrUpgradeTimeout
+	}
+}
+
+// TakeOverPidFile waits for the instance that started this one by an upgrade to exit, then
+// writes the pid file in its place.
+func TakeOverPidFile() (*daemon.LockFile, error) {
+	parent := os.Getppid()
+	for os.Getppid() == parent {
+		<-time.After(100 * time.Millisecond)
+	}
+	return daemon.CreatePidFile(GetCtx().PidFileName, GetCtx().PidFilePerm)
+}
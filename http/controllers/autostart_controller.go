@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zhsyourai/URCF-engine/http/controllers/shard"
+	"github.com/zhsyourai/URCF-engine/http/gin-jwt"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/processes/autostart"
+	"net/http"
+	"strconv"
+)
+
+func NewAutoStartController(middleware *gin_jwt.JwtMiddleware) *AutoStartController {
+	return &AutoStartController{
+		service:    autostart.GetInstance(),
+		middleware: middleware,
+	}
+}
+
+// AutoStartController is our /autostart controller.
+type AutoStartController struct {
+	service    autostart.Service
+	middleware *gin_jwt.JwtMiddleware
+}
+
+func (c *AutoStartController) Handler(root *gin.RouterGroup) {
+	root.Use(c.middleware.Handler)
+	root.GET("/list", c.ListHandler)
+	root.POST("", c.AddHandler)
+	root.POST("/start-all", c.StartAllHandler)
+	root.POST("/enable-all", c.EnableAllHandler)
+	root.POST("/disable-all", c.DisableAllHandler)
+	root.POST("/by-id/:id/enable", c.EnableHandler)
+	root.POST("/by-id/:id/disable", c.DisableHandler)
+	root.DELETE("/by-id/:id", c.RemoveHandler)
+}
+
+func autoStartErrorStatus(err error) int {
+	switch err.(type) {
+	case autostart.CycleError:
+		return http.StatusConflict
+	}
+	switch err {
+	case autostart.ErrAutoStartNotExist:
+		return http.StatusNotFound
+	case autostart.ErrNameAndCmdCannotBeEmpty:
+		return http.StatusBadRequest
+	case autostart.ErrAutoStartExist:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (c *AutoStartController) ListHandler(ctx *gin.Context) {
+	entries, err := c.service.List()
+	if err != nil {
+		ctx.AbortWithError(autoStartErrorStatus(err), err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &shard.AutoStartsWithCount{
+		TotalCount: int64(len(entries)),
+		Items:      entries,
+	})
+}
+
+func (c *AutoStartController) AddHandler(ctx *gin.Context) {
+	as := &models.AutoStart{}
+	if err := ctx.BindJSON(as); err != nil {
+		return
+	}
+	if as.Name == "" || as.Cmd == "" {
+		ctx.AbortWithError(http.StatusBadRequest, ErrNameAndCmdCannotBeEmpty)
+		return
+	}
+
+	id, err := c.service.Add(*as)
+	if err != nil {
+		ctx.AbortWithError(autoStartErrorStatus(err), err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &shard.AutoStartID{
+		ID: id,
+	})
+}
+
+func (c *AutoStartController) StartAllHandler(ctx *gin.Context) {
+	err := c.service.StartAll()
+	if err != nil {
+		ctx.AbortWithError(autoStartErrorStatus(err), err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+func (c *AutoStartController) EnableAllHandler(ctx *gin.Context) {
+	err := c.service.EnableAll()
+	if err != nil {
+		ctx.AbortWithError(autoStartErrorStatus(err), err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+func (c *AutoStartController) DisableAllHandler(ctx *gin.Context) {
+	err := c.service.DisableAll()
+	if err != nil {
+		ctx.AbortWithError(autoStartErrorStatus(err), err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+func (c *AutoStartController) EnableHandler(ctx *gin.Context) {
+	c.doAction(ctx, c.service.Enable)
+}
+
+func (c *AutoStartController) DisableHandler(ctx *gin.Context) {
+	c.doAction(ctx, c.service.Disable)
+}
+
+func (c *AutoStartController) RemoveHandler(ctx *gin.Context) {
+	c.doAction(ctx, c.service.Remove)
+}
+
+func (c *AutoStartController) doAction(ctx *gin.Context, action func(id int64) error) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	err = action(id)
+	if err != nil {
+		ctx.AbortWithError(autoStartErrorStatus(err), err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
@@ -4,8 +4,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/kataras/iris/core/errors"
 	"github.com/zhsyourai/URCF-engine/http/controllers/shard"
+	"github.com/zhsyourai/URCF-engine/models"
 	"github.com/zhsyourai/URCF-engine/services/configuration"
+	"io"
 	"net/http"
+	"time"
 )
 
 var (
@@ -28,6 +31,8 @@ func (c *ConfigurationController) Handler(root *gin.RouterGroup) {
 	root.GET("/", c.GetConfigurationHandler)
 	root.PUT("/", c.UpdateConfigurationHandler)
 	root.DELETE("/", c.DeleteConfigurationHandler)
+	root.GET("/watch", c.WatchConfigurationHandler)
+	root.GET("/watch/poll", c.PollConfigurationHandler)
 }
 
 func (c *ConfigurationController) GetConfigurationHandler(ctx *gin.Context) {
@@ -79,3 +84,108 @@ func (c *ConfigurationController) DeleteConfigurationHandler(ctx *gin.Context) {
 	c.service.Delete(keyStr)
 	ctx.Status(http.StatusOK)
 }
+
+const (
+	defaultPollWait = 30
+	maxPollWait     = 120
+)
+
+func (c *ConfigurationController) watch(ctx *gin.Context) (*configuration.Watcher, *shard.ConfigWatchRequest) {
+	var request shard.ConfigWatchRequest
+	if ctx.BindQuery(&request) != nil {
+		return nil, nil
+	}
+	watcher, err := c.service.Watch(request.Prefix, request.Revision)
+	if err == configuration.ErrRevisionCompacted {
+		ctx.AbortWithError(http.StatusGone, err)
+		return nil, nil
+	} else if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return nil, nil
+	}
+	return watcher, &request
+}
+
+// WatchConfigurationHandler streams the changes of the keys starting with prefix as
+// Server-Sent Events, starting with the ones after revision. A client that doesn't keep up
+// gets a dropped event and the stream ends, it may watch again from the last revision it got.
+func (c *ConfigurationController) WatchConfigurationHandler(ctx *gin.Context) {
+	watcher, _ := c.watch(ctx)
+	if watcher == nil {
+		return
+	}
+	defer watcher.Close()
+
+	longLived(ctx)
+	clientGone := ctx.Writer.CloseNotify()
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-watcher.Events():
+			if !ok {
+				if err := watcher.Err(); err != nil {
+					ctx.SSEvent("dropped", err.Error())
+				}
+				return false
+			}
+			ctx.SSEvent("change", event)
+			return true
+		case <-clientGone:
+			return false
+		}
+	})
+}
+
+// PollConfigurationHandler returns the changes of the keys starting with prefix after
+// revision, waiting up to wait seconds for one if there are none yet.
+func (c *ConfigurationController) PollConfigurationHandler(ctx *gin.Context) {
+	watcher, request := c.watch(ctx)
+	if watcher == nil {
+		return
+	}
+	defer watcher.Close()
+
+	longLived(ctx)
+	wait := request.Wait
+	if wait <= 0 {
+		wait = defaultPollWait
+	} else if wait > maxPollWait {
+		wait = maxPollWait
+	}
+	response := &shard.ConfigEvents{
+		Revision: c.service.Revision(),
+		Events:   []models.ConfigEvent{},
+	}
+	if request.Revision > response.Revision {
+		response.Revision = request.Revision
+	}
+	clientGone := ctx.Writer.CloseNotify()
+	timeout := time.After(time.Duration(wait) * time.Second)
+	events := watcher.Events()
+	for events != nil {
+		var event models.ConfigEvent
+		var ok bool
+		if len(response.Events) == 0 {
+			select {
+			case event, ok = <-events:
+			case <-timeout:
+				events = nil
+				continue
+			case <-clientGone:
+				return
+			}
+		} else {
+			select {
+			case event, ok = <-events:
+			default:
+				events = nil
+				continue
+			}
+		}
+		if !ok {
+			break
+		}
+		response.Events = append(response.Events, event)
+		response.Revision = event.Revision
+	}
+	ctx.JSON(http.StatusOK, response)
+}
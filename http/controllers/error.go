@@ -3,5 +3,8 @@ package controllers
 import "errors"
 
 var (
-	ErrMissingAuthInfo = errors.New("missing auth info")
+	ErrMissingAuthInfo         = errors.New("missing auth info")
+	ErrNameAndCmdCannotBeEmpty = errors.New("name and cmd can't be empty")
+	ErrBadLogField             = errors.New("log field must be key=value")
+	ErrLevelCannotBeEmpty      = errors.New("level can't be empty without a name")
 )
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zhsyourai/URCF-engine/http/controllers/shard"
+	"github.com/zhsyourai/URCF-engine/http/gin-jwt"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/processes/scheduler"
+	"net/http"
+	"strconv"
+)
+
+func NewJobsController(middleware *gin_jwt.JwtMiddleware) *JobsController {
+	return &JobsController{
+		service:    scheduler.GetInstance(),
+		middleware: middleware,
+	}
+}
+
+// JobsController is our /jobs controller.
+type JobsController struct {
+	service    scheduler.Service
+	middleware *gin_jwt.JwtMiddleware
+}
+
+func (c *JobsController) Handler(root *gin.RouterGroup) {
+	root.Use(c.middleware.Handler)
+	root.GET("/list", c.ListHandler)
+	root.POST("", c.AddHandler)
+	root.GET("/by-id/:id/runs", c.ListRunsHandler)
+	root.POST("/by-id/:id/enable", c.EnableHandler)
+	root.POST("/by-id/:id/disable", c.DisableHandler)
+	root.POST("/by-id/:id/trigger", c.TriggerHandler)
+	root.DELETE("/by-id/:id", c.RemoveHandler)
+}
+
+func jobErrorStatus(err error) int {
+	switch err {
+	case scheduler.ErrJobNotExist:
+		return http.StatusNotFound
+	case scheduler.ErrJobExist:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (c *JobsController) ListHandler(ctx *gin.Context) {
+	jobs, err := c.service.List()
+	if err != nil {
+		ctx.AbortWithError(jobErrorStatus(err), err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &shard.JobsWithCount{
+		TotalCount: int64(len(jobs)),
+		Items:      jobs,
+	})
+}
+
+func (c *JobsController) AddHandler(ctx *gin.Context) {
+	job := &models.Job{}
+	if err := ctx.BindJSON(job); err != nil {
+		return
+	}
+	if job.Name == "" || job.Cmd == "" {
+		ctx.AbortWithError(http.StatusBadRequest, ErrNameAndCmdCannotBeEmpty)
+		return
+	}
+
+	id, err := c.service.Add(*job)
+	if err != nil {
+		status := jobErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			// Schedule and overlap errors come before anything is stored.
+			status = http.StatusBadRequest
+		}
+		ctx.AbortWithError(status, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &shard.JobID{
+		ID: id,
+	})
+}
+
+func (c *JobsController) ListRunsHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	var paging shard.Paging
+	if ctx.BindQuery(&paging) != nil {
+		return
+	}
+
+	total, runs, err := c.service.ListRuns(id, paging.Page, paging.Size, paging.Sort, paging.Order)
+	if err != nil {
+		ctx.AbortWithError(jobErrorStatus(err), err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &shard.ProcessRunsWithCount{
+		TotalCount: total,
+		Items:      runs,
+	})
+}
+
+func (c *JobsController) EnableHandler(ctx *gin.Context) {
+	c.doAction(ctx, c.service.Enable)
+}
+
+func (c *JobsController) DisableHandler(ctx *gin.Context) {
+	c.doAction(ctx, c.service.Disable)
+}
+
+func (c *JobsController) TriggerHandler(ctx *gin.Context) {
+	c.doAction(ctx, c.service.Trigger)
+}
+
+func (c *JobsController) RemoveHandler(ctx *gin.Context) {
+	c.doAction(ctx, c.service.Remove)
+}
+
+func (c *JobsController) doAction(ctx *gin.Context, action func(id int64) error) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	err = action(id)
+	if err != nil {
+		ctx.AbortWithError(jobErrorStatus(err), err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
@@ -4,9 +4,16 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/zhsyourai/URCF-engine/http/controllers/shard"
 	"github.com/zhsyourai/URCF-engine/http/gin-jwt"
+	"github.com/zhsyourai/URCF-engine/models"
 	"github.com/zhsyourai/URCF-engine/services/log"
+	"github.com/zhsyourai/URCF-engine/services/log/export"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"regexp/syntax"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func NewLogController(middleware *gin_jwt.JwtMiddleware) *LogController {
@@ -24,6 +31,13 @@ type LogController struct {
 
 func (c *LogController) Handler(root *gin.RouterGroup) {
 	root.GET("/list", c.ListLogHandler)
+	root.GET("/search", c.SearchLogHandler)
+	root.GET("/export", c.ExportLogHandler)
+	root.GET("/tail", c.TailLogHandler)
+	root.POST("/compact", c.middleware.Handler, c.CompactHandler)
+	root.GET("/stats", c.StatsHandler)
+	root.GET("/levels", c.GetLevelsHandler)
+	root.PUT("/levels", c.middleware.Handler, c.SetLevelHandler)
 	root.DELETE("/*id", c.CleanLogHandler)
 }
 
@@ -45,6 +59,173 @@ func (c *LogController) ListLogHandler(ctx *gin.Context) {
 	})
 }
 
+func (c *LogController) SearchLogHandler(ctx *gin.Context) {
+	var paging shard.Paging
+	if ctx.BindQuery(&paging) != nil {
+		return
+	}
+	query, ok := bindLogQuery(ctx)
+	if !ok {
+		return
+	}
+
+	total, logs, err := c.service.Search(query, paging.Page, paging.Size, paging.Sort, paging.Order)
+	if err == log.ErrFullTextUnsupported {
+		ctx.AbortWithError(http.StatusNotImplemented, err)
+		return
+	} else if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &shard.LogsWithCount{
+		TotalCount: total,
+		Items:      logs,
+	})
+}
+
+// bindLogQuery binds the log filters of the query string, aborting the request if they are
+// not valid.
+func bindLogQuery(ctx *gin.Context) (query models.LogQuery, ok bool) {
+	var request shard.LogSearchRequest
+	if ctx.BindQuery(&request) != nil {
+		return
+	}
+	query = models.LogQuery{
+		Name:     request.Name,
+		From:     request.From,
+		To:       request.To,
+		TraceID:  request.TraceID,
+		Contains: request.Contains,
+		Match:    request.Match,
+		Fields:   make(map[string]string, len(request.Fields)),
+	}
+	if request.Level != "" {
+		level, err := models.ParseLevel(request.Level)
+		if err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		query.MinLevel = &level
+	}
+	for _, field := range request.Fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			ctx.AbortWithError(http.StatusBadRequest, ErrBadLogField)
+			return
+		}
+		query.Fields[kv[0]] = kv[1]
+	}
+	return query, true
+}
+
+// ExportLogHandler streams the logs the filters match as a file, or as a tar.gz with a file
+// for each name given.
+func (c *LogController) ExportLogHandler(ctx *gin.Context) {
+	var request shard.LogExportRequest
+	if ctx.BindQuery(&request) != nil {
+		return
+	}
+	query, ok := bindLogQuery(ctx)
+	if !ok {
+		return
+	}
+	if request.Format == "" {
+		request.Format = export.JSONL
+	}
+	if _, err := export.NewWriter(ioutil.Discard, request.Format); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	// A query that can't be run fails before anything is sent.
+	if _, err := c.service.SearchAfter(query, 0, 1); err == log.ErrFullTextUnsupported {
+		ctx.AbortWithError(http.StatusNotImplemented, err)
+		return
+	} else if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	name := "logs-" + time.Now().UTC().Format("20060102T150405Z")
+	var err error
+	if len(request.Names) > 0 {
+		ctx.Header("Content-Type", "application/gzip")
+		ctx.Header("Content-Disposition", `attachment; filename="`+name+`.tar.gz"`)
+		err = c.service.ExportBundle(ctx.Writer, request.Format, query, request.Names)
+	} else {
+		contentType := "application/x-ndjson"
+		if request.Format == export.CSV {
+			contentType = "text/csv"
+		} else if request.Format == export.Text {
+			contentType = "text/plain; charset=utf-8"
+		}
+		ctx.Header("Content-Type", contentType)
+		ctx.Header("Content-Disposition",
+			`attachment; filename="`+name+"."+export.Extension(request.Format)+`"`)
+		_, err = c.service.Export(ctx.Writer, request.Format, query)
+	}
+	if err != nil {
+		// The status is sent already, the client sees a truncated file.
+		ctx.Error(err)
+	}
+}
+
+// TailLogHandler streams the logs inserted from now on as Server-Sent Events, starting with
+// the last ones. A client that doesn't keep up gets a dropped event and the stream ends.
+func (c *LogController) TailLogHandler(ctx *gin.Context) {
+	var request shard.LogTailRequest
+	if ctx.BindQuery(&request) != nil {
+		return
+	}
+	filter := models.LogTailFilter{
+		Name:    request.Name,
+		Pattern: request.Pattern,
+	}
+	if request.Level != "" {
+		level, err := models.ParseLevel(request.Level)
+		if err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		filter.MinLevel = &level
+	}
+	tail, err := c.service.Tail(filter, request.Lines)
+	if _, ok := err.(*syntax.Error); ok {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	defer tail.Close()
+
+	longLived(ctx)
+	clientGone := ctx.Writer.CloseNotify()
+	history := tail.History
+	ctx.Stream(func(w io.Writer) bool {
+		if history != nil {
+			for _, log := range history {
+				ctx.SSEvent("log", log)
+			}
+			history = nil
+			return true
+		}
+		select {
+		case log, ok := <-tail.Logs():
+			if !ok {
+				if err := tail.Err(); err != nil {
+					ctx.SSEvent("dropped", err.Error())
+				}
+				return false
+			}
+			ctx.SSEvent("log", log)
+			return true
+		case <-clientGone:
+			return false
+		}
+	})
+}
+
 func (c *LogController) CleanLogHandler(ctx *gin.Context) {
 	idStr := ctx.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 0)
@@ -55,3 +236,60 @@ func (c *LogController) CleanLogHandler(ctx *gin.Context) {
 	c.service.Clean(id)
 	ctx.Status(http.StatusOK)
 }
+
+func (c *LogController) CompactHandler(ctx *gin.Context) {
+	result, err := c.service.Compact()
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &result)
+}
+
+func (c *LogController) GetLevelsHandler(ctx *gin.Context) {
+	defaultLevel, levels := c.service.Levels()
+	result := &shard.LogLevels{
+		Default: models.Level(defaultLevel).String(),
+		Levels:  make(map[string]string, len(levels)),
+	}
+	for name, level := range levels {
+		result.Levels[name] = models.Level(level).String()
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+func (c *LogController) SetLevelHandler(ctx *gin.Context) {
+	var request shard.LogLevelRequest
+	if ctx.BindJSON(&request) != nil {
+		return
+	}
+	var err error
+	if request.Level == "" {
+		if request.Name == "" {
+			ctx.AbortWithError(http.StatusBadRequest, ErrLevelCannotBeEmpty)
+			return
+		}
+		err = c.service.ResetLevel(request.Name)
+	} else {
+		level, e := models.ParseLevel(request.Level)
+		if e != nil {
+			ctx.AbortWithError(http.StatusBadRequest, e)
+			return
+		}
+		if request.Name == "" {
+			err = c.service.ChangeLevel(log.Level(level))
+		} else {
+			err = c.service.SetLevel(request.Name, log.Level(level))
+		}
+	}
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+func (c *LogController) StatsHandler(ctx *gin.Context) {
+	stats := c.service.Stats()
+	ctx.JSON(http.StatusOK, &stats)
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zhsyourai/URCF-engine/http/gin-jwt"
+	"github.com/zhsyourai/URCF-engine/services/processes/autostart"
+	"github.com/zhsyourai/URCF-engine/services/processes/manifest"
+	"net/http"
+)
+
+func NewManifestController(middleware *gin_jwt.JwtMiddleware) *ManifestController {
+	return &ManifestController{
+		service:    manifest.GetInstance(),
+		middleware: middleware,
+	}
+}
+
+// ManifestController is our /manifests controller.
+type ManifestController struct {
+	service    manifest.Service
+	middleware *gin_jwt.JwtMiddleware
+}
+
+func (c *ManifestController) Handler(root *gin.RouterGroup) {
+	root.Use(c.middleware.Handler)
+	root.GET("/diff", c.DiffHandler)
+	root.POST("/reconcile", c.ReconcileHandler)
+}
+
+func manifestErrorStatus(err error) int {
+	switch err.(type) {
+	case autostart.CycleError:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// DiffHandler returns what reconciling would change, as a dry run.
+func (c *ManifestController) DiffHandler(ctx *gin.Context) {
+	changes, err := c.service.Diff()
+	if err != nil {
+		ctx.AbortWithError(manifestErrorStatus(err), err)
+		return
+	}
+	ctx.JSON(http.StatusOK, changes)
+}
+
+// ReconcileHandler applies what Diff returns. When some changes failed, the changes are still
+// returned, with their error, but with an error status.
+func (c *ManifestController) ReconcileHandler(ctx *gin.Context) {
+	changes, err := c.service.Reconcile()
+	if err != nil {
+		ctx.AbortWithError(manifestErrorStatus(err), err)
+		return
+	}
+	if manifest.Failed(changes) {
+		ctx.JSON(http.StatusInternalServerError, changes)
+		return
+	}
+	ctx.JSON(http.StatusOK, changes)
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zhsyourai/URCF-engine/http/controllers/shard"
+	"github.com/zhsyourai/URCF-engine/http/gin-jwt"
+	"github.com/zhsyourai/URCF-engine/services/processes"
+	"net/http"
+)
+
+func NewProcessGroupsController(middleware *gin_jwt.JwtMiddleware) *ProcessGroupsController {
+	return &ProcessGroupsController{
+		service:    processes.GetInstance(),
+		middleware: middleware,
+	}
+}
+
+// ProcessGroupsController is our /process-groups controller.
+type ProcessGroupsController struct {
+	service    processes.Service
+	middleware *gin_jwt.JwtMiddleware
+}
+
+func (c *ProcessGroupsController) Handler(root *gin.RouterGroup) {
+	root.Use(c.middleware.Handler)
+	root.GET("/list", c.ListHandler)
+	root.GET("/by-name/:group", c.GetHandler)
+	root.POST("/by-name/:group/start", c.StartHandler)
+	root.POST("/by-name/:group/stop", c.StopHandler)
+	root.POST("/by-name/:group/restart", c.RestartHandler)
+}
+
+func processGroupsErrorStatus(err error) int {
+	switch err.(type) {
+	case processes.GroupError:
+		return http.StatusMultiStatus
+	}
+	if err == processes.GroupNotExist {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// abortWithGroupError reports the members a group operation failed on.
+func abortWithGroupError(ctx *gin.Context, group string, err error) {
+	if groupErr, ok := err.(processes.GroupError); ok {
+		errs := make(map[string]string, len(groupErr))
+		for name, e := range groupErr {
+			errs[name] = e.Error()
+		}
+		ctx.AbortWithStatusJSON(processGroupsErrorStatus(err), &shard.ProcessGroupErrors{
+			Group:  group,
+			Errors: errs,
+		})
+		return
+	}
+	ctx.AbortWithError(processGroupsErrorStatus(err), err)
+}
+
+func (c *ProcessGroupsController) ListHandler(ctx *gin.Context) {
+	result := c.service.ListGroups()
+	ctx.JSON(http.StatusOK, &result)
+}
+
+func (c *ProcessGroupsController) GetHandler(ctx *gin.Context) {
+	group, err := c.service.FindGroup(ctx.Param("group"))
+	if err != nil {
+		ctx.AbortWithError(processGroupsErrorStatus(err), err)
+		return
+	}
+	ctx.JSON(http.StatusOK, group)
+}
+
+func (c *ProcessGroupsController) StartHandler(ctx *gin.Context) {
+	group := ctx.Param("group")
+	err := c.service.StartGroup(group)
+	if err != nil {
+		abortWithGroupError(ctx, group, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+func (c *ProcessGroupsController) StopHandler(ctx *gin.Context) {
+	group := ctx.Param("group")
+	user, ok := requestUser(c.middleware, ctx)
+	if !ok {
+		return
+	}
+	results, err := c.service.StopGroup(group, user)
+	if err != nil {
+		abortWithGroupError(ctx, group, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &shard.ProcessGroupStopResult{
+		Group:   group,
+		Results: results,
+	})
+}
+
+func (c *ProcessGroupsController) RestartHandler(ctx *gin.Context) {
+	group := ctx.Param("group")
+	user, ok := requestUser(c.middleware, ctx)
+	if !ok {
+		return
+	}
+	err := c.service.RestartGroup(group, user)
+	if err != nil {
+		abortWithGroupError(ctx, group, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
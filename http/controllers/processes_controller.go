@@ -1,34 +1,276 @@
 package controllers
 
 import (
+	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/zhsyourai/URCF-engine/http/controllers/shard"
+	"github.com/zhsyourai/URCF-engine/http/gin-jwt"
+	"github.com/zhsyourai/URCF-engine/models"
 	"github.com/zhsyourai/URCF-engine/services/processes"
+	"github.com/zhsyourai/URCF-engine/services/processes/types"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 )
 
-func NewProcessesController() *ProcessesController {
+func NewProcessesController(middleware *gin_jwt.JwtMiddleware) *ProcessesController {
 	return &ProcessesController{
-		service: processes.GetInstance(),
+		service:    processes.GetInstance(),
+		middleware: middleware,
 	}
 }
 
 // ProcessesController is our /processes controller.
 type ProcessesController struct {
-	service processes.Service
+	service    processes.Service
+	middleware *gin_jwt.JwtMiddleware
+	upgrader   websocket.Upgrader
 }
 
 func (c *ProcessesController) Handler(root *gin.RouterGroup) {
+	root.Use(c.middleware.Handler)
 	root.GET("/list", c.ListHandler)
+	root.POST("", c.PrepareHandler)
+	root.GET("/by-name/:name", c.GetHandler)
+	root.GET("/by-name/:name/alive", c.IsAliveHandler)
+	root.GET("/by-name/:name/output", c.OutputHandler)
+	root.GET("/by-name/:name/attach", c.AttachHandler)
+	root.GET("/by-name/:name/runs", c.ListRunsHandler)
+	root.POST("/by-name/:name/start", c.StartHandler)
+	root.POST("/by-name/:name/stop", c.StopHandler)
+	root.POST("/by-name/:name/restart", c.RestartHandler)
+	root.POST("/by-name/:name/kill", c.KillHandler)
+	root.POST("/by-name/:name/clean", c.CleanHandler)
 	root.DELETE("/*name", c.KillHandler)
 }
 
+func processesErrorStatus(err error) int {
+	switch err {
+	case processes.ProcessNotExist:
+		return http.StatusNotFound
+	case processes.ProcessExist, processes.ProcessNotRun, processes.ProcessAdopted, processes.ProcessReplaced:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func (c *ProcessesController) ListHandler(ctx *gin.Context) {
 	result := c.service.ListAll()
 	ctx.JSON(http.StatusOK, &result)
 }
 
+func (c *ProcessesController) PrepareHandler(ctx *gin.Context) {
+	param := &models.ProcessParam{}
+	if err := ctx.BindJSON(param); err != nil {
+		return
+	}
+	if param.Name == "" || param.Cmd == "" {
+		ctx.AbortWithError(http.StatusBadRequest, ErrNameAndCmdCannotBeEmpty)
+		return
+	}
+
+	proc, err := c.service.Prepare(*param)
+	if err != nil {
+		ctx.AbortWithError(processesErrorStatus(err), err)
+		return
+	}
+	ctx.JSON(http.StatusOK, proc)
+}
+
+func (c *ProcessesController) GetHandler(ctx *gin.Context) {
+	name := processName(ctx)
+	proc := c.service.FindByName(name)
+	if proc == nil {
+		ctx.AbortWithError(http.StatusNotFound, processes.ProcessNotExist)
+		return
+	}
+	ctx.JSON(http.StatusOK, proc)
+}
+
+func (c *ProcessesController) IsAliveHandler(ctx *gin.Context) {
+	name := processName(ctx)
+	if c.service.FindByName(name) == nil {
+		ctx.AbortWithError(http.StatusNotFound, processes.ProcessNotExist)
+		return
+	}
+	ctx.JSON(http.StatusOK, &shard.ProcessAlive{
+		Name:  name,
+		Alive: c.service.IsAlive(name),
+	})
+}
+
+// OutputHandler streams the stdout and stderr lines of a process as Server-Sent Events,
+// starting with the last lines kept in its output buffer.
+func (c *ProcessesController) OutputHandler(ctx *gin.Context) {
+	name := processName(ctx)
+	var request shard.ProcessOutputRequest
+	if ctx.BindQuery(&request) != nil {
+		return
+	}
+	tail, err := c.service.Tail(name, request.Lines)
+	if err != nil {
+		ctx.AbortWithError(processesErrorStatus(err), err)
+		return
+	}
+	defer tail.Close()
+
+	longLived(ctx)
+	clientGone := ctx.Writer.CloseNotify()
+	history := tail.History
+	ctx.Stream(func(w io.Writer) bool {
+		if history != nil {
+			for _, line := range history {
+				ctx.SSEvent("output", line)
+			}
+			history = nil
+			return true
+		}
+		select {
+		case line, ok := <-tail.Lines():
+			if !ok {
+				return false
+			}
+			ctx.SSEvent("output", line)
+			return true
+		case <-clientGone:
+			return false
+		}
+	})
+}
+
+// AttachHandler upgrades to a WebSocket and writes every message received on it to the
+// stdin of a process.
+func (c *ProcessesController) AttachHandler(ctx *gin.Context) {
+	name := processName(ctx)
+	mode, err := types.ParseAttachMode(ctx.Query("mode"))
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	username, ok := requestUser(c.middleware, ctx)
+	if !ok {
+		return
+	}
+
+	stdIn, err := c.service.Attach(name, username, mode)
+	if err != nil {
+		ctx.AbortWithError(processesErrorStatus(err), err)
+		return
+	}
+	defer stdIn.Close()
+
+	conn, err := c.upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_, err = stdIn.Write(message)
+		if err != nil {
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, err.Error()), time.Now().Add(time.Second))
+			return
+		}
+	}
+}
+
+func (c *ProcessesController) StartHandler(ctx *gin.Context) {
+	c.doAction(ctx, c.service.Start)
+}
+
+func (c *ProcessesController) StopHandler(ctx *gin.Context) {
+	name := processName(ctx)
+	username, ok := requestUser(c.middleware, ctx)
+	if !ok {
+		return
+	}
+	result, err := c.service.Stop(name, username)
+	if err != nil {
+		ctx.AbortWithError(processesErrorStatus(err), err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &shard.ProcessStopResult{
+		Name:   name,
+		Result: result,
+	})
+}
+
+func (c *ProcessesController) RestartHandler(ctx *gin.Context) {
+	username, ok := requestUser(c.middleware, ctx)
+	if !ok {
+		return
+	}
+	c.doAction(ctx, func(name string) error {
+		return c.service.Restart(name, username)
+	})
+}
+
 func (c *ProcessesController) KillHandler(ctx *gin.Context) {
-	name := ctx.Param("name")
-	c.service.Kill(name)
+	username, ok := requestUser(c.middleware, ctx)
+	if !ok {
+		return
+	}
+	c.doAction(ctx, func(name string) error {
+		return c.service.Kill(name, username)
+	})
+}
+
+func (c *ProcessesController) CleanHandler(ctx *gin.Context) {
+	c.doAction(ctx, c.service.Clean)
+}
+
+// processName returns the process name of the request. The DELETE route takes the name
+// as a catch-all parameter, which keeps its leading slash.
+func processName(ctx *gin.Context) string {
+	return strings.TrimPrefix(ctx.Param("name"), "/")
+}
+
+// requestUser returns the user of the request, or aborts it when the token has none.
+func requestUser(middleware *gin_jwt.JwtMiddleware, ctx *gin.Context) (string, bool) {
+	token, err := middleware.ExtractToken(ctx)
+	if err != nil {
+		ctx.AbortWithError(http.StatusUnauthorized, err)
+		return "", false
+	}
+	username, ok := token.Claims.(jwt.MapClaims)["username"].(string)
+	if !ok {
+		ctx.AbortWithError(http.StatusUnauthorized, ErrMissingAuthInfo)
+		return "", false
+	}
+	return username, true
+}
+
+func (c *ProcessesController) ListRunsHandler(ctx *gin.Context) {
+	var paging shard.Paging
+	if ctx.BindQuery(&paging) != nil {
+		return
+	}
+
+	total, runs, err := c.service.ListRuns(processName(ctx), paging.Page, paging.Size, paging.Sort, paging.Order)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &shard.ProcessRunsWithCount{
+		TotalCount: total,
+		Items:      runs,
+	})
+}
+
+func (c *ProcessesController) doAction(ctx *gin.Context, action func(name string) error) {
+	name := processName(ctx)
+	err := action(name)
+	if err != nil {
+		ctx.AbortWithError(processesErrorStatus(err), err)
+		return
+	}
 	ctx.Status(http.StatusOK)
 }
@@ -0,0 +1,12 @@
+package shard
+
+import "github.com/zhsyourai/URCF-engine/models"
+
+type AutoStartsWithCount struct {
+	TotalCount int64              `json:"total_count"`
+	Items      []models.AutoStart `json:"items"`
+}
+
+type AutoStartID struct {
+	ID int64 `json:"id"`
+}
@@ -11,3 +11,17 @@ type ConfigurationsWithCount struct {
 	TotalCount int64           `json:"total_count"`
 	Items      []models.Config `json:"items"`
 }
+
+// ConfigWatchRequest is what a watch follows, the changes of the keys starting with Prefix
+// after Revision. Wait is how many seconds a long poll waits for one.
+type ConfigWatchRequest struct {
+	Prefix   string `form:"prefix"`
+	Revision int64  `form:"revision"`
+	Wait     int    `form:"wait"`
+}
+
+// ConfigEvents are the changes a long poll got, Revision is the one to poll after next.
+type ConfigEvents struct {
+	Revision int64                `json:"revision"`
+	Events   []models.ConfigEvent `json:"events"`
+}
@@ -0,0 +1,12 @@
+package shard
+
+import "github.com/zhsyourai/URCF-engine/models"
+
+type JobsWithCount struct {
+	TotalCount int64        `json:"total_count"`
+	Items      []models.Job `json:"items"`
+}
+
+type JobID struct {
+	ID int64 `json:"id"`
+}
@@ -1,8 +1,53 @@
 package shard
 
-import "github.com/zhsyourai/URCF-engine/models"
+import (
+	"github.com/zhsyourai/URCF-engine/models"
+	"time"
+)
 
 type LogsWithCount struct {
 	TotalCount int64        `json:"total_count"`
 	Items      []models.Log `json:"items"`
 }
+
+// LogSearchRequest are the filters of a log search, besides paging. Each field is given as
+// key=value.
+type LogSearchRequest struct {
+	Name     string    `form:"name"`
+	Level    string    `form:"level"`
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	TraceID  string    `form:"trace_id"`
+	Contains string    `form:"contains"`
+	Match    string    `form:"match"`
+	Fields   []string  `form:"field"`
+}
+
+// LogLevels are the default level and the levels set per logger name.
+type LogLevels struct {
+	Default string            `json:"default"`
+	Levels  map[string]string `json:"levels"`
+}
+
+// LogLevelRequest sets the level of the logger Name, or the default level if Name is empty.
+// An empty Level makes the logger follow the default level again.
+type LogLevelRequest struct {
+	Name  string `json:"name"`
+	Level string `json:"level"`
+}
+
+// LogTailRequest filters the logs of a tail. Pattern is a regular expression on the message,
+// Lines is how many logs inserted before the tail to start with.
+type LogTailRequest struct {
+	Name    string `form:"name"`
+	Level   string `form:"level"`
+	Pattern string `form:"pattern"`
+	Lines   int    `form:"lines"`
+}
+
+// LogExportRequest is the format of an export, besides its filters. Giving Names bundles a
+// file for each name in a tar.gz.
+type LogExportRequest struct {
+	Format string   `form:"format"`
+	Names  []string `form:"names"`
+}
@@ -0,0 +1,35 @@
+package shard
+
+import (
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/processes/types"
+)
+
+type ProcessOutputRequest struct {
+	Lines int `json:"lines" form:"lines" query:"lines"`
+}
+
+type ProcessAlive struct {
+	Name  string `json:"name"`
+	Alive bool   `json:"alive"`
+}
+
+type ProcessStopResult struct {
+	Name   string           `json:"name"`
+	Result types.StopResult `json:"result"`
+}
+
+type ProcessRunsWithCount struct {
+	TotalCount int64               `json:"total_count"`
+	Items      []models.ProcessRun `json:"items"`
+}
+
+type ProcessGroupStopResult struct {
+	Group   string                      `json:"group"`
+	Results map[string]types.StopResult `json:"results"`
+}
+
+type ProcessGroupErrors struct {
+	Group  string            `json:"group"`
+	Errors map[string]string `json:"errors"`
+}
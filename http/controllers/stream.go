@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamWriteTimeout bounds each write of a long lived response, instead of the WriteTimeout
+// of the server bounding the whole response.
+const StreamWriteTimeout = 10 * time.Second
+
+type responseControllerKey struct{}
+
+// WithResponseController passes the http.ResponseController of each response to the
+// handlers of h, so that the long lived ones can push back their write deadline.
+func WithResponseController(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), responseControllerKey{}, http.NewResponseController(w))
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// deadlineWriter gives every write StreamWriteTimeout to complete.
+type deadlineWriter struct {
+	gin.ResponseWriter
+	controller *http.ResponseController
+}
+
+func (w deadlineWriter) extend() {
+	w.controller.SetWriteDeadline(time.Now().Add(StreamWriteTimeout))
+}
+
+func (w deadlineWriter) Write(data []byte) (int, error) {
+	w.extend()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w deadlineWriter) WriteString(s string) (int, error) {
+	w.extend()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w deadlineWriter) Flush() {
+	w.extend()
+	w.ResponseWriter.Flush()
+}
+
+// longLived lets the response of ctx outlive the WriteTimeout of the server, for as long as
+// each write completes within StreamWriteTimeout.
+func longLived(ctx *gin.Context) {
+	controller, ok := ctx.Request.Context().Value(responseControllerKey{}).(*http.ResponseController)
+	if !ok {
+		return
+	}
+	ctx.Writer = deadlineWriter{
+		ResponseWriter: ctx.Writer,
+		controller:     controller,
+	}
+}
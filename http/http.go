@@ -12,6 +12,7 @@ import (
 	"github.com/gin-contrib/secure"
 	"github.com/gin-gonic/gin"
 	"github.com/zhsyourai/URCF-engine/config"
+	"github.com/zhsyourai/URCF-engine/daemon"
 	"github.com/zhsyourai/URCF-engine/http/controllers"
 	"github.com/zhsyourai/URCF-engine/http/gin-jwt"
 	"net/http"
@@ -86,13 +87,19 @@ func apiServer() (*http.Server, error) {
 		controllers.NewConfigurationController().Handler(v1.Group("/configuration"))
 		controllers.NewLogController(jwtMiddleware).Handler(v1.Group("/log"))
 		controllers.NewNetFilterController().Handler(v1.Group("/netfilter"))
-		controllers.NewProcessesController().Handler(v1.Group("/processes"))
+		controllers.NewProcessesController(jwtMiddleware).Handler(v1.Group("/processes"))
+		controllers.NewProcessGroupsController(jwtMiddleware).Handler(v1.Group("/process-groups"))
+		controllers.NewAutoStartController(jwtMiddleware).Handler(v1.Group("/autostart"))
+		controllers.NewManifestController(jwtMiddleware).Handler(v1.Group("/manifests"))
+		controllers.NewJobsController(jwtMiddleware).Handler(v1.Group("/jobs"))
 		controllers.NewPluginController(jwtMiddleware).Handler(v1.Group("/plugins"))
 	}
 
+	// Streaming endpoints such as the process output tail lift the WriteTimeout of their
+	// own response through the response controller.
 	return &http.Server{
 		Addr:           ":8080",
-		Handler:        router,
+		Handler:        controllers.WithResponseController(router),
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20,
@@ -158,36 +165,41 @@ func websServer() (*http.Server, error) {
 	}, nil
 }
 
-func StartHTTPServer() error {
-	api, err := apiServer()
+func StartHTTPServer() (err error) {
+	api, err = apiServer()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	webs, err := websServer()
+	webs, err = websServer()
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	w.Go(func() error {
-		err := api.ListenAndServe()
-		if err != nil {
-			log.Fatal(err)
-		}
-		return err
+		return serve("api", api)
 	})
 
 	w.Go(func() error {
-		err := webs.ListenAndServe()
-		if err != nil {
-			log.Fatal(err)
-		}
-		return err
+		return serve("webs", webs)
 	})
 
 	return w.Wait()
 }
 
+// serve serves server on its address, or on the listener handed over by the previous daemon.
+func serve(name string, server *http.Server) error {
+	l, err := daemon.Listen(name, "tcp", server.Addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = server.Serve(l)
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	return err
+}
+
 func StopHTTPServer() (err error) {
 	ctx, cancel := stdContext.WithTimeout(stdContext.Background(), shutdownTimeout)
 	defer cancel()
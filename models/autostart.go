@@ -5,15 +5,21 @@ import (
 )
 
 type AutoStart struct {
-	ID         int64
-	Priority   int32
-	StartDelay int32
-	StopDelay  int32
-	Parallel   bool
-	Enable     bool
-	CreateTime time.Time
-	UpdateTime time.Time
-	ProcessParam
+	ID         int64 `json:"id" yaml:"-"`
+	Priority   int32 `json:"priority" yaml:"priority"`
+	StartDelay int32 `json:"start_delay" yaml:"start-delay"`
+	StopDelay  int32 `json:"stop_delay" yaml:"stop-delay"`
+	Parallel   bool  `json:"parallel" yaml:"parallel"`
+	Enable     bool  `json:"enable" yaml:"-"`
+	// After lists the entries started before this one, Requires those that must also have
+	// started successfully for this one to start.
+	After    []string `json:"after" yaml:"after"`
+	Requires []string `json:"requires" yaml:"requires"`
+	// WaitReady waits for the dependencies to be ready instead of just started.
+	WaitReady    bool      `json:"wait_ready" yaml:"wait-ready"`
+	CreateTime   time.Time `json:"create_time" yaml:"-"`
+	UpdateTime   time.Time `json:"update_time" yaml:"-"`
+	ProcessParam `yaml:",inline"`
 }
 
 type ByPriority []AutoStart
@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	Key        string
@@ -9,3 +14,53 @@ type Config struct {
 	UpdateTime time.Time
 	Expires    time.Duration
 }
+
+type ConfigEventType uint32
+
+const (
+	PutEvent ConfigEventType = iota
+	DeleteEvent
+)
+
+func (t ConfigEventType) String() string {
+	switch t {
+	case PutEvent:
+		return "put"
+	case DeleteEvent:
+		return "delete"
+	}
+
+	return "unknown"
+}
+
+func (t ConfigEventType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *ConfigEventType) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(s) {
+	case "put":
+		*t = PutEvent
+	case "delete":
+		*t = DeleteEvent
+	default:
+		return fmt.Errorf("not a valid ConfigEventType: %q", s)
+	}
+	return nil
+}
+
+// ConfigEvent is a change of a configuration key. Revision increases by one with every
+// change, so a watch can resume after the last one it got.
+type ConfigEvent struct {
+	Type     ConfigEventType `json:"type"`
+	Key      string          `json:"key"`
+	OldValue interface{}     `json:"old_value"`
+	NewValue interface{}     `json:"new_value"`
+	Revision int64           `json:"revision"`
+	Time     time.Time       `json:"time"`
+}
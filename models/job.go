@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	OverlapSkip    = "skip"
+	OverlapQueue   = "queue"
+	OverlapReplace = "replace"
+)
+
+// Job launches a process on a cron expression or every Interval seconds. Overlap tells what
+// to do when a run is due while the previous one is still running, skip by default, and runs
+// longer than MaxRuntime seconds are stopped.
+type Job struct {
+	ID           int64     `json:"id" yaml:"-"`
+	Schedule     string    `json:"schedule" yaml:"schedule"`
+	Interval     int32     `json:"interval" yaml:"interval"`
+	Overlap      string    `json:"overlap" yaml:"overlap"`
+	MaxRuntime   int32     `json:"max_runtime" yaml:"max-runtime"`
+	Enable       bool      `json:"enable" yaml:"-"`
+	CreateTime   time.Time `json:"create_time" yaml:"-"`
+	UpdateTime   time.Time `json:"update_time" yaml:"-"`
+	ProcessParam `yaml:",inline"`
+}
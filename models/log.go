@@ -83,4 +83,34 @@ type Log struct {
 	Name       string    `json:"name"`
 	Level      Level     `json:"level"`
 	CreateTime time.Time `json:"create_time"`
+	// Fields are the structured fields of the log line besides its message and level.
+	Fields  map[string]interface{} `json:"fields"`
+	Caller  string                 `json:"caller"`
+	TraceID string                 `json:"trace_id"`
+	SpanID  string                 `json:"span_id"`
+}
+
+// LogQuery filters logs, zero values don't filter.
+type LogQuery struct {
+	Name string `json:"name"`
+	// MinLevel keeps the logs at least as severe, nil keeps every level.
+	MinLevel *Level    `json:"min_level"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	TraceID  string    `json:"trace_id"`
+	// Contains is a substring of the message.
+	Contains string `json:"contains"`
+	// Match is a full-text query on the message, in the SQLite FTS5 syntax.
+	Match string `json:"match"`
+	// Fields are values the structured fields of the logs must have.
+	Fields map[string]string `json:"fields"`
+}
+
+// LogTailFilter filters the logs followed by a tail, zero values don't filter.
+type LogTailFilter struct {
+	Name string `json:"name"`
+	// MinLevel keeps the logs at least as severe, nil keeps every level.
+	MinLevel *Level `json:"min_level"`
+	// Pattern is a regular expression the message must match.
+	Pattern string `json:"pattern"`
 }
@@ -0,0 +1,11 @@
+package models
+
+// ProcessManifest describes a process declared by a YAML file of the manifest directory, with
+// the same settings as an autostart entry.
+type ProcessManifest struct {
+	AutoStart `yaml:",inline"`
+	// Disable keeps the process prepared but not running.
+	Disable bool `json:"disable" yaml:"disable"`
+	// File is the manifest file the process is declared in.
+	File string `json:"file" yaml:"-"`
+}
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"go/types"
+	"strconv"
 	"strings"
+	"syscall"
 )
 
 type ProcessOption uint32
@@ -32,6 +34,50 @@ func (option ProcessOption) MarshalJSON() ([]byte, error) {
 	}
 }
 
+func (option *ProcessOption) UnmarshalJSON(data []byte) error {
+	var optionArray []string
+	err := json.Unmarshal(data, &optionArray)
+	if err != nil {
+		return err
+	}
+	*option = None
+	for _, opt := range optionArray {
+		switch strings.ToLower(opt) {
+		case "autorestart":
+			*option |= AutoRestart
+		case "hooklog":
+			*option |= HookLog
+		default:
+			return fmt.Errorf("not a valid ProcessOption: %q", opt)
+		}
+	}
+	return nil
+}
+
+func (option ProcessOption) MarshalYAML() (interface{}, error) {
+	optionArray := []string{}
+	if option&AutoRestart == AutoRestart {
+		optionArray = append(optionArray, "AutoRestart")
+	}
+	if option&HookLog == HookLog {
+		optionArray = append(optionArray, "HookLog")
+	}
+	return optionArray, nil
+}
+
+func (option *ProcessOption) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var optionArray []string
+	err := unmarshal(&optionArray)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(optionArray)
+	if err != nil {
+		return err
+	}
+	return option.UnmarshalJSON(data)
+}
+
 func (option ProcessOption) String() (ret string) {
 	if option == None {
 		return "None"
@@ -101,13 +147,161 @@ func (args *Args) Scan(value interface{}) error {
 	return nil
 }
 
+var signalNames = map[string]syscall.Signal{
+	"SIGABRT":   syscall.SIGABRT,
+	"SIGALRM":   syscall.SIGALRM,
+	"SIGBUS":    syscall.SIGBUS,
+	"SIGCHLD":   syscall.SIGCHLD,
+	"SIGCONT":   syscall.SIGCONT,
+	"SIGFPE":    syscall.SIGFPE,
+	"SIGHUP":    syscall.SIGHUP,
+	"SIGILL":    syscall.SIGILL,
+	"SIGINT":    syscall.SIGINT,
+	"SIGIO":     syscall.SIGIO,
+	"SIGKILL":   syscall.SIGKILL,
+	"SIGPIPE":   syscall.SIGPIPE,
+	"SIGPROF":   syscall.SIGPROF,
+	"SIGQUIT":   syscall.SIGQUIT,
+	"SIGSEGV":   syscall.SIGSEGV,
+	"SIGSTOP":   syscall.SIGSTOP,
+	"SIGSYS":    syscall.SIGSYS,
+	"SIGTERM":   syscall.SIGTERM,
+	"SIGTRAP":   syscall.SIGTRAP,
+	"SIGTSTP":   syscall.SIGTSTP,
+	"SIGTTIN":   syscall.SIGTTIN,
+	"SIGTTOU":   syscall.SIGTTOU,
+	"SIGURG":    syscall.SIGURG,
+	"SIGUSR1":   syscall.SIGUSR1,
+	"SIGUSR2":   syscall.SIGUSR2,
+	"SIGVTALRM": syscall.SIGVTALRM,
+	"SIGWINCH":  syscall.SIGWINCH,
+	"SIGXCPU":   syscall.SIGXCPU,
+	"SIGXFSZ":   syscall.SIGXFSZ,
+}
+
+// maxSignal is the highest signal number, real-time signals included.
+const maxSignal = 64
+
+// ParseSignal parses a signal name such as "SIGTERM" or "term", or a signal number such as "15".
+func ParseSignal(signal string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(signal); err == nil {
+		if n <= 0 || n > maxSignal {
+			return 0, fmt.Errorf("not a valid Signal: %q", signal)
+		}
+		return syscall.Signal(n), nil
+	}
+	name := strings.ToUpper(signal)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig, ok := signalNames[name]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("not a valid Signal: %q", signal)
+}
+
+// SignalName returns the name of sig such as "SIGTERM", or its number for the signals
+// ParseSignal doesn't know.
+func SignalName(sig syscall.Signal) string {
+	for name, s := range signalNames {
+		if s == sig {
+			return name
+		}
+	}
+	return strconv.Itoa(int(sig))
+}
+
 type Env map[string]string
 
+// ResourceLimits are applied through a cgroup of its own to a process. Zero means no limit.
+type ResourceLimits struct {
+	// CPUQuota is the CPU time the process may use, in percent of one CPU.
+	CPUQuota  int32  `json:"cpu_quota" yaml:"cpu-quota"`
+	MemoryMax int64  `json:"memory_max" yaml:"memory-max"`
+	PidsMax   int64  `json:"pids_max" yaml:"pids-max"`
+	IOWeight  uint16 `json:"io_weight" yaml:"io-weight"`
+}
+
+const (
+	RestartAlways    = "always"
+	RestartOnFailure = "on-failure"
+	RestartNever     = "never"
+)
+
+// RestartPolicy tells when a process that exited on its own is restarted. An empty Mode
+// means always if the AutoRestart option is set and never otherwise. Delays are exponential
+// from InitialDelay up to MaxDelay seconds, and a process restarted MaxRestarts times within
+// Window seconds is left in crash loop. Zero values take the defaults.
+type RestartPolicy struct {
+	Mode string `json:"mode" yaml:"mode"`
+	// ExitCodes restricts on-failure restarts to these exit codes, a process killed by a
+	// signal exits with 128 plus the signal number.
+	ExitCodes    []int `json:"exit_codes" yaml:"exit-codes"`
+	InitialDelay int32 `json:"initial_delay" yaml:"initial-delay"`
+	MaxDelay     int32 `json:"max_delay" yaml:"max-delay"`
+	MaxRestarts  int   `json:"max_restarts" yaml:"max-restarts"`
+	Window       int32 `json:"window" yaml:"window"`
+}
+
+const (
+	ProbeExec = "exec"
+	ProbeTCP  = "tcp"
+	ProbeHTTP = "http"
+	ProbeGRPC = "grpc"
+)
+
+// Probe checks the health of a running process every Interval seconds. A check taking longer
+// than Timeout seconds fails, and the probe fails after FailureThreshold consecutive failed
+// checks. Zero values take the defaults.
+type Probe struct {
+	Type string `json:"type" yaml:"type"`
+	// Command is run in the work directory of the process by exec probes, it succeeds when
+	// the command exits with code 0.
+	Command []string `json:"command" yaml:"command"`
+	// Address is dialed by tcp and grpc probes, as host:port.
+	Address string `json:"address" yaml:"address"`
+	// URL is requested by http probes, any 2xx or 3xx status succeeds.
+	URL string `json:"url" yaml:"url"`
+	// Service is checked by grpc probes with grpc.health.v1, empty means the whole server.
+	Service string `json:"service" yaml:"service"`
+	// InitialDelay is how long to wait after the start before the first check, in seconds.
+	InitialDelay     int32 `json:"initial_delay" yaml:"initial-delay"`
+	Interval         int32 `json:"interval" yaml:"interval"`
+	Timeout          int32 `json:"timeout" yaml:"timeout"`
+	FailureThreshold int   `json:"failure_threshold" yaml:"failure-threshold"`
+}
+
+// ProcessCredential is the identity a process runs with, empty fields are inherited from the
+// daemon. Capabilities are retained across the switch to User, every other one is dropped.
+type ProcessCredential struct {
+	User         string   `json:"user" yaml:"user"`
+	Group        string   `json:"group" yaml:"group"`
+	Groups       []string `json:"groups" yaml:"groups"`
+	Umask        string   `json:"umask" yaml:"umask"`
+	Capabilities []string `json:"capabilities" yaml:"capabilities"`
+}
+
+func (limits ResourceLimits) IsZero() bool {
+	return limits == ResourceLimits{}
+}
+
 type ProcessParam struct {
-	Name    string        `json:"name"`
-	Cmd     string        `json:"cmd"`
-	Args    Args          `json:"args"`
-	WorkDir string        `json:"work_dir"`
-	Env     Env           `json:"env"`
-	Option  ProcessOption `json:"option"`
+	Name          string            `json:"name" yaml:"name"`
+	Cmd           string            `json:"cmd" yaml:"cmd"`
+	Args          Args              `json:"args" yaml:"args"`
+	WorkDir       string            `json:"work_dir" yaml:"work-dir"`
+	Env           Env               `json:"env" yaml:"env"`
+	Option        ProcessOption     `json:"option" yaml:"option"`
+	StopSignal    string            `json:"stop_signal" yaml:"stop-signal"`
+	StopTimeout   int32             `json:"stop_timeout" yaml:"stop-timeout"`
+	Limits        ResourceLimits    `json:"limits" yaml:"limits"`
+	Credential    ProcessCredential `json:"credential" yaml:"credential"`
+	RestartPolicy RestartPolicy     `json:"restart_policy" yaml:"restart-policy"`
+	// Groups are the process groups the process belongs to, for collective operations.
+	Groups []string `json:"groups" yaml:"groups"`
+	// Liveness fails terminate the process, which is then handled by its restart policy.
+	Liveness *Probe `json:"liveness" yaml:"liveness"`
+	// Readiness tells whether the process is ready to serve, a process without it is ready
+	// once running.
+	Readiness *Probe `json:"readiness" yaml:"readiness"`
 }
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// ProcessRun is one run of a managed process, from its start to its exit.
+type ProcessRun struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Pid       int       `json:"pid"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	// ExitCode is -1 when the process was terminated by Signal.
+	ExitCode int    `json:"exit_code"`
+	Signal   string `json:"signal"`
+	CoreDump bool   `json:"core_dump"`
+	// StopBy is who requested the process to stop, empty when it exited on its own.
+	StopBy string `json:"stop_by"`
+}
@@ -6,8 +6,10 @@ import (
 	"reflect"
 
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/zhsyourai/URCF-engine/models"
 	"github.com/zhsyourai/URCF-engine/services/global_configuration"
 	"os"
@@ -15,32 +17,41 @@ import (
 )
 
 const (
-	_CREATE_TABLE_SQL_ = `CREATE TABLE IF NOT EXISTS accounts (
+	_CREATE_TABLE_SQL_ = `CREATE TABLE IF NOT EXISTS autostarts (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
 			priority INTEGER NOT NULL,
 			start_delay INTEGER NOT NULL,
 			stop_delay INTEGER NOT NULL,
 			parallel BOOLEAN NOT NULL,
 			enable BOOLEAN NOT NULL,
+			wait_ready BOOLEAN NOT NULL,
+			after TEXT NOT NULL,
+			requires TEXT NOT NULL,
+			process_param TEXT NOT NULL,
 			create_time DATETIME NOT NULL,
 			update_time DATETIME NOT NULL
 		)`
 
-	_INSERT_SQL = `INSERT INTO autostarts(priority, start_delay, stop_delay, parallel, enable, name, cmd, args, workdir, env, option, create_time, update_time)
-			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`
+	_COLUMNS_ = `id, name, priority, start_delay, stop_delay, parallel, enable, wait_ready, after, requires, 
+			process_param, create_time, update_time`
+
+	_INSERT_SQL = `INSERT INTO autostarts(name, priority, start_delay, stop_delay, parallel, enable, wait_ready, 
+			after, requires, process_param, create_time, update_time)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`
 
 	_EXIST_BY_ID_SQL = `SELECT EXISTS(SELECT id FROM autostarts WHERE id = ?)`
 
-	_SELECT_ALL_SQL = `SELECT * FROM autostarts`
+	_SELECT_ALL_SQL = `SELECT ` + _COLUMNS_ + ` FROM autostarts`
 
-	_SELECT_BY_ID_SQL = `SELECT * FROM autostarts WHERE id = ?`
+	_SELECT_BY_ID_SQL = `SELECT ` + _COLUMNS_ + ` FROM autostarts WHERE id = ?`
 
 	_DELETE_BY_ID_SQL = `DELETE FROM autostarts WHERE id = ?`
 
 	_DELETE_ALL_SQL = `DELETE FROM autostarts`
 
-	_UPDATE_BY_ID_SQL = `UPDATE autostarts SET priority = ?, start_delay = ?, stop_delay = ?, parallel = ?, 
-			enable = ?, name = ?, cmd = ?, args = ?, workdir = ?, env = ?, option = ?, 
+	_UPDATE_BY_ID_SQL = `UPDATE autostarts SET name = ?, priority = ?, start_delay = ?, stop_delay = ?, parallel = ?, 
+			enable = ?, wait_ready = ?, after = ?, requires = ?, process_param = ?, 
 			update_time = CURRENT_TIMESTAMP WHERE id = ?`
 )
 
@@ -79,6 +90,52 @@ func NewAutostartRepository() Repository {
 	return &autostartRepository{db}
 }
 
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAutoStart scans a row selected with _COLUMNS_. The dependencies and the process
+// parameters are stored as JSON.
+func scanAutoStart(row scanner, autoStart *models.AutoStart) error {
+	var after, requires, param string
+	err := row.Scan(&autoStart.ID, &autoStart.Name, &autoStart.Priority, &autoStart.StartDelay, &autoStart.StopDelay,
+		&autoStart.Parallel, &autoStart.Enable, &autoStart.WaitReady, &after, &requires, &param,
+		&autoStart.CreateTime, &autoStart.UpdateTime)
+	if err != nil {
+		return err
+	}
+	name := autoStart.Name
+	err = json.Unmarshal([]byte(param), &autoStart.ProcessParam)
+	if err != nil {
+		return err
+	}
+	autoStart.Name = name
+	err = json.Unmarshal([]byte(after), &autoStart.After)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(requires), &autoStart.Requires)
+}
+
+// autoStartArgs returns the values of the insert and update statements.
+func autoStartArgs(autoStart *models.AutoStart) ([]interface{}, error) {
+	after, err := json.Marshal(autoStart.After)
+	if err != nil {
+		return nil, err
+	}
+	requires, err := json.Marshal(autoStart.Requires)
+	if err != nil {
+		return nil, err
+	}
+	param, err := json.Marshal(autoStart.ProcessParam)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{autoStart.Name, autoStart.Priority, autoStart.StartDelay, autoStart.StopDelay,
+		autoStart.Parallel, autoStart.Enable, autoStart.WaitReady, string(after), string(requires),
+		string(param)}, nil
+}
+
 // autostartRepository is a "Repository"
 // which manages the AutoStarts using the memory data source (map).
 type autostartRepository struct {
@@ -101,9 +158,11 @@ func (r *autostartRepository) InsertAutoStart(autoStart *models.AutoStart) (err
 		}
 	}()
 
-	result, err := tx.Exec(_INSERT_SQL, &autoStart.ID, &autoStart.Priority, &autoStart.StartDelay, &autoStart.StopDelay,
-		&autoStart.Parallel, &autoStart.Enable, &autoStart.Name, &autoStart.Cmd, &autoStart.Args,
-		&autoStart.WorkDir, &autoStart.Env, &autoStart.Option)
+	args, err := autoStartArgs(autoStart)
+	if err != nil {
+		return
+	}
+	result, err := tx.Exec(_INSERT_SQL, args...)
 	if err != nil {
 		return
 	}
@@ -128,10 +187,7 @@ func (r *autostartRepository) FindAutoStartByID(id int64) (autoStart models.Auto
 		}
 	}()
 
-	err = tx.QueryRow(_SELECT_BY_ID_SQL, id).Scan(
-		&autoStart.ID, &autoStart.Priority, &autoStart.StartDelay, &autoStart.StopDelay,
-		&autoStart.Parallel, &autoStart.Enable, &autoStart.CreateTime, &autoStart.UpdateTime, &autoStart.Name,
-		&autoStart.Cmd, &autoStart.Args, &autoStart.WorkDir, &autoStart.Env, &autoStart.Option)
+	err = scanAutoStart(tx.QueryRow(_SELECT_BY_ID_SQL, id), &autoStart)
 	if err != nil {
 		return
 	}
@@ -164,10 +220,7 @@ func (r *autostartRepository) FindAll() (autoStarts []models.AutoStart, err erro
 
 	for rows.Next() {
 		var autoStart models.AutoStart
-		err = rows.Scan(
-			&autoStart.ID, &autoStart.Priority, &autoStart.StartDelay, &autoStart.StopDelay,
-			&autoStart.Parallel, &autoStart.Enable, &autoStart.CreateTime, &autoStart.UpdateTime, &autoStart.Name,
-			&autoStart.Cmd, &autoStart.Args, &autoStart.WorkDir, &autoStart.Env, &autoStart.Option)
+		err = scanAutoStart(rows, &autoStart)
 		if err != nil {
 			return
 		}
@@ -192,10 +245,7 @@ func (r *autostartRepository) DeleteAutoStartByID(id int64) (autoStart models.Au
 			err = tx.Commit()
 		}
 	}()
-	err = tx.QueryRow(_SELECT_BY_ID_SQL, id).Scan(
-		&autoStart.ID, &autoStart.Priority, &autoStart.StartDelay, &autoStart.StopDelay,
-		&autoStart.Parallel, &autoStart.Enable, &autoStart.CreateTime, &autoStart.UpdateTime, &autoStart.Name,
-		&autoStart.Cmd, &autoStart.Args, &autoStart.WorkDir, &autoStart.Env, &autoStart.Option)
+	err = scanAutoStart(tx.QueryRow(_SELECT_BY_ID_SQL, id), &autoStart)
 	if err != nil {
 		return
 	}
@@ -224,10 +274,7 @@ func (r *autostartRepository) UpdateAutoStartByID(id int64,
 			err = tx.Commit()
 		}
 	}()
-	err = tx.QueryRow(_SELECT_BY_ID_SQL, id).Scan(
-		&autoStart.ID, &autoStart.Priority, &autoStart.StartDelay, &autoStart.StopDelay,
-		&autoStart.Parallel, &autoStart.Enable, &autoStart.CreateTime, &autoStart.UpdateTime, &autoStart.Name,
-		&autoStart.Cmd, &autoStart.Args, &autoStart.WorkDir, &autoStart.Env, &autoStart.Option)
+	err = scanAutoStart(tx.QueryRow(_SELECT_BY_ID_SQL, id), &autoStart)
 	if err != nil {
 		return
 	}
@@ -243,9 +290,11 @@ func (r *autostartRepository) UpdateAutoStartByID(id int64,
 		}
 	}
 
-	_, err = tx.Exec(_UPDATE_BY_ID_SQL, &autoStart.Priority, &autoStart.StartDelay,
-		&autoStart.StopDelay, &autoStart.Parallel, &autoStart.Enable, &autoStart.Name, &autoStart.Cmd, &autoStart.Args,
-		&autoStart.WorkDir, &autoStart.Env, &autoStart.Option)
+	args, err := autoStartArgs(&autoStart)
+	if err != nil {
+		return
+	}
+	_, err = tx.Exec(_UPDATE_BY_ID_SQL, append(args, id)...)
 	if err != nil {
 		return
 	}
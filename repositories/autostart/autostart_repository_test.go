@@ -4,11 +4,57 @@ import (
 	"fmt"
 	"math/rand"
 	"testing"
+
+	"github.com/zhsyourai/URCF-engine/models"
 )
 
 var testID = "__test" + fmt.Sprint(rand.Int())
 var repo = NewAutostartRepository()
 
 func TestInsertAndFind(t *testing.T) {
+	autoStart := &models.AutoStart{
+		Priority:  1,
+		StopDelay: 5,
+		Enable:    true,
+		After:     []string{"db"},
+		Requires:  []string{"cache"},
+		WaitReady: true,
+		ProcessParam: models.ProcessParam{
+			Name: testID,
+			Cmd:  "/bin/sleep",
+			Args: []string{"10"},
+			Env:  models.Env{"A": "B"},
+		},
+	}
+	err := repo.InsertAutoStart(autoStart)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Insert error", fmt.Sprint(err))
+	}
+	ret, err := repo.FindAutoStartByID(autoStart.ID)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Find error", fmt.Sprint(err))
+	}
+	if ret.Name != testID || ret.Args[0] != "10" || ret.Env["A"] != "B" || ret.After[0] != "db" ||
+		ret.Requires[0] != "cache" || !ret.WaitReady {
+		t.Fatalf("Find error (%v not equal %v)", ret, *autoStart)
+	}
+
+	ret, err = repo.UpdateAutoStartByID(autoStart.ID, map[string]interface{}{
+		"Enable": false,
+	})
+	if err != nil {
+		t.Fatalf("%s(%s)", "Update error", fmt.Sprint(err))
+	}
+	ret, err = repo.FindAutoStartByID(autoStart.ID)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Find error", fmt.Sprint(err))
+	}
+	if ret.Enable {
+		t.Fatal("Update error (enable not changed)")
+	}
 
+	_, err = repo.DeleteAutoStartByID(autoStart.ID)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Delete error", fmt.Sprint(err))
+	}
 }
@@ -39,6 +39,15 @@ const (
 	_DELETE_ALL_SQL = `DELETE FROM configs`
 
 	_UPDATE_BY_KEY_SQL = `UPDATE configs SET value = ?, expires = ?, update_time = CURRENT_TIMESTAMP WHERE key = ?`
+
+	_CREATE_REVISION_TABLE_SQL_ = `CREATE TABLE IF NOT EXISTS revision (
+			id INTEGER PRIMARY KEY CHECK (id = 0),
+			value INTEGER NOT NULL
+		)`
+
+	_SELECT_REVISION_SQL = `SELECT value FROM revision WHERE id = 0`
+
+	_SAVE_REVISION_SQL = `INSERT OR REPLACE INTO revision(id, value) VALUES(0, ?)`
 )
 
 // Repository handles the basic operations of a account entity/model.
@@ -53,6 +62,9 @@ type Repository interface {
 	DeleteConfigByKey(key string) (models.Config, error)
 	DeleteAll() error
 	UpdateConfigByKey(key string, fields map[string]interface{}) (config models.Config, err error)
+	// Revision returns the revision of the last configuration change, 0 before the first.
+	Revision() (int64, error)
+	SaveRevision(revision int64) error
 }
 
 // NewConfigurationRepository returns a new account memory-based repository,
@@ -74,6 +86,10 @@ func NewConfigurationRepository() Repository {
 	if err != nil {
 		log.Fatal(err)
 	}
+	_, err = db.Exec(_CREATE_REVISION_TABLE_SQL_)
+	if err != nil {
+		log.Fatal(err)
+	}
 	return &configurationRepository{OrderPaging: &repositories.OrderPaging{
 		MaxSize: 100,
 		CanOrderFields: map[string]repositories.Order{
@@ -291,7 +307,7 @@ func (r *configurationRepository) UpdateConfigByKey(key string,
 		}
 	}
 
-	_, err = tx.Exec(_UPDATE_BY_KEY_SQL, config.Value, config.Expires)
+	_, err = tx.Exec(_UPDATE_BY_KEY_SQL, config.Value, config.Expires, key)
 	if err != nil {
 		return
 	}
@@ -299,6 +315,19 @@ func (r *configurationRepository) UpdateConfigByKey(key string,
 	return
 }
 
+func (r *configurationRepository) Revision() (revision int64, err error) {
+	err = r.db.QueryRow(_SELECT_REVISION_SQL).Scan(&revision)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return
+}
+
+func (r *configurationRepository) SaveRevision(revision int64) error {
+	_, err := r.db.Exec(_SAVE_REVISION_SQL, revision)
+	return err
+}
+
 func (r *configurationRepository) Close() error {
 	if r.db != nil {
 		return r.db.Close()
@@ -0,0 +1,278 @@
+package job
+
+import (
+	"io"
+	"log"
+	"reflect"
+
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+	"os"
+	"path"
+)
+
+const (
+	_CREATE_TABLE_SQL_ = `CREATE TABLE IF NOT EXISTS jobs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			schedule TEXT NOT NULL,
+			interval INTEGER NOT NULL,
+			overlap TEXT NOT NULL,
+			max_runtime INTEGER NOT NULL,
+			enable BOOLEAN NOT NULL,
+			process_param TEXT NOT NULL,
+			create_time DATETIME NOT NULL,
+			update_time DATETIME NOT NULL
+		)`
+
+	_COLUMNS_ = `id, name, schedule, interval, overlap, max_runtime, enable, process_param, create_time, update_time`
+
+	_INSERT_SQL = `INSERT INTO jobs(name, schedule, interval, overlap, max_runtime, enable, process_param, 
+			create_time, update_time)
+			VALUES(?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`
+
+	_SELECT_ALL_SQL = `SELECT ` + _COLUMNS_ + ` FROM jobs`
+
+	_SELECT_BY_ID_SQL = `SELECT ` + _COLUMNS_ + ` FROM jobs WHERE id = ?`
+
+	_DELETE_BY_ID_SQL = `DELETE FROM jobs WHERE id = ?`
+
+	_UPDATE_BY_ID_SQL = `UPDATE jobs SET name = ?, schedule = ?, interval = ?, overlap = ?, max_runtime = ?, 
+			enable = ?, process_param = ?, update_time = CURRENT_TIMESTAMP WHERE id = ?`
+)
+
+// Repository handles the basic operations of a Job entity/model.
+// It's an interface in order to be testable, i.e a memory Job repository or
+// a connected to an sql database.
+type Repository interface {
+	io.Closer
+	InsertJob(job *models.Job) error
+	FindJobByID(id int64) (models.Job, error)
+	FindAll() ([]models.Job, error)
+	DeleteJobByID(id int64) (models.Job, error)
+	UpdateJobByID(id int64, fields map[string]interface{}) (models.Job, error)
+}
+
+// NewJobRepository returns a new Job sqlite-based repository.
+func NewJobRepository() Repository {
+	confServ := global_configuration.GetGlobalConfig()
+	dbPath := confServ.Get().Sys.DatabasePath
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		os.MkdirAll(dbPath, 0770)
+	}
+	dbFile := path.Join(dbPath, "Job.db")
+
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(_CREATE_TABLE_SQL_)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &jobRepository{db}
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanJob scans a row selected with _COLUMNS_. The process parameters are stored as JSON.
+func scanJob(row scanner, job *models.Job) error {
+	var param string
+	err := row.Scan(&job.ID, &job.Name, &job.Schedule, &job.Interval, &job.Overlap, &job.MaxRuntime, &job.Enable,
+		&param, &job.CreateTime, &job.UpdateTime)
+	if err != nil {
+		return err
+	}
+	name := job.Name
+	err = json.Unmarshal([]byte(param), &job.ProcessParam)
+	job.Name = name
+	return err
+}
+
+// jobArgs returns the values of the insert and update statements.
+func jobArgs(job *models.Job) ([]interface{}, error) {
+	param, err := json.Marshal(job.ProcessParam)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{job.Name, job.Schedule, job.Interval, job.Overlap, job.MaxRuntime, job.Enable,
+		string(param)}, nil
+}
+
+type jobRepository struct {
+	db *sql.DB
+}
+
+func (r *jobRepository) InsertJob(job *models.Job) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	success := false
+	defer func() {
+		if !success {
+			if e := tx.Rollback(); e != nil {
+				err = e
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	args, err := jobArgs(job)
+	if err != nil {
+		return
+	}
+	result, err := tx.Exec(_INSERT_SQL, args...)
+	if err != nil {
+		return
+	}
+	job.ID, err = result.LastInsertId()
+	success = true
+	return
+}
+
+func (r *jobRepository) FindJobByID(id int64) (job models.Job, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	success := false
+	defer func() {
+		if !success {
+			if e := tx.Rollback(); e != nil {
+				err = e
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	err = scanJob(tx.QueryRow(_SELECT_BY_ID_SQL, id), &job)
+	if err != nil {
+		return
+	}
+	success = true
+	return
+}
+
+func (r *jobRepository) FindAll() (jobs []models.Job, err error) {
+	jobs = make([]models.Job, 0, 100)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	success := false
+	defer func() {
+		if !success {
+			if e := tx.Rollback(); e != nil {
+				err = e
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	rows, err := tx.Query(_SELECT_ALL_SQL)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var job models.Job
+		err = scanJob(rows, &job)
+		if err != nil {
+			return
+		}
+		jobs = append(jobs, job)
+	}
+	success = true
+	return
+}
+
+func (r *jobRepository) DeleteJobByID(id int64) (job models.Job, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	success := false
+	defer func() {
+		if !success {
+			if e := tx.Rollback(); e != nil {
+				err = e
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	err = scanJob(tx.QueryRow(_SELECT_BY_ID_SQL, id), &job)
+	if err != nil {
+		return
+	}
+
+	_, err = tx.Exec(_DELETE_BY_ID_SQL, id)
+	if err != nil {
+		return
+	}
+	success = true
+	return
+}
+
+func (r *jobRepository) UpdateJobByID(id int64, fields map[string]interface{}) (job models.Job, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	success := false
+	defer func() {
+		if !success {
+			if e := tx.Rollback(); e != nil {
+				err = e
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	err = scanJob(tx.QueryRow(_SELECT_BY_ID_SQL, id), &job)
+	if err != nil {
+		return
+	}
+
+	s := reflect.ValueOf(&job).Elem()
+	for k, v := range fields {
+		field := s.FieldByName(k)
+		if field.IsValid() {
+			field.Set(reflect.ValueOf(v))
+		} else {
+			err = errors.New(fmt.Sprintf("field %s not exist", k))
+			return
+		}
+	}
+
+	args, err := jobArgs(&job)
+	if err != nil {
+		return
+	}
+	_, err = tx.Exec(_UPDATE_BY_ID_SQL, append(args, id)...)
+	if err != nil {
+		return
+	}
+	success = true
+	return
+}
+
+func (r *jobRepository) Close() error {
+	if r.db != nil {
+		return r.db.Close()
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package job
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/zhsyourai/URCF-engine/models"
+)
+
+var testName = "__test" + fmt.Sprint(rand.Int())
+var repo = NewJobRepository()
+
+func TestInsertFindUpdateDelete(t *testing.T) {
+	job := &models.Job{
+		Schedule:   "*/5 * * * *",
+		Overlap:    models.OverlapQueue,
+		MaxRuntime: 60,
+		Enable:     true,
+		ProcessParam: models.ProcessParam{
+			Name: testName,
+			Cmd:  "/usr/bin/rotate",
+			Args: []string{"--all"},
+		},
+	}
+	err := repo.InsertJob(job)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Insert error", fmt.Sprint(err))
+	}
+	ret, err := repo.FindJobByID(job.ID)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Find error", fmt.Sprint(err))
+	}
+	if ret.Name != testName || ret.Schedule != job.Schedule || ret.Overlap != job.Overlap || ret.Args[0] != "--all" {
+		t.Fatalf("Find error (%v not equal %v)", ret, *job)
+	}
+
+	_, err = repo.UpdateJobByID(job.ID, map[string]interface{}{
+		"Enable": false,
+	})
+	if err != nil {
+		t.Fatalf("%s(%s)", "Update error", fmt.Sprint(err))
+	}
+	jobs, err := repo.FindAll()
+	if err != nil {
+		t.Fatalf("%s(%s)", "Find all error", fmt.Sprint(err))
+	}
+	found := false
+	for _, j := range jobs {
+		if j.ID == job.ID {
+			found = true
+			if j.Enable {
+				t.Fatal("Update error (enable not changed)")
+			}
+		}
+	}
+	if !found {
+		t.Fatal("Find all error (job not found)")
+	}
+
+	_, err = repo.DeleteJobByID(job.ID)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Delete error", fmt.Sprint(err))
+	}
+}
@@ -2,6 +2,9 @@ package log
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/zhsyourai/URCF-engine/models"
 	"github.com/zhsyourai/URCF-engine/repositories"
@@ -10,19 +13,56 @@ import (
 	"log"
 	"os"
 	"path"
+	"sort"
+	"strings"
+	"time"
 )
 
+var ErrFullTextUnsupported = errors.New("full-text search is not supported by this build, build with the sqlite_fts5 tag")
+
 const (
 	_CREATE_TABLE_SQL_ = `CREATE TABLE IF NOT EXISTS logs (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			name TEXT NOT NULL,
 			message TEXT NOT NULL,
 			level TEXT NOT NULL,
-			create_time DATETIME NOT NULL
+			create_time DATETIME NOT NULL,
+			fields TEXT NOT NULL DEFAULT '{}',
+			caller TEXT NOT NULL DEFAULT '',
+			trace_id TEXT NOT NULL DEFAULT '',
+			span_id TEXT NOT NULL DEFAULT ''
+		)`
+
+	_CREATE_FTS_TABLE_SQL_ = `CREATE VIRTUAL TABLE logs_fts USING fts5(
+			message, content='logs', content_rowid='id'
 		)`
 
-	_INSERT_SQL = `INSERT INTO logs(name, message, level, create_time)
-			VALUES(?, ?, ?, CURRENT_TIMESTAMP)`
+	_CREATE_FTS_TRIGGERS_SQL_ = `
+		CREATE TRIGGER IF NOT EXISTS logs_fts_insert AFTER INSERT ON logs BEGIN
+			INSERT INTO logs_fts(rowid, message) VALUES (new.id, new.message);
+		END;
+		CREATE TRIGGER IF NOT EXISTS logs_fts_delete AFTER DELETE ON logs BEGIN
+			INSERT INTO logs_fts(logs_fts, rowid, message) VALUES ('delete', old.id, old.message);
+		END`
+
+	_DROP_FTS_TRIGGERS_SQL_ = `
+		DROP TRIGGER IF EXISTS logs_fts_insert;
+		DROP TRIGGER IF EXISTS logs_fts_delete`
+
+	_REBUILD_FTS_SQL = `INSERT INTO logs_fts(logs_fts) VALUES ('rebuild')`
+
+	_FTS_AVAILABLE_SQL = `SELECT sqlite_compileoption_used('ENABLE_FTS5')`
+
+	_TABLE_EXIST_SQL = `SELECT COUNT(*) FROM sqlite_master WHERE name = ?`
+
+	_TABLE_INFO_SQL = `PRAGMA table_info(logs)`
+
+	_INSERT_SQL = `INSERT INTO logs(name, message, level, create_time, fields, caller, trace_id, span_id)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_SEARCH_SQL = `SELECT * FROM logs`
+
+	_COUNT_SEARCH_SQL = `SELECT COUNT(*) as count FROM logs`
 
 	_SELECT_BY_ID_SQL = `SELECT * FROM logs WHERE id = ?`
 
@@ -39,14 +79,43 @@ const (
 	_DELETE_BY_ID_SQL = `DELETE FROM logs WHERE id = ?`
 
 	_DELETE_ALL_SQL = `DELETE FROM logs`
+
+	_MAX_ID_BEFORE_SQL = `SELECT IFNULL(MAX(id), 0) FROM logs WHERE create_time < ?`
+
+	_NTH_NEWEST_ID_SQL = `SELECT id FROM logs ORDER BY id DESC LIMIT 1 OFFSET ?`
+
+	_NTH_NEWEST_ID_BY_NAME_SQL = `SELECT id FROM logs WHERE name = ? ORDER BY id DESC LIMIT 1 OFFSET ?`
+
+	_COUNT_GROUP_BY_NAME_SQL = `SELECT name, COUNT(*) FROM logs GROUP BY name`
+
+	_SELECT_RANGE_SQL = `SELECT * FROM logs WHERE id > ? AND id <= ? ORDER BY id LIMIT ?`
+
+	_SELECT_RANGE_BY_NAME_SQL = `SELECT * FROM logs WHERE name = ? AND id > ? AND id <= ? ORDER BY id LIMIT ?`
+
+	_DELETE_UP_TO_SQL = `DELETE FROM logs WHERE id <= ?`
+
+	_DELETE_UP_TO_BY_NAME_SQL = `DELETE FROM logs WHERE name = ? AND id <= ?`
+
+	_PAGE_COUNT_SQL = `PRAGMA page_count`
+
+	_FREELIST_COUNT_SQL = `PRAGMA freelist_count`
+
+	_PAGE_SIZE_SQL = `PRAGMA page_size`
+
+	_VACUUM_SQL = `VACUUM`
 )
 
+// createTimeFormat is how create_time is stored, in UTC as CURRENT_TIMESTAMP does.
+const createTimeFormat = "2006-01-02 15:04:05"
+
 // Repository handles the basic operations of a account entity/model.
 // It's an interface in order to be testable, i.e a memory account repository or
 // a connected to an sql database.
 type Repository interface {
 	io.Closer
 	InsertLog(log *models.Log) error
+	// InsertLogs inserts logs in a single transaction and sets their IDs.
+	InsertLogs(logs []*models.Log) error
 	FindLogByID(id int64) (models.Log, error)
 	FindLogByName(name string, page uint32, size uint32, sorts []repositories.Sort) ([]models.Log, error)
 	FindAll(page uint32, size uint32, sorts []repositories.Sort) ([]models.Log, error)
@@ -55,6 +124,27 @@ type Repository interface {
 	DeleteLogByID(id int64) (models.Log, error)
 	DeleteLogByName(name string) error
 	DeleteAll() error
+	Search(query models.LogQuery, page uint32, size uint32, sorts []repositories.Sort) ([]models.Log, error)
+	CountSearch(query models.LogQuery) (int64, error)
+	// MaxIDBefore returns the id of the newest log created before t, 0 if there is none.
+	MaxIDBefore(t time.Time) (int64, error)
+	// NthNewestID returns the id of the n-th newest log of name, or of all logs if name is
+	// empty, 0 if there are fewer logs. n starts at 1.
+	NthNewestID(name string, n int64) (int64, error)
+	CountGroupByName() (map[string]int64, error)
+	// FindLogsInRange returns up to limit logs of name, or of all names if empty, with an
+	// id in (fromID, toID], ordered by id.
+	FindLogsInRange(name string, fromID int64, toID int64, limit uint32) ([]models.Log, error)
+	// SearchAfter returns up to limit logs query matches with an id over afterID, ordered by
+	// id, to go through every match without paging.
+	SearchAfter(query models.LogQuery, afterID int64, limit uint32) ([]models.Log, error)
+	// DeleteLogsUpTo deletes the logs of name, or of all names if empty, with an id up to
+	// toID, and returns how many were deleted.
+	DeleteLogsUpTo(name string, toID int64) (int64, error)
+	// Size returns the bytes the database uses, without its free pages.
+	Size() (int64, error)
+	// Vacuum gives the free pages of the database back to the file system.
+	Vacuum() error
 }
 
 // NewLogRepository returns a new account memory-based repository,
@@ -72,11 +162,24 @@ func NewLogRepository() Repository {
 		log.Fatal(err)
 	}
 
+	// Readers don't hold up the writer and commits don't wait for the whole file to sync.
+	_, err = db.Exec(`PRAGMA journal_mode=WAL`)
+	if err != nil {
+		log.Fatal(err)
+	}
 	_, err = db.Exec(_CREATE_TABLE_SQL_)
 	if err != nil {
 		log.Fatal(err)
 	}
-	return &logRepository{OrderPaging: &repositories.OrderPaging{
+	err = addColumns(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fts, err := createFullText(db)
+	if err != nil {
+		log.Printf("log full-text search is disabled: %v", err)
+	}
+	return &logRepository{fts: fts, OrderPaging: &repositories.OrderPaging{
 		MaxSize: 100,
 		CanOrderFields: map[string]repositories.Order{
 			"id":          repositories.ASC | repositories.DESC,
@@ -92,7 +195,241 @@ func NewLogRepository() Repository {
 // which manages the accounts using the memory data source (map).
 type logRepository struct {
 	*repositories.OrderPaging
-	db *sql.DB
+	db  *sql.DB
+	fts bool
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanLog scans a logs row. The fields are stored as JSON.
+func scanLog(row scanner, log *models.Log) error {
+	var fields string
+	err := row.Scan(&log.ID, &log.Name, &log.Message, &log.Level, &log.CreateTime, &fields, &log.Caller,
+		&log.TraceID, &log.SpanID)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(fields), &log.Fields)
+}
+
+// addedColumns are the columns added to the logs table after its creation, in order.
+var addedColumns = []struct {
+	name       string
+	definition string
+}{
+	{"fields", "TEXT NOT NULL DEFAULT '{}'"},
+	{"caller", "TEXT NOT NULL DEFAULT ''"},
+	{"trace_id", "TEXT NOT NULL DEFAULT ''"},
+	{"span_id", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// addColumns adds the columns missing from a logs table created by an older version.
+func addColumns(db *sql.DB) error {
+	rows, err := db.Query(_TABLE_INFO_SQL)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		err = rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk)
+		if err != nil {
+			return err
+		}
+		columns[name] = true
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	for _, column := range addedColumns {
+		if columns[column.name] {
+			continue
+		}
+		_, err = db.Exec("ALTER TABLE logs ADD COLUMN " + column.name + " " + column.definition)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createFullText indexes the messages with FTS5, which needs go-sqlite3 built with the
+// sqlite_fts5 tag. Without it, the triggers a binary with FTS5 left are dropped, since every
+// insert would fail on them, and the index is rebuilt once the database is opened with FTS5
+// again.
+func createFullText(db *sql.DB) (bool, error) {
+	var available bool
+	err := db.QueryRow(_FTS_AVAILABLE_SQL).Scan(&available)
+	if err != nil {
+		return false, err
+	}
+	if !available {
+		_, err = db.Exec(_DROP_FTS_TRIGGERS_SQL_)
+		if err != nil {
+			return false, err
+		}
+		return false, ErrFullTextUnsupported
+	}
+	var tables, triggers int
+	err = db.QueryRow(_TABLE_EXIST_SQL, "logs_fts").Scan(&tables)
+	if err != nil {
+		return false, err
+	}
+	err = db.QueryRow(_TABLE_EXIST_SQL, "logs_fts_insert").Scan(&triggers)
+	if err != nil {
+		return false, err
+	}
+	if tables == 0 {
+		_, err = db.Exec(_CREATE_FTS_TABLE_SQL_)
+		if err != nil {
+			return false, err
+		}
+	}
+	_, err = db.Exec(_CREATE_FTS_TRIGGERS_SQL_)
+	if err != nil {
+		return false, err
+	}
+	if tables == 0 || triggers == 0 {
+		_, err = db.Exec(_REBUILD_FTS_SQL)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// buildSearch returns the WHERE clause of query and its arguments.
+func (r *logRepository) buildSearch(query models.LogQuery) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+	if query.Name != "" {
+		conditions = append(conditions, "name = ?")
+		args = append(args, query.Name)
+	}
+	if query.MinLevel != nil {
+		var levels []string
+		for level := models.PanicLevel; level <= *query.MinLevel; level++ {
+			levels = append(levels, "?")
+			args = append(args, level.String())
+		}
+		conditions = append(conditions, "level IN ("+strings.Join(levels, ", ")+")")
+	}
+	if !query.From.IsZero() {
+		conditions = append(conditions, "create_time >= ?")
+		args = append(args, query.From.UTC().Format(createTimeFormat))
+	}
+	if !query.To.IsZero() {
+		conditions = append(conditions, "create_time < ?")
+		args = append(args, query.To.UTC().Format(createTimeFormat))
+	}
+	if query.TraceID != "" {
+		conditions = append(conditions, "trace_id = ?")
+		args = append(args, query.TraceID)
+	}
+	if query.Contains != "" {
+		conditions = append(conditions, `message LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(query.Contains)+"%")
+	}
+	if query.Match != "" {
+		if !r.fts {
+			return "", nil, ErrFullTextUnsupported
+		}
+		conditions = append(conditions, "id IN (SELECT rowid FROM logs_fts WHERE logs_fts MATCH ?)")
+		args = append(args, query.Match)
+	}
+	keys := make([]string, 0, len(query.Fields))
+	for key := range query.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if strings.ContainsAny(key, `"\`) {
+			return "", nil, fmt.Errorf("not a valid field name: %q", key)
+		}
+		conditions = append(conditions, "CAST(json_extract(fields, ?) AS TEXT) = ?")
+		args = append(args, `$."`+key+`"`, query.Fields[key])
+	}
+	if len(conditions) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+func (r *logRepository) Search(query models.LogQuery, page uint32, size uint32,
+	sorts []repositories.Sort) (logs []models.Log, err error) {
+	logs = make([]models.Log, 0, 50)
+	where, args, err := r.buildSearch(query)
+	if err != nil {
+		return
+	}
+	paSoStr, err := r.BuildPagingOrder(page, size, sorts)
+	if err != nil {
+		return
+	}
+	rows, err := r.db.Query(_SEARCH_SQL+where+paSoStr, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result models.Log
+		err = scanLog(rows, &result)
+		if err != nil {
+			return
+		}
+		logs = append(logs, result)
+	}
+	err = rows.Err()
+	return
+}
+
+func (r *logRepository) SearchAfter(query models.LogQuery, afterID int64,
+	limit uint32) (logs []models.Log, err error) {
+	logs = make([]models.Log, 0, limit)
+	where, args, err := r.buildSearch(query)
+	if err != nil {
+		return
+	}
+	if where == "" {
+		where = " WHERE id > ?"
+	} else {
+		where += " AND id > ?"
+	}
+	args = append(args, afterID, limit)
+	rows, err := r.db.Query(_SEARCH_SQL+where+" ORDER BY id LIMIT ?", args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result models.Log
+		err = scanLog(rows, &result)
+		if err != nil {
+			return
+		}
+		logs = append(logs, result)
+	}
+	err = rows.Err()
+	return
+}
+
+func (r *logRepository) CountSearch(query models.LogQuery) (count int64, err error) {
+	where, args, err := r.buildSearch(query)
+	if err != nil {
+		return
+	}
+	err = r.db.QueryRow(_COUNT_SEARCH_SQL+where, args...).Scan(&count)
+	return
 }
 
 func (r *logRepository) InsertLog(log *models.Log) (err error) {
@@ -111,15 +448,62 @@ func (r *logRepository) InsertLog(log *models.Log) (err error) {
 		}
 	}()
 
-	result, err := tx.Exec(_INSERT_SQL, &log.Name, &log.Message, &log.Level)
+	err = insertLog(tx, log)
 	if err != nil {
 		return
 	}
-	log.ID, err = result.LastInsertId()
 	success = true
 	return
 }
 
+func (r *logRepository) InsertLogs(logs []*models.Log) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	success := false
+	defer func() {
+		if !success {
+			if e := tx.Rollback(); e != nil {
+				err = e
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	for _, log := range logs {
+		err = insertLog(tx, log)
+		if err != nil {
+			return
+		}
+	}
+	success = true
+	return
+}
+
+// insertLog inserts log and sets its ID. It is created now if its time isn't set.
+func insertLog(tx *sql.Tx, log *models.Log) error {
+	fields, err := json.Marshal(log.Fields)
+	if err != nil {
+		return err
+	}
+	if log.Fields == nil {
+		fields = []byte("{}")
+	}
+	createTime := log.CreateTime
+	if createTime.IsZero() {
+		createTime = time.Now()
+	}
+	result, err := tx.Exec(_INSERT_SQL, &log.Name, &log.Message, &log.Level,
+		createTime.UTC().Format(createTimeFormat), string(fields), &log.Caller, &log.TraceID, &log.SpanID)
+	if err != nil {
+		return err
+	}
+	log.ID, err = result.LastInsertId()
+	return err
+}
+
 func (r *logRepository) FindLogByID(id int64) (log models.Log, err error) {
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -136,8 +520,7 @@ func (r *logRepository) FindLogByID(id int64) (log models.Log, err error) {
 		}
 	}()
 
-	err = tx.QueryRow(_SELECT_BY_ID_SQL, id).Scan(
-		&log.ID, &log.Name, &log.Message, &log.Level, &log.CreateTime)
+	err = scanLog(tx.QueryRow(_SELECT_BY_ID_SQL, id), &log)
 	if err != nil {
 		return
 	}
@@ -175,7 +558,7 @@ func (r *logRepository) FindLogByName(name string, page uint32, size uint32,
 
 	for rows.Next() {
 		var result models.Log
-		err = rows.Scan(&result.ID, &result.Name, &result.Message, &result.Level, &result.CreateTime)
+		err = scanLog(rows, &result)
 		if err != nil {
 			return
 		}
@@ -214,7 +597,7 @@ func (r *logRepository) FindAll(page uint32, size uint32, sorts []repositories.S
 
 	for rows.Next() {
 		var result models.Log
-		err = rows.Scan(&result.ID, &result.Name, &result.Message, &result.Level, &result.CreateTime)
+		err = scanLog(rows, &result)
 		if err != nil {
 			return
 		}
@@ -264,7 +647,7 @@ func (r *logRepository) CountByName(name string) (count int64, err error) {
 		}
 	}()
 
-	err = tx.QueryRow(_COUNT_BY_NAME_SQL).Scan(&count)
+	err = tx.QueryRow(_COUNT_BY_NAME_SQL, name).Scan(&count)
 	if err != nil {
 		return
 	}
@@ -311,8 +694,7 @@ func (r *logRepository) DeleteLogByID(id int64) (log models.Log, err error) {
 			err = tx.Commit()
 		}
 	}()
-	err = tx.QueryRow(_SELECT_BY_ID_SQL, id).Scan(
-		&log.ID, &log.Name, &log.Message, &log.Level, &log.CreateTime)
+	err = scanLog(tx.QueryRow(_SELECT_BY_ID_SQL, id), &log)
 	if err != nil {
 		return
 	}
@@ -349,6 +731,125 @@ func (r *logRepository) DeleteAll() (err error) {
 	return
 }
 
+func (r *logRepository) MaxIDBefore(t time.Time) (id int64, err error) {
+	err = r.db.QueryRow(_MAX_ID_BEFORE_SQL, t.UTC().Format(createTimeFormat)).Scan(&id)
+	return
+}
+
+func (r *logRepository) NthNewestID(name string, n int64) (id int64, err error) {
+	if name == "" {
+		err = r.db.QueryRow(_NTH_NEWEST_ID_SQL, n-1).Scan(&id)
+	} else {
+		err = r.db.QueryRow(_NTH_NEWEST_ID_BY_NAME_SQL, name, n-1).Scan(&id)
+	}
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return
+}
+
+func (r *logRepository) CountGroupByName() (counts map[string]int64, err error) {
+	counts = make(map[string]int64)
+	rows, err := r.db.Query(_COUNT_GROUP_BY_NAME_SQL)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var count int64
+		err = rows.Scan(&name, &count)
+		if err != nil {
+			return
+		}
+		counts[name] = count
+	}
+	err = rows.Err()
+	return
+}
+
+func (r *logRepository) FindLogsInRange(name string, fromID int64, toID int64,
+	limit uint32) (logs []models.Log, err error) {
+	logs = make([]models.Log, 0, limit)
+	var rows *sql.Rows
+	if name == "" {
+		rows, err = r.db.Query(_SELECT_RANGE_SQL, fromID, toID, limit)
+	} else {
+		rows, err = r.db.Query(_SELECT_RANGE_BY_NAME_SQL, name, fromID, toID, limit)
+	}
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result models.Log
+		err = scanLog(rows, &result)
+		if err != nil {
+			return
+		}
+		logs = append(logs, result)
+	}
+	err = rows.Err()
+	return
+}
+
+func (r *logRepository) DeleteLogsUpTo(name string, toID int64) (count int64, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	success := false
+	defer func() {
+		if !success {
+			if e := tx.Rollback(); e != nil {
+				err = e
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var result sql.Result
+	if name == "" {
+		result, err = tx.Exec(_DELETE_UP_TO_SQL, toID)
+	} else {
+		result, err = tx.Exec(_DELETE_UP_TO_BY_NAME_SQL, name, toID)
+	}
+	if err != nil {
+		return
+	}
+	count, err = result.RowsAffected()
+	if err != nil {
+		return
+	}
+	success = true
+	return
+}
+
+func (r *logRepository) Size() (int64, error) {
+	var pageCount, freelistCount, pageSize int64
+	err := r.db.QueryRow(_PAGE_COUNT_SQL).Scan(&pageCount)
+	if err != nil {
+		return 0, err
+	}
+	err = r.db.QueryRow(_FREELIST_COUNT_SQL).Scan(&freelistCount)
+	if err != nil {
+		return 0, err
+	}
+	err = r.db.QueryRow(_PAGE_SIZE_SQL).Scan(&pageSize)
+	if err != nil {
+		return 0, err
+	}
+	return (pageCount - freelistCount) * pageSize, nil
+}
+
+func (r *logRepository) Vacuum() error {
+	_, err := r.db.Exec(_VACUUM_SQL)
+	return err
+}
+
 func (r *logRepository) Close() error {
 	if r.db != nil {
 		return r.db.Close()
@@ -87,3 +87,170 @@ func TestDeleteAll(t *testing.T) {
 	}
 	t.Logf("%s", "Delete All success")
 }
+
+func TestRetention(t *testing.T) {
+	var ids []int64
+	for i, name := range []string{"retention_a", "retention_b", "retention_a", "retention_a", "retention_b"} {
+		log := &models.Log{
+			Message: fmt.Sprintf("I am log %d in TestRetention.", i),
+			Level:   models.InfoLevel,
+			Name:    name,
+		}
+		err := repo.InsertLog(log)
+		if err != nil {
+			t.Fatalf("%s(%s)", "Insert error", fmt.Sprint(err))
+		}
+		ids = append(ids, log.ID)
+	}
+	defer repo.DeleteAll()
+
+	counts, err := repo.CountGroupByName()
+	if err != nil {
+		t.Fatalf("%s(%s)", "Count group error", fmt.Sprint(err))
+	}
+	if counts["retention_a"] != 3 || counts["retention_b"] != 2 {
+		t.Fatalf("Count group error (counts %v)", counts)
+	}
+	id, err := repo.NthNewestID("retention_a", 2)
+	if err != nil || id != ids[2] {
+		t.Fatalf("Nth newest error (id %d not equal %d, %v)", id, ids[2], err)
+	}
+	id, err = repo.NthNewestID("retention_a", 4)
+	if err != nil || id != 0 {
+		t.Fatalf("Nth newest error (id %d not equal 0, %v)", id, err)
+	}
+	logs, err := repo.FindLogsInRange("retention_a", ids[0], ids[4], 100)
+	if err != nil || len(logs) != 2 || logs[0].ID != ids[2] {
+		t.Fatalf("Find range error (logs %v, %v)", logs, err)
+	}
+	deleted, err := repo.DeleteLogsUpTo("retention_a", ids[2])
+	if err != nil || deleted != 2 {
+		t.Fatalf("Delete up to error (deleted %d not equal 2, %v)", deleted, err)
+	}
+	deleted, err = repo.DeleteLogsUpTo("", ids[3])
+	if err != nil || deleted != 2 {
+		t.Fatalf("Delete up to error (deleted %d not equal 2, %v)", deleted, err)
+	}
+	id, err = repo.MaxIDBefore(time.Now().Add(time.Hour))
+	if err != nil || id != ids[4] {
+		t.Fatalf("Max id before error (id %d not equal %d, %v)", id, ids[4], err)
+	}
+	size, err := repo.Size()
+	if err != nil || size <= 0 {
+		t.Fatalf("Size error (size %d, %v)", size, err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	logs := []*models.Log{
+		{Name: "search_web", Message: "listening on :80", Level: models.InfoLevel,
+			Fields: map[string]interface{}{"port": 80, "tls": false}},
+		{Name: "search_web", Message: "request 100% failed", Level: models.ErrorLevel,
+			Fields: map[string]interface{}{"path": "/api"}},
+		{Name: "search_db", Message: "slow query", Level: models.WarnLevel},
+	}
+	for _, log := range logs {
+		err := repo.InsertLog(log)
+		if err != nil {
+			t.Fatalf("%s(%s)", "Insert error", fmt.Sprint(err))
+		}
+	}
+	defer repo.DeleteAll()
+
+	warn := models.WarnLevel
+	tests := []struct {
+		query models.LogQuery
+		count int64
+	}{
+		{models.LogQuery{}, 3},
+		{models.LogQuery{Name: "search_web"}, 2},
+		{models.LogQuery{MinLevel: &warn}, 2},
+		{models.LogQuery{Name: "search_web", MinLevel: &warn}, 1},
+		{models.LogQuery{Contains: "100%"}, 1},
+		{models.LogQuery{Contains: "_"}, 0},
+		{models.LogQuery{Fields: map[string]string{"port": "80"}}, 1},
+		{models.LogQuery{Fields: map[string]string{"path": "/api", "port": "80"}}, 0},
+		{models.LogQuery{From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Hour)}, 3},
+		{models.LogQuery{To: time.Now().Add(-time.Hour)}, 0},
+	}
+	for _, test := range tests {
+		count, err := repo.CountSearch(test.query)
+		if err != nil {
+			t.Fatalf("%s(%s)", "Count search error", fmt.Sprint(err))
+		}
+		if count != test.count {
+			t.Fatalf("Count search error (%+v count %d not equal %d)", test.query, count, test.count)
+		}
+		found, err := repo.Search(test.query, 0, 100, nil)
+		if err != nil {
+			t.Fatalf("%s(%s)", "Search error", fmt.Sprint(err))
+		}
+		if int64(len(found)) != test.count {
+			t.Fatalf("Search error (%+v len %d not equal %d)", test.query, len(found), test.count)
+		}
+	}
+
+	found, err := repo.Search(models.LogQuery{Name: "search_web", Contains: "listening"}, 0, 100, nil)
+	if err != nil || len(found) != 1 || found[0].Fields["port"] != float64(80) {
+		t.Fatalf("Search error (fields %v, %v)", found, err)
+	}
+	count, err := repo.CountSearch(models.LogQuery{Match: "slow"})
+	if err == ErrFullTextUnsupported {
+		t.Logf("%s", "Full-text search not supported")
+	} else if err != nil || count != 1 {
+		t.Fatalf("Full-text search error (count %d not equal 1, %v)", count, err)
+	}
+}
+
+func TestInsertLogs(t *testing.T) {
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	logs := []*models.Log{
+		{Name: "batch", Message: "first", Level: models.InfoLevel, CreateTime: created},
+		{Name: "batch", Message: "second", Level: models.WarnLevel},
+	}
+	err := repo.InsertLogs(logs)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Insert logs error", fmt.Sprint(err))
+	}
+	defer repo.DeleteAll()
+	if logs[0].ID == 0 || logs[1].ID != logs[0].ID+1 {
+		t.Fatalf("Insert logs error (ids %d, %d)", logs[0].ID, logs[1].ID)
+	}
+	found, err := repo.FindLogByID(logs[0].ID)
+	if err != nil || found.Message != "first" || !found.CreateTime.Equal(created) {
+		t.Fatalf("Insert logs error (%+v, %v)", found, err)
+	}
+	count, err := repo.CountByName("batch")
+	if err != nil || count != 2 {
+		t.Fatalf("Insert logs error (count %d, %v)", count, err)
+	}
+}
+
+func TestSearchAfter(t *testing.T) {
+	var logs []*models.Log
+	for i := 0; i < 5; i++ {
+		name := "after_web"
+		if i%2 == 1 {
+			name = "after_db"
+		}
+		logs = append(logs, &models.Log{Name: name, Message: fmt.Sprint(i), Level: models.InfoLevel})
+	}
+	err := repo.InsertLogs(logs)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Insert logs error", fmt.Sprint(err))
+	}
+	defer repo.DeleteAll()
+
+	found, err := repo.SearchAfter(models.LogQuery{Name: "after_web"}, 0, 2)
+	if err != nil || len(found) != 2 || found[0].Message != "0" || found[1].Message != "2" {
+		t.Fatalf("Search after error (%+v, %v)", found, err)
+	}
+	found, err = repo.SearchAfter(models.LogQuery{Name: "after_web"}, found[1].ID, 2)
+	if err != nil || len(found) != 1 || found[0].Message != "4" {
+		t.Fatalf("Search after error (%+v, %v)", found, err)
+	}
+	found, err = repo.SearchAfter(models.LogQuery{}, logs[2].ID, 100)
+	if err != nil || len(found) != 2 || found[0].ID != logs[3].ID {
+		t.Fatalf("Search after error (%+v, %v)", found, err)
+	}
+}
@@ -0,0 +1,242 @@
+package process_run
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/repositories"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+	"io"
+	"log"
+	"os"
+	"path"
+)
+
+const (
+	_CREATE_TABLE_SQL_ = `CREATE TABLE IF NOT EXISTS process_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			pid INTEGER NOT NULL,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME NOT NULL,
+			exit_code INTEGER NOT NULL,
+			signal TEXT NOT NULL,
+			core_dump BOOLEAN NOT NULL,
+			stop_by TEXT NOT NULL
+		)`
+
+	_CREATE_INDEX_SQL_ = `CREATE INDEX IF NOT EXISTS process_runs_name ON process_runs(name)`
+
+	_INSERT_SQL = `INSERT INTO process_runs(name, pid, start_time, end_time, exit_code, signal, core_dump, stop_by)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_SELECT_BY_ID_SQL = `SELECT * FROM process_runs WHERE id = ?`
+
+	_SELECT_BY_NAME_SQL = `SELECT * FROM process_runs WHERE name = ?`
+
+	_COUNT_BY_NAME_SQL = `SELECT COUNT(*) as count FROM process_runs WHERE name = ?`
+
+	_DELETE_BY_NAME_SQL = `DELETE FROM process_runs WHERE name = ?`
+)
+
+// Repository handles the basic operations of a ProcessRun entity/model.
+// It's an interface in order to be testable, i.e a memory ProcessRun repository or
+// a connected to an sql database.
+type Repository interface {
+	io.Closer
+	InsertProcessRun(run *models.ProcessRun) error
+	FindProcessRunByID(id int64) (models.ProcessRun, error)
+	FindProcessRunsByName(name string, page uint32, size uint32, sorts []repositories.Sort) ([]models.ProcessRun, error)
+	CountByName(name string) (int64, error)
+	DeleteProcessRunsByName(name string) error
+}
+
+// NewProcessRunRepository returns a new ProcessRun sqlite-based repository.
+func NewProcessRunRepository() Repository {
+	confServ := global_configuration.GetGlobalConfig()
+	dbPath := confServ.Get().Sys.DatabasePath
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		os.MkdirAll(dbPath, 0770)
+	}
+	dbFile := path.Join(dbPath, "ProcessRun.db")
+
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(_CREATE_TABLE_SQL_)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = db.Exec(_CREATE_INDEX_SQL_)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &processRunRepository{OrderPaging: &repositories.OrderPaging{
+		MaxSize: 100,
+		CanOrderFields: map[string]repositories.Order{
+			"id":         repositories.ASC | repositories.DESC,
+			"start_time": repositories.ASC | repositories.DESC,
+			"end_time":   repositories.ASC | repositories.DESC,
+			"exit_code":  repositories.ASC | repositories.DESC,
+		},
+	}, db: db}
+}
+
+type processRunRepository struct {
+	*repositories.OrderPaging
+	db *sql.DB
+}
+
+func scanProcessRun(scanner interface {
+	Scan(dest ...interface{}) error
+}, run *models.ProcessRun) error {
+	return scanner.Scan(&run.ID, &run.Name, &run.Pid, &run.StartTime, &run.EndTime, &run.ExitCode, &run.Signal,
+		&run.CoreDump, &run.StopBy)
+}
+
+func (r *processRunRepository) InsertProcessRun(run *models.ProcessRun) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	success := false
+	defer func() {
+		if !success {
+			if e := tx.Rollback(); e != nil {
+				err = e
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	result, err := tx.Exec(_INSERT_SQL, run.Name, run.Pid, run.StartTime, run.EndTime, run.ExitCode, run.Signal,
+		run.CoreDump, run.StopBy)
+	if err != nil {
+		return
+	}
+	run.ID, err = result.LastInsertId()
+	success = true
+	return
+}
+
+func (r *processRunRepository) FindProcessRunByID(id int64) (run models.ProcessRun, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	success := false
+	defer func() {
+		if !success {
+			if e := tx.Rollback(); e != nil {
+				err = e
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	err = scanProcessRun(tx.QueryRow(_SELECT_BY_ID_SQL, id), &run)
+	if err != nil {
+		return
+	}
+	success = true
+	return
+}
+
+func (r *processRunRepository) FindProcessRunsByName(name string, page uint32, size uint32,
+	sorts []repositories.Sort) (runs []models.ProcessRun, err error) {
+	runs = make([]models.ProcessRun, 0, 50)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	success := false
+	defer func() {
+		if !success {
+			if e := tx.Rollback(); e != nil {
+				err = e
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	paSoStr, err := r.BuildPagingOrder(page, size, sorts)
+	if err != nil {
+		return
+	}
+	rows, err := tx.Query(_SELECT_BY_NAME_SQL+paSoStr, name)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result models.ProcessRun
+		err = scanProcessRun(rows, &result)
+		if err != nil {
+			return
+		}
+		runs = append(runs, result)
+	}
+	success = true
+	return
+}
+
+func (r *processRunRepository) CountByName(name string) (count int64, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	success := false
+	defer func() {
+		if !success {
+			if e := tx.Rollback(); e != nil {
+				err = e
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	err = tx.QueryRow(_COUNT_BY_NAME_SQL, name).Scan(&count)
+	if err != nil {
+		return
+	}
+	success = true
+	return
+}
+
+func (r *processRunRepository) DeleteProcessRunsByName(name string) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	success := false
+	defer func() {
+		if !success {
+			if e := tx.Rollback(); e != nil {
+				err = e
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	_, err = tx.Exec(_DELETE_BY_NAME_SQL, name)
+	if err != nil {
+		return
+	}
+	success = true
+	return
+}
+
+func (r *processRunRepository) Close() error {
+	if r.db != nil {
+		return r.db.Close()
+	}
+	return nil
+}
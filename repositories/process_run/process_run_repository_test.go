@@ -0,0 +1,75 @@
+package process_run
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/repositories"
+)
+
+var repo = NewProcessRunRepository()
+
+func TestInsertAndFind(t *testing.T) {
+	run := &models.ProcessRun{
+		Name:      "process_run_test",
+		Pid:       100,
+		StartTime: time.Now().Add(-time.Minute),
+		EndTime:   time.Now(),
+		ExitCode:  -1,
+		Signal:    "SIGKILL",
+		StopBy:    "admin",
+	}
+	err := repo.InsertProcessRun(run)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Insert error", fmt.Sprint(err))
+	}
+	runRet, err := repo.FindProcessRunByID(run.ID)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Find error", fmt.Sprint(err))
+	}
+	if runRet.Signal != run.Signal || runRet.StopBy != run.StopBy || runRet.ExitCode != run.ExitCode {
+		t.Fatalf("Find error (%v not equal %v)", runRet, *run)
+	}
+	t.Logf("%s(%s)", "Find success", fmt.Sprint(runRet))
+}
+
+func TestFindByNameAndDelete(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		err := repo.InsertProcessRun(&models.ProcessRun{
+			Name:      "process_run_paging",
+			Pid:       200 + i,
+			StartTime: time.Now(),
+			EndTime:   time.Now(),
+			ExitCode:  i,
+		})
+		if err != nil {
+			t.Fatalf("%s(%s)", "Insert error", fmt.Sprint(err))
+		}
+	}
+	count, err := repo.CountByName("process_run_paging")
+	if err != nil {
+		t.Fatalf("%s(%s)", "Count error", fmt.Sprint(err))
+	}
+	if count != 3 {
+		t.Fatalf("Count error (count %d not equal 3)", count)
+	}
+	runs, err := repo.FindProcessRunsByName("process_run_paging", 0, 2, []repositories.Sort{
+		{Name: "exit_code", Order: repositories.DESC},
+	})
+	if err != nil {
+		t.Fatalf("%s(%s)", "Find by name error", fmt.Sprint(err))
+	}
+	if len(runs) != 2 || runs[0].ExitCode != 2 {
+		t.Fatalf("Find by name error (%v)", runs)
+	}
+	err = repo.DeleteProcessRunsByName("process_run_paging")
+	if err != nil {
+		t.Fatalf("%s(%s)", "Delete error", fmt.Sprint(err))
+	}
+	count, _ = repo.CountByName("process_run_paging")
+	if count != 0 {
+		t.Fatalf("Delete error (count %d not equal 0)", count)
+	}
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"github.com/zhsyourai/URCF-engine/models"
+	"net/rpc"
+)
+
+type AutoStartRPC struct {
+	client *rpc.Client
+}
+
+const AutoStartRPCName = "AutoStartRPC"
+
+func NewAutoStartRPC(address string) (*AutoStartRPC, error) {
+	client, err := rpc.DialHTTP("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &AutoStartRPC{
+		client: client,
+	}, nil
+}
+
+func (t *AutoStartRPC) List() (entries []models.AutoStart, err error) {
+	err = t.client.Call(AutoStartRPCName+".List", true, &entries)
+	return
+}
+
+func (t *AutoStartRPC) Add(as *models.AutoStart) (id int64, err error) {
+	err = t.client.Call(AutoStartRPCName+".Add", as, &id)
+	return
+}
+
+func (t *AutoStartRPC) Remove(id int64) (err error) {
+	var reply bool
+	err = t.client.Call(AutoStartRPCName+".Remove", id, &reply)
+	return
+}
+
+func (t *AutoStartRPC) Enable(id int64) (err error) {
+	var reply bool
+	err = t.client.Call(AutoStartRPCName+".Enable", id, &reply)
+	return
+}
+
+func (t *AutoStartRPC) Disable(id int64) (err error) {
+	var reply bool
+	err = t.client.Call(AutoStartRPCName+".Disable", id, &reply)
+	return
+}
+
+func (t *AutoStartRPC) StartAll() (err error) {
+	var reply bool
+	err = t.client.Call(AutoStartRPCName+".StartAll", true, &reply)
+	return
+}
+
+func (t *AutoStartRPC) EnableAll() (err error) {
+	var reply bool
+	err = t.client.Call(AutoStartRPCName+".EnableAll", true, &reply)
+	return
+}
+
+func (t *AutoStartRPC) DisableAll() (err error) {
+	var reply bool
+	err = t.client.Call(AutoStartRPCName+".DisableAll", true, &reply)
+	return
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/rpc/shared"
+	"net/rpc"
+)
+
+type LogRPC struct {
+	client *rpc.Client
+}
+
+const LogRPCName = "LogRPC"
+
+func NewLogRPC(address string) (*LogRPC, error) {
+	client, err := rpc.DialHTTP("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &LogRPC{
+		client: client,
+	}, nil
+}
+
+func (t *LogRPC) Levels() (levels shared.LogLevels, err error) {
+	err = t.client.Call(LogRPCName+".Levels", true, &levels)
+	return
+}
+
+// SetLevel sets the level of the logger name, or the default level if name is empty.
+func (t *LogRPC) SetLevel(name string, level string) (err error) {
+	var reply bool
+	err = t.client.Call(LogRPCName+".SetLevel", &shared.LogLevelParam{Name: name, Level: level}, &reply)
+	return
+}
+
+func (t *LogRPC) ResetLevel(name string) (err error) {
+	var reply bool
+	err = t.client.Call(LogRPCName+".ResetLevel", name, &reply)
+	return
+}
+
+// Tail opens a tail starting with up to the last lines logs passing filter.
+func (t *LogRPC) Tail(filter models.LogTailFilter, lines int) (reply shared.LogTailReply, err error) {
+	err = t.client.Call(LogRPCName+".Tail", &shared.LogTailParam{Filter: filter, Lines: lines}, &reply)
+	return
+}
+
+// Next waits for the next logs of a tail, returning none after a while without any.
+func (t *LogRPC) Next(session int64) (logs []models.Log, err error) {
+	err = t.client.Call(LogRPCName+".Next", session, &logs)
+	return
+}
+
+func (t *LogRPC) CloseTail(session int64) (err error) {
+	var reply bool
+	err = t.client.Call(LogRPCName+".CloseTail", session, &reply)
+	return
+}
+
+// SearchAfter returns up to limit logs query matches with an id over afterID, ordered by id.
+func (t *LogRPC) SearchAfter(query models.LogQuery, afterID int64, limit uint32) (logs []models.Log, err error) {
+	param := &shared.LogSearchAfterParam{
+		Query:   query,
+		AfterID: afterID,
+		Limit:   limit,
+	}
+	err = t.client.Call(LogRPCName+".SearchAfter", param, &logs)
+	return
+}
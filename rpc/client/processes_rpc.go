@@ -0,0 +1,94 @@
+package client
+
+import (
+	"github.com/zhsyourai/URCF-engine/rpc/shared"
+	"github.com/zhsyourai/URCF-engine/services/processes/types"
+	"net/rpc"
+)
+
+type ProcessesRPC struct {
+	client *rpc.Client
+}
+
+const ProcessesRPCName = "ProcessesRPC"
+
+func NewProcessesRPC(address string) (*ProcessesRPC, error) {
+	client, err := rpc.DialHTTP("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &ProcessesRPC{
+		client: client,
+	}, nil
+}
+
+func (t *ProcessesRPC) Attach(name string, user string, password string, mode string) (session int64, err error) {
+	param := &shared.AttachParam{
+		Name:     name,
+		User:     user,
+		Password: password,
+		Mode:     mode,
+	}
+	err = t.client.Call(ProcessesRPCName+".Attach", param, &session)
+	return
+}
+
+func (t *ProcessesRPC) Write(session int64, data []byte) (n int, err error) {
+	param := &shared.WriteParam{
+		Session: session,
+		Data:    data,
+	}
+	err = t.client.Call(ProcessesRPCName+".Write", param, &n)
+	return
+}
+
+// KeepAlive renews the lease of an attach session, see server.AttachLease.
+func (t *ProcessesRPC) KeepAlive(session int64) (err error) {
+	var reply bool
+	err = t.client.Call(ProcessesRPCName+".KeepAlive", session, &reply)
+	return
+}
+
+func (t *ProcessesRPC) Detach(session int64) (err error) {
+	var reply bool
+	err = t.client.Call(ProcessesRPCName+".Detach", session, &reply)
+	return
+}
+
+func (t *ProcessesRPC) ListGroups() (groups []*types.ProcessGroup, err error) {
+	err = t.client.Call(ProcessesRPCName+".ListGroups", true, &groups)
+	return
+}
+
+func (t *ProcessesRPC) FindGroup(group string) (result *types.ProcessGroup, err error) {
+	result = &types.ProcessGroup{}
+	err = t.client.Call(ProcessesRPCName+".FindGroup", group, result)
+	return
+}
+
+func (t *ProcessesRPC) StartGroup(group string) (err error) {
+	var reply bool
+	err = t.client.Call(ProcessesRPCName+".StartGroup", &shared.GroupParam{Group: group}, &reply)
+	return
+}
+
+func (t *ProcessesRPC) StopGroup(group string, user string, password string) (results map[string]types.StopResult, err error) {
+	param := &shared.GroupParam{
+		Group:    group,
+		User:     user,
+		Password: password,
+	}
+	err = t.client.Call(ProcessesRPCName+".StopGroup", param, &results)
+	return
+}
+
+func (t *ProcessesRPC) RestartGroup(group string, user string, password string) (err error) {
+	var reply bool
+	param := &shared.GroupParam{
+		Group:    group,
+		User:     user,
+		Password: password,
+	}
+	err = t.client.Call(ProcessesRPCName+".RestartGroup", param, &reply)
+	return
+}
@@ -1,15 +1,19 @@
 package rpc
 
 import (
+	stdContext "context"
 	log "github.com/sirupsen/logrus"
+	"github.com/zhsyourai/URCF-engine/daemon"
 	"github.com/zhsyourai/URCF-engine/rpc/server"
 	"github.com/zhsyourai/URCF-engine/services/global_configuration"
-	"net"
 	"net/http"
 	"net/rpc"
 	"strconv"
+	"time"
 )
 
+var rpcServer = &http.Server{}
+
 func StartRPCServer() (err error) {
 	confServ := global_configuration.GetGlobalConfig()
 	value := confServ.Get()
@@ -18,19 +22,33 @@ func StartRPCServer() (err error) {
 	if err != nil {
 		log.Fatal("Register Account RPC error:", err)
 	}
+	err = server.RegisterProcessesRPC()
+	if err != nil {
+		log.Fatal("Register Processes RPC error:", err)
+	}
+	err = server.RegisterAutoStartRPC()
+	if err != nil {
+		log.Fatal("Register AutoStart RPC error:", err)
+	}
+	err = server.RegisterLogRPC()
+	if err != nil {
+		log.Fatal("Register Log RPC error:", err)
+	}
 	rpc.HandleHTTP()
-	l, err := net.Listen("tcp", address)
+	l, err := daemon.Listen("rpc", "tcp", address)
 	if err != nil {
 		log.Fatal("listen error:", err)
 	}
 	log.Info("RPC listen at: ", address)
-	err = http.Serve(l, nil)
-	if err != nil {
+	err = rpcServer.Serve(l)
+	if err != nil && err != http.ErrServerClosed {
 		log.Fatal(err)
 	}
 	return
 }
 
 func StopRPCServer() (err error) {
-	return
+	ctx, cancel := stdContext.WithTimeout(stdContext.Background(), 10*time.Second)
+	defer cancel()
+	return rpcServer.Shutdown(ctx)
 }
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/processes/autostart"
+	"net/rpc"
+)
+
+type AutoStartRPC struct {
+	service autostart.Service
+}
+
+func RegisterAutoStartRPC() error {
+	err := rpc.RegisterName("AutoStartRPC", &AutoStartRPC{
+		service: autostart.GetInstance(),
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *AutoStartRPC) List(args bool, reply *[]models.AutoStart) (err error) {
+	*reply, err = t.service.List()
+	return
+}
+
+func (t *AutoStartRPC) Add(args *models.AutoStart, reply *int64) (err error) {
+	*reply, err = t.service.Add(*args)
+	return
+}
+
+func (t *AutoStartRPC) Remove(id int64, reply *bool) (err error) {
+	err = t.service.Remove(id)
+	*reply = err == nil
+	return
+}
+
+func (t *AutoStartRPC) Enable(id int64, reply *bool) (err error) {
+	err = t.service.Enable(id)
+	*reply = err == nil
+	return
+}
+
+func (t *AutoStartRPC) Disable(id int64, reply *bool) (err error) {
+	err = t.service.Disable(id)
+	*reply = err == nil
+	return
+}
+
+func (t *AutoStartRPC) StartAll(args bool, reply *bool) (err error) {
+	err = t.service.StartAll()
+	*reply = err == nil
+	return
+}
+
+func (t *AutoStartRPC) EnableAll(args bool, reply *bool) (err error) {
+	err = t.service.EnableAll()
+	*reply = err == nil
+	return
+}
+
+func (t *AutoStartRPC) DisableAll(args bool, reply *bool) (err error) {
+	err = t.service.DisableAll()
+	*reply = err == nil
+	return
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"errors"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/rpc/shared"
+	"github.com/zhsyourai/URCF-engine/services/log"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrTailNotExist = errors.New("log tail not exist")
+
+const (
+	// tailWait is how long Next waits for a log before returning none, so that the call
+	// doesn't outlive a client that went away by much.
+	tailWait = 30 * time.Second
+	// tailBatch is the most logs Next returns at once.
+	tailBatch = 100
+)
+
+// TailLease is how long a tail lives without a Next. A client gone without closing its tail
+// has it closed once its lease ran out.
+const TailLease = 2 * tailWait
+
+type tailSession struct {
+	tail     *log.Tail
+	lastSeen int64
+}
+
+func (s *tailSession) touch() {
+	atomic.StoreInt64(&s.lastSeen, time.Now().UnixNano())
+}
+
+func (s *tailSession) expired(now time.Time) bool {
+	return now.Sub(time.Unix(0, atomic.LoadInt64(&s.lastSeen))) > TailLease
+}
+
+type LogRPC struct {
+	service  log.Service
+	tails    sync.Map
+	lastTail int64
+}
+
+func RegisterLogRPC() error {
+	t := &LogRPC{
+		service: log.GetInstance(),
+	}
+	err := rpc.RegisterName("LogRPC", t)
+	if err != nil {
+		return err
+	}
+	go t.expireTails()
+	return nil
+}
+
+// expireTails closes the tails whose lease ran out.
+func (t *LogRPC) expireTails() {
+	ticker := time.NewTicker(TailLease / 3)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		t.tails.Range(func(key, value interface{}) bool {
+			if value.(*tailSession).expired(now) {
+				t.tails.Delete(key)
+				value.(*tailSession).tail.Close()
+			}
+			return true
+		})
+	}
+}
+
+func (t *LogRPC) Levels(args bool, reply *shared.LogLevels) (err error) {
+	defaultLevel, levels := t.service.Levels()
+	reply.Default = models.Level(defaultLevel).String()
+	reply.Levels = make(map[string]string, len(levels))
+	for name, level := range levels {
+		reply.Levels[name] = models.Level(level).String()
+	}
+	return
+}
+
+func (t *LogRPC) SetLevel(args *shared.LogLevelParam, reply *bool) (err error) {
+	level, err := models.ParseLevel(args.Level)
+	if err != nil {
+		return
+	}
+	if args.Name == "" {
+		err = t.service.ChangeLevel(log.Level(level))
+	} else {
+		err = t.service.SetLevel(args.Name, log.Level(level))
+	}
+	*reply = err == nil
+	return
+}
+
+func (t *LogRPC) ResetLevel(name string, reply *bool) (err error) {
+	err = t.service.ResetLevel(name)
+	*reply = err == nil
+	return
+}
+
+// Tail opens a tail, whose logs are then read with Next until CloseTail.
+func (t *LogRPC) Tail(args *shared.LogTailParam, reply *shared.LogTailReply) (err error) {
+	tail, err := t.service.Tail(args.Filter, args.Lines)
+	if err != nil {
+		return
+	}
+	reply.Session = atomic.AddInt64(&t.lastTail, 1)
+	reply.History = tail.History
+	session := &tailSession{
+		tail: tail,
+	}
+	session.touch()
+	t.tails.Store(reply.Session, session)
+	return
+}
+
+// Next waits for the next logs of a tail and renews its lease. It fails with the reason once
+// the tail is dropped.
+func (t *LogRPC) Next(session int64, reply *[]models.Log) (err error) {
+	value, ok := t.tails.Load(session)
+	if !ok {
+		return ErrTailNotExist
+	}
+	entry := value.(*tailSession)
+	entry.touch()
+	defer entry.touch()
+	tail := entry.tail
+	logs := make([]models.Log, 0)
+	timeout := time.NewTimer(tailWait)
+	defer timeout.Stop()
+	select {
+	case l, ok := <-tail.Logs():
+		if !ok {
+			t.tails.Delete(session)
+			if err = tail.Err(); err == nil {
+				err = ErrTailNotExist
+			}
+			return
+		}
+		logs = append(logs, l)
+	case <-timeout.C:
+	}
+	for len(logs) < tailBatch {
+		select {
+		case l, ok := <-tail.Logs():
+			if ok {
+				logs = append(logs, l)
+				continue
+			}
+		default:
+		}
+		break
+	}
+	*reply = logs
+	return
+}
+
+func (t *LogRPC) CloseTail(session int64, reply *bool) (err error) {
+	value, ok := t.tails.Load(session)
+	if !ok {
+		return ErrTailNotExist
+	}
+	t.tails.Delete(session)
+	value.(*tailSession).tail.Close()
+	*reply = true
+	return
+}
+
+func (t *LogRPC) SearchAfter(args *shared.LogSearchAfterParam, reply *[]models.Log) (err error) {
+	*reply, err = t.service.SearchAfter(args.Query, args.AfterID, args.Limit)
+	return
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"errors"
+	"github.com/zhsyourai/URCF-engine/rpc/shared"
+	"github.com/zhsyourai/URCF-engine/services/account"
+	"github.com/zhsyourai/URCF-engine/services/processes"
+	"github.com/zhsyourai/URCF-engine/services/processes/types"
+	"io"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrSessionNotExist = errors.New("attach session not exist")
+
+// AttachLease is how long an attach session lives without a Write or KeepAlive. A client
+// gone without detaching is detached once its lease ran out.
+const AttachLease = 30 * time.Second
+
+type attachSession struct {
+	stdIn    io.WriteCloser
+	lastSeen int64
+}
+
+func (a *attachSession) touch() {
+	atomic.StoreInt64(&a.lastSeen, time.Now().UnixNano())
+}
+
+func (a *attachSession) expired(now time.Time) bool {
+	return now.Sub(time.Unix(0, atomic.LoadInt64(&a.lastSeen))) > AttachLease
+}
+
+type ProcessesRPC struct {
+	service     processes.Service
+	accounts    account.Service
+	sessions    sync.Map
+	lastSession int64
+}
+
+func RegisterProcessesRPC() error {
+	t := &ProcessesRPC{
+		service:  processes.GetInstance(),
+		accounts: account.GetInstance(),
+	}
+	err := rpc.RegisterName("ProcessesRPC", t)
+	if err != nil {
+		return err
+	}
+	go t.expireSessions()
+	return nil
+}
+
+// expireSessions detaches the sessions whose lease ran out.
+func (t *ProcessesRPC) expireSessions() {
+	ticker := time.NewTicker(AttachLease / 3)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		t.sessions.Range(func(key, value interface{}) bool {
+			if value.(*attachSession).expired(now) {
+				t.sessions.Delete(key)
+				value.(*attachSession).stdIn.Close()
+			}
+			return true
+		})
+	}
+}
+
+// Attach opens a session writing to the stdin of a process once the credentials of the
+// operator are verified. The session must be kept alive with Write or KeepAlive.
+func (t *ProcessesRPC) Attach(args *shared.AttachParam, reply *int64) (err error) {
+	mode, err := types.ParseAttachMode(args.Mode)
+	if err != nil {
+		return
+	}
+	acc, err := t.accounts.Verify(args.User, args.Password)
+	if err != nil {
+		return
+	}
+	stdIn, err := t.service.Attach(args.Name, acc.Username+" (rpc)", mode)
+	if err != nil {
+		return
+	}
+	session := &attachSession{
+		stdIn: stdIn,
+	}
+	session.touch()
+	*reply = atomic.AddInt64(&t.lastSession, 1)
+	t.sessions.Store(*reply, session)
+	return
+}
+
+func (t *ProcessesRPC) Write(args *shared.WriteParam, reply *int) (err error) {
+	value, ok := t.sessions.Load(args.Session)
+	if !ok {
+		return ErrSessionNotExist
+	}
+	session := value.(*attachSession)
+	session.touch()
+	*reply, err = session.stdIn.Write(args.Data)
+	return
+}
+
+// KeepAlive renews the lease of an attach session.
+func (t *ProcessesRPC) KeepAlive(session int64, reply *bool) (err error) {
+	value, ok := t.sessions.Load(session)
+	if !ok {
+		return ErrSessionNotExist
+	}
+	value.(*attachSession).touch()
+	*reply = true
+	return
+}
+
+func (t *ProcessesRPC) Detach(session int64, reply *bool) (err error) {
+	value, ok := t.sessions.Load(session)
+	if !ok {
+		return ErrSessionNotExist
+	}
+	t.sessions.Delete(session)
+	*reply = true
+	return value.(*attachSession).stdIn.Close()
+}
+
+func (t *ProcessesRPC) ListGroups(args bool, reply *[]*types.ProcessGroup) (err error) {
+	*reply = t.service.ListGroups()
+	return
+}
+
+func (t *ProcessesRPC) FindGroup(group string, reply *types.ProcessGroup) (err error) {
+	result, err := t.service.FindGroup(group)
+	if err != nil {
+		return
+	}
+	*reply = *result
+	return
+}
+
+func (t *ProcessesRPC) StartGroup(args *shared.GroupParam, reply *bool) (err error) {
+	err = t.service.StartGroup(args.Group)
+	*reply = err == nil
+	return
+}
+
+// StopGroup stops the members of a group once the credentials of the operator, who is
+// recorded as the stop requester, are verified.
+func (t *ProcessesRPC) StopGroup(args *shared.GroupParam, reply *map[string]types.StopResult) (err error) {
+	acc, err := t.accounts.Verify(args.User, args.Password)
+	if err != nil {
+		return
+	}
+	*reply, err = t.service.StopGroup(args.Group, acc.Username+" (rpc)")
+	return
+}
+
+// RestartGroup restarts the members of a group once the credentials of the operator are
+// verified.
+func (t *ProcessesRPC) RestartGroup(args *shared.GroupParam, reply *bool) (err error) {
+	acc, err := t.accounts.Verify(args.User, args.Password)
+	if err != nil {
+		return
+	}
+	err = t.service.RestartGroup(args.Group, acc.Username+" (rpc)")
+	*reply = err == nil
+	return
+}
@@ -0,0 +1,32 @@
+package shared
+
+import "github.com/zhsyourai/URCF-engine/models"
+
+// LogLevelParam sets the level of the logger Name, or the default level if Name is empty.
+type LogLevelParam struct {
+	Name  string
+	Level string
+}
+
+type LogLevels struct {
+	Default string
+	Levels  map[string]string
+}
+
+// LogTailParam opens a tail starting with up to the last Lines logs.
+type LogTailParam struct {
+	Filter models.LogTailFilter
+	Lines  int
+}
+
+type LogTailReply struct {
+	Session int64
+	History []models.Log
+}
+
+// LogSearchAfterParam pages through the logs Query matches by id.
+type LogSearchAfterParam struct {
+	Query   models.LogQuery
+	AfterID int64
+	Limit   uint32
+}
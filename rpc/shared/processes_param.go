@@ -0,0 +1,19 @@
+package shared
+
+type AttachParam struct {
+	Name     string
+	User     string
+	Password string
+	Mode     string
+}
+
+type WriteParam struct {
+	Session int64
+	Data    []byte
+}
+
+type GroupParam struct {
+	Group    string
+	User     string
+	Password string
+}
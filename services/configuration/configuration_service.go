@@ -1,12 +1,13 @@
 package configuration
 
 import (
+	"database/sql"
 	"errors"
+	log "github.com/sirupsen/logrus"
 	"github.com/zhsyourai/URCF-engine/models"
 	"github.com/zhsyourai/URCF-engine/repositories"
 	"github.com/zhsyourai/URCF-engine/repositories/configuration"
 	"github.com/zhsyourai/URCF-engine/services"
-	"math"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -58,6 +59,8 @@ type Service interface {
 	ListAll(page uint32, size uint32, sort string, order string) (int64, []models.Config, error)
 	Put(key string, value interface{}) error
 	Delete(key string) (*Node, error)
+	Revision() int64
+	Watch(prefix string, revision int64) (*Watcher, error)
 }
 
 type configurationService struct {
@@ -65,6 +68,12 @@ type configurationService struct {
 	repo     configuration.Repository
 	rootNode *Node
 	syncFlag atomic.Value
+	// writeLock orders the changes, so that each one has the old value of the previous.
+	writeLock sync.Mutex
+	watchLock sync.Mutex
+	revision  int64
+	history   []models.ConfigEvent
+	watchers  map[*Watcher]struct{}
 }
 
 func (s *configurationService) Initialize(arguments ...interface{}) error {
@@ -79,50 +88,63 @@ func (s *configurationService) UnInitialize(arguments ...interface{}) error {
 	})
 }
 
+// syncPageSize is how many configs are loaded at once, the most the repository pages.
+const syncPageSize = 100
+
 func (s *configurationService) sync() error {
-	configs, err := s.repo.FindAll(0, math.MaxUint32, nil)
-	if err != nil {
-		return err
+	for page := uint32(0); ; page++ {
+		configs, err := s.repo.FindAll(page, syncPageSize, nil)
+		if err != nil {
+			return err
+		}
+		for _, conf := range configs {
+			s.load(conf)
+		}
+		if len(configs) < syncPageSize {
+			break
+		}
 	}
-	for _, conf := range configs {
-		allPath := strings.Split(conf.Key, ".")
-		parentPath := ""
-		parentNode := s.rootNode
-		var currentNode *Node
-		for i, path := range allPath {
-			currentPath := parentPath + path
-			tmp, exist := parentNode.child.Load(currentPath)
-			if exist {
-				currentNode = tmp.(*Node)
-				if i == len(allPath)-1 {
-					currentNode.Config = conf
-				}
+	s.syncFlag.Store(true)
+	return nil
+}
+
+// load puts conf in the tree, with the nodes of its parents.
+func (s *configurationService) load(conf models.Config) {
+	allPath := strings.Split(conf.Key, ".")
+	parentPath := ""
+	parentNode := s.rootNode
+	var currentNode *Node
+	for i, path := range allPath {
+		currentPath := parentPath + path
+		tmp, exist := parentNode.child.Load(currentPath)
+		if exist {
+			currentNode = tmp.(*Node)
+			if i == len(allPath)-1 {
+				currentNode.Config = conf
+			}
+		} else {
+			var currentConfig models.Config
+			if i == len(allPath)-1 {
+				currentConfig = conf
 			} else {
-				var currentConfig models.Config
-				if i == len(allPath)-1 {
-					currentConfig = conf
-				} else {
-					currentConfig = models.Config{
-						Key:        currentPath,
-						Value:      nil,
-						CreateTime: startOf2018,
-						UpdateTime: startOf2018,
-						Expires:    time.Duration(-1),
-					}
-				}
-				currentNode = &Node{
-					parent:  parentNode,
-					service: s,
-					Config:  currentConfig,
+				currentConfig = models.Config{
+					Key:        currentPath,
+					Value:      nil,
+					CreateTime: startOf2018,
+					UpdateTime: startOf2018,
+					Expires:    time.Duration(-1),
 				}
-				parentNode.child.Store(currentPath, currentNode)
 			}
-			parentPath = currentPath + "."
-			parentNode = currentNode
+			currentNode = &Node{
+				parent:  parentNode,
+				service: s,
+				Config:  currentConfig,
+			}
+			parentNode.child.Store(currentPath, currentNode)
 		}
+		parentPath = currentPath + "."
+		parentNode = currentNode
 	}
-	s.syncFlag.Store(true)
-	return nil
 }
 
 func (s *configurationService) GetRoot() (*Node, error) {
@@ -178,6 +200,8 @@ func (s *configurationService) Get(key string) (*Node, error) {
 }
 
 func (s *configurationService) Put(key string, value interface{}) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
 	allPath := strings.Split(key, ".")
 	parentPath := ""
 	parentNode := s.rootNode
@@ -189,11 +213,17 @@ func (s *configurationService) Put(key string, value interface{}) error {
 		if exist {
 			currentNode = tmp.(*Node)
 			if i == len(allPath)-1 {
+				oldValue := currentNode.Value
 				currentNode.Value = value
-				err := s.repo.InsertConfig(&currentNode.Config)
+				// The node may only be the parent of other keys, without a row yet.
+				_, err := s.repo.UpdateConfigByKey(key, map[string]interface{}{"Value": value})
+				if err == sql.ErrNoRows {
+					err = s.repo.InsertConfig(&currentNode.Config)
+				}
 				if err != nil {
 					return err
 				}
+				s.notify(models.PutEvent, key, oldValue, value)
 			}
 		} else {
 			var currentConfig models.Config
@@ -209,7 +239,7 @@ func (s *configurationService) Put(key string, value interface{}) error {
 				if err != nil {
 					return err
 				}
-
+				s.notify(models.PutEvent, key, nil, value)
 			} else {
 				currentConfig = models.Config{
 					Key:        currentPath,
@@ -233,6 +263,8 @@ func (s *configurationService) Put(key string, value interface{}) error {
 }
 
 func (s *configurationService) Delete(key string) (*Node, error) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
 	allPath := strings.Split(key, ".")
 	parentPath := ""
 	parentNode := s.rootNode
@@ -254,6 +286,7 @@ func (s *configurationService) Delete(key string) (*Node, error) {
 	if err != nil {
 		return &Node{}, err
 	}
+	s.notify(models.DeleteEvent, key, currentNode.Value, nil)
 	return currentNode, nil
 }
 
@@ -263,7 +296,8 @@ var once sync.Once
 func GetInstance() Service {
 	once.Do(func() {
 		service = &configurationService{
-			repo: configuration.NewConfigurationRepository(),
+			repo:     configuration.NewConfigurationRepository(),
+			watchers: make(map[*Watcher]struct{}),
 			rootNode: &Node{
 				parent:  nil,
 				service: service,
@@ -278,6 +312,11 @@ func GetInstance() Service {
 		}
 		service.syncFlag.Store(false)
 		service.sync()
+		revision, err := service.repo.Revision()
+		if err != nil {
+			log.Errorf("configuration revision read error: %v", err)
+		}
+		service.revision = revision
 	})
 	return service
 }
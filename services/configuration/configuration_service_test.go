@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"math/rand"
 	"testing"
+
+	"github.com/zhsyourai/URCF-engine/models"
 )
 
 func TestConfigurationService_Put(t *testing.T) {
@@ -59,3 +61,88 @@ func TestConfigurationService_Put(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestConfigurationService_Watch(t *testing.T) {
+	s := GetInstance().(*configurationService)
+
+	prefix := "test1.watch" + fmt.Sprint(rand.Int())
+	w, err := s.Watch(prefix+".", 0)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Watch error", fmt.Sprint(err))
+	}
+	defer w.Close()
+	start := s.Revision()
+	for _, value := range []string{"a", "b"} {
+		err = s.Put(prefix+".key", value)
+		if err != nil {
+			t.Fatalf("%s(%s)", "Put error", fmt.Sprint(err))
+		}
+	}
+	err = s.Put(prefix+"other", "c")
+	if err != nil {
+		t.Fatalf("%s(%s)", "Put error", fmt.Sprint(err))
+	}
+	_, err = s.Delete(prefix + ".key")
+	if err != nil {
+		t.Fatalf("%s(%s)", "Delete error", fmt.Sprint(err))
+	}
+
+	want := []models.ConfigEvent{
+		{Type: models.PutEvent, Key: prefix + ".key", NewValue: "a", Revision: start + 1},
+		{Type: models.PutEvent, Key: prefix + ".key", OldValue: "a", NewValue: "b", Revision: start + 2},
+		{Type: models.DeleteEvent, Key: prefix + ".key", OldValue: "b", Revision: start + 4},
+	}
+	for _, event := range want {
+		got := <-w.Events()
+		if got.Type != event.Type || got.Key != event.Key || got.OldValue != event.OldValue ||
+			got.NewValue != event.NewValue || got.Revision != event.Revision {
+			t.Fatalf("watch got %+v, want %+v", got, event)
+		}
+	}
+
+	resumed, err := s.Watch(prefix, start+1)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Watch error", fmt.Sprint(err))
+	}
+	defer resumed.Close()
+	for _, revision := range []int64{start + 2, start + 3, start + 4} {
+		if got := <-resumed.Events(); got.Revision != revision {
+			t.Fatalf("resumed watch got %+v, want revision %d", got, revision)
+		}
+	}
+	if _, err = s.Watch(prefix, s.Revision()+1); err != ErrRevisionCompacted {
+		t.Fatalf("future revision watch error got %v", err)
+	}
+
+	// After a restart, the revisions go on and the changes before are gone.
+	restarted := &configurationService{repo: s.repo, watchers: make(map[*Watcher]struct{})}
+	restarted.revision, err = restarted.repo.Revision()
+	if err != nil {
+		t.Fatalf("%s(%s)", "Revision error", fmt.Sprint(err))
+	}
+	if restarted.Revision() != s.Revision() {
+		t.Fatalf("restarted revision got %d, want %d", restarted.Revision(), s.Revision())
+	}
+	if _, err = restarted.Watch(prefix, start+1); err != ErrRevisionCompacted {
+		t.Fatalf("resume after restart watch error got %v", err)
+	}
+
+	slow, err := s.Watch(prefix, 0)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Watch error", fmt.Sprint(err))
+	}
+	for i := 0; i <= watchChanSize; i++ {
+		s.notify(models.PutEvent, prefix+".flood", nil, i)
+	}
+	for range slow.Events() {
+	}
+	if slow.Err() != ErrWatchTooSlow {
+		t.Fatalf("slow watch error got %v", slow.Err())
+	}
+	for i := 0; i < maxWatchHistory; i++ {
+		s.notify(models.PutEvent, prefix+".flood", nil, i)
+	}
+	if _, err = s.Watch(prefix, start+1); err != ErrRevisionCompacted {
+		t.Fatalf("compacted revision watch error got %v", err)
+	}
+}
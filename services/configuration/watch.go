@@ -0,0 +1,136 @@
+package configuration
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhsyourai/URCF-engine/models"
+)
+
+var (
+	// ErrWatchTooSlow is the error of a watch dropped because it didn't keep up with the changes.
+	ErrWatchTooSlow = errors.New("configuration watch dropped, it did not keep up with the changes")
+	// ErrRevisionCompacted is the error of a watch resuming from a revision whose following
+	// changes aren't kept anymore. The configuration must be read again.
+	ErrRevisionCompacted = errors.New("configuration revision is no longer available")
+)
+
+const (
+	watchChanSize = 256
+	// maxWatchHistory is how many of the last changes are kept for watches to resume from.
+	maxWatchHistory = 1000
+)
+
+// Watcher follows the changes of the keys starting with a prefix. Close must be called once
+// the watcher isn't needed anymore.
+type Watcher struct {
+	prefix  string
+	events  chan models.ConfigEvent
+	err     error
+	service *configurationService
+	once    sync.Once
+}
+
+// Events returns the channel of the changes, closed when the watcher is closed or dropped.
+func (w *Watcher) Events() <-chan models.ConfigEvent {
+	return w.events
+}
+
+// Err returns ErrWatchTooSlow once Events is closed if the watcher was dropped.
+func (w *Watcher) Err() error {
+	w.service.watchLock.Lock()
+	defer w.service.watchLock.Unlock()
+	return w.err
+}
+
+func (w *Watcher) Close() {
+	w.service.watchLock.Lock()
+	defer w.service.watchLock.Unlock()
+	w.close(nil)
+}
+
+// close closes the watcher, watchLock held.
+func (w *Watcher) close(err error) {
+	w.once.Do(func() {
+		delete(w.service.watchers, w)
+		w.err = err
+		close(w.events)
+	})
+}
+
+func (w *Watcher) match(event *models.ConfigEvent) bool {
+	return strings.HasPrefix(event.Key, w.prefix)
+}
+
+// Revision returns the revision of the last change. Revisions are kept across restarts, but
+// not the changes, so a watch resuming from before a restart fails with ErrRevisionCompacted.
+func (s *configurationService) Revision() int64 {
+	s.watchLock.Lock()
+	defer s.watchLock.Unlock()
+	return s.revision
+}
+
+// Watch follows the changes of the keys starting with prefix, an empty prefix following
+// them all. With a revision other than zero, the kept changes after it are sent first.
+func (s *configurationService) Watch(prefix string, revision int64) (*Watcher, error) {
+	s.watchLock.Lock()
+	defer s.watchLock.Unlock()
+	var replay []models.ConfigEvent
+	if revision != 0 && revision != s.revision {
+		if revision > s.revision || len(s.history) == 0 || revision < s.history[0].Revision-1 {
+			return nil, ErrRevisionCompacted
+		}
+		replay = s.history[len(s.history)-int(s.revision-revision):]
+	}
+
+	w := &Watcher{
+		prefix:  prefix,
+		events:  make(chan models.ConfigEvent, watchChanSize+len(replay)),
+		service: s,
+	}
+	for i := range replay {
+		if w.match(&replay[i]) {
+			w.events <- replay[i]
+		}
+	}
+	s.watchers[w] = struct{}{}
+	return w, nil
+}
+
+// notify records a change of key and sends it to the watchers. A watcher that doesn't keep
+// up is dropped instead of holding up the writes.
+func (s *configurationService) notify(eventType models.ConfigEventType, key string, oldValue,
+	newValue interface{}) {
+	s.watchLock.Lock()
+	defer s.watchLock.Unlock()
+	s.revision++
+	if err := s.repo.SaveRevision(s.revision); err != nil {
+		log.Warnf("configuration revision %d save error: %v", s.revision, err)
+	}
+	event := models.ConfigEvent{
+		Type:     eventType,
+		Key:      key,
+		OldValue: oldValue,
+		NewValue: newValue,
+		Revision: s.revision,
+		Time:     time.Now(),
+	}
+	if len(s.history) == maxWatchHistory {
+		copy(s.history, s.history[1:])
+		s.history = s.history[:maxWatchHistory-1]
+	}
+	s.history = append(s.history, event)
+	for w := range s.watchers {
+		if !w.match(&event) {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			w.close(ErrWatchTooSlow)
+		}
+	}
+}
@@ -40,9 +40,62 @@ type Sys struct {
 	PluginWebs   string `yaml:"plugin-webs"`
 }
 
+type Processes struct {
+	OutputBufferLines int    `yaml:"output-buffer-lines"`
+	CgroupParent      string `yaml:"cgroup-parent"`
+	SampleInterval    int32  `yaml:"sample-interval"`
+	SampleHistory     int    `yaml:"sample-history"`
+	ManifestPath      string `yaml:"manifest-path"`
+	ManifestInterval  int32  `yaml:"manifest-interval"`
+}
+
+// Log configures the log service. Zero disables a retention limit. Expired logs are archived
+// to gzipped JSONL files in the work path before being deleted when Archive is set.
+type Log struct {
+	// Logs over any of the retention limits are deleted, and archived first with Archive. A
+	// limit of 0 is off, all are off by default. MaxAge is in seconds.
+	MaxAge          int64 `yaml:"max-age"`
+	MaxRowsPerName  int64 `yaml:"max-rows-per-name"`
+	MaxDatabaseSize int64 `yaml:"max-database-size"`
+	// CompactInterval is how often, in seconds, the retention is applied.
+	CompactInterval int32 `yaml:"compact-interval"`
+	Archive         bool  `yaml:"archive"`
+	// Logs are written to the database in batches of up to WriteBatch, or whatever came
+	// within WriteInterval milliseconds. Logs over WriteQueue waiting are dropped.
+	WriteQueue    int   `yaml:"write-queue"`
+	WriteBatch    int   `yaml:"write-batch"`
+	WriteInterval int32 `yaml:"write-interval"`
+	// Sinks are where logs are forwarded to besides the database.
+	Sinks []LogSink `yaml:"sinks"`
+}
+
+// LogSink forwards the logs at least as severe as Level to a syslog server (Network and
+// Address), a JSON lines file (Path) or an HTTP endpoint (URL, in the loki or elasticsearch
+// Format). Logs that can't be sent are buffered on disk, up to MaxBuffer bytes, and retried.
+type LogSink struct {
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type"`
+	Level    string `yaml:"level"`
+	Network  string `yaml:"network"`
+	Address  string `yaml:"address"`
+	Facility int    `yaml:"facility"`
+	Path     string `yaml:"path"`
+	URL      string `yaml:"url"`
+	Format   string `yaml:"format"`
+	// Index is the elasticsearch index.
+	Index   string            `yaml:"index"`
+	Headers map[string]string `yaml:"headers"`
+	// BatchSize logs are sent at once, or whatever came within FlushInterval seconds.
+	BatchSize     int   `yaml:"batch-size"`
+	FlushInterval int32 `yaml:"flush-interval"`
+	MaxBuffer     int64 `yaml:"max-buffer"`
+}
+
 type GlobalConfig struct {
-	Rpc Rpc
-	Sys Sys
+	Rpc       Rpc
+	Sys       Sys
+	Processes Processes
+	Log       Log
 }
 
 type Service interface {
@@ -60,6 +113,20 @@ func GetGlobalConfig() Service {
 			only: GlobalConfig{
 				Rpc: Rpc{Port: 8228},
 				Sys: Sys{WorkPath: "./", PluginPath: "./plugin", DatabasePath: "./database"},
+				Processes: Processes{
+					OutputBufferLines: 1000,
+					CgroupParent:      "urcf.slice",
+					SampleInterval:    5,
+					SampleHistory:     60,
+					ManifestPath:      "./manifests",
+					ManifestInterval:  60,
+				},
+				Log: Log{
+					CompactInterval: 3600,
+					WriteQueue:      10000,
+					WriteBatch:      500,
+					WriteInterval:   200,
+				},
 			},
 		}
 	})
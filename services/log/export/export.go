@@ -0,0 +1,281 @@
+package export
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zhsyourai/URCF-engine/models"
+)
+
+var ErrUnknownFormat = errors.New("unknown log export format")
+
+const (
+	JSONL = "jsonl"
+	CSV   = "csv"
+	Text  = "text"
+)
+
+// ManifestName is the name of the manifest in a bundle.
+const ManifestName = "manifest.json"
+
+// Writer writes logs in an export format. Close flushes what is buffered.
+type Writer interface {
+	Write(log *models.Log) error
+	Close() error
+}
+
+// NewWriter returns a Writer of format writing to w.
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case JSONL:
+		buf := bufio.NewWriter(w)
+		return &jsonlWriter{buf: buf, enc: json.NewEncoder(buf)}, nil
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case Text:
+		return &textWriter{buf: bufio.NewWriter(w)}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// Extension returns the file extension of format.
+func Extension(format string) string {
+	if format == Text {
+		return "log"
+	}
+	return format
+}
+
+type jsonlWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w *jsonlWriter) Write(log *models.Log) error {
+	return w.enc.Encode(log)
+}
+
+func (w *jsonlWriter) Close() error {
+	return w.buf.Flush()
+}
+
+var csvHeader = []string{"id", "create_time", "name", "level", "message", "caller", "trace_id", "span_id",
+	"fields"}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (w *csvWriter) Write(log *models.Log) error {
+	if !w.header {
+		w.header = true
+		err := w.w.Write(csvHeader)
+		if err != nil {
+			return err
+		}
+	}
+	fields := ""
+	if len(log.Fields) > 0 {
+		content, err := json.Marshal(log.Fields)
+		if err != nil {
+			return err
+		}
+		fields = string(content)
+	}
+	return w.w.Write([]string{strconv.FormatInt(log.ID, 10), log.CreateTime.Format(time.RFC3339),
+		log.Name, log.Level.String(), log.Message, log.Caller, log.TraceID, log.SpanID, fields})
+}
+
+func (w *csvWriter) Close() error {
+	// An empty export still tells its columns.
+	if !w.header {
+		w.header = true
+		w.w.Write(csvHeader)
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type textWriter struct {
+	buf *bufio.Writer
+}
+
+func (w *textWriter) Write(log *models.Log) error {
+	fields := make([]string, 0, len(log.Fields))
+	for k, v := range log.Fields {
+		fields = append(fields, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(fields)
+	line := fmt.Sprintf("%s %-7s %s: %s", log.CreateTime.Format(time.RFC3339),
+		strings.ToUpper(log.Level.String()), log.Name, log.Message)
+	if len(fields) > 0 {
+		line += " " + strings.Join(fields, " ")
+	}
+	_, err := w.buf.WriteString(line + "\n")
+	return err
+}
+
+func (w *textWriter) Close() error {
+	return w.buf.Flush()
+}
+
+// Source calls fn for every log query matches, oldest first, stopping at the first error.
+type Source func(query models.LogQuery, fn func(log *models.Log) error) error
+
+// Export writes the logs of source query matches to w in format, and returns how many.
+func Export(w io.Writer, format string, query models.LogQuery, source Source) (count int64, err error) {
+	writer, err := NewWriter(w, format)
+	if err != nil {
+		return 0, err
+	}
+	err = source(query, func(log *models.Log) error {
+		count++
+		return writer.Write(log)
+	})
+	if e := writer.Close(); err == nil {
+		err = e
+	}
+	return
+}
+
+// Manifest describes the files of a bundle.
+type Manifest struct {
+	Created time.Time       `json:"created"`
+	Format  string          `json:"format"`
+	Query   models.LogQuery `json:"query"`
+	Files   []ManifestFile  `json:"files"`
+}
+
+type ManifestFile struct {
+	Name  string `json:"name"`
+	File  string `json:"file"`
+	Count int64  `json:"count"`
+}
+
+// Bundle writes a tar.gz to w holding a manifest then a file of the logs of each name query
+// matches, in format. Names are exported once, each file is suffixed with its index since
+// names may hold any character.
+func Bundle(w io.Writer, format string, query models.LogQuery, names []string, source Source) error {
+	_, err := NewWriter(ioutil.Discard, format)
+	if err != nil {
+		return err
+	}
+	manifest := Manifest{
+		Created: time.Now(),
+		Format:  format,
+		Query:   query,
+		Files:   make([]ManifestFile, 0, len(names)),
+	}
+	// The size of a file goes before it in the tar, so the logs are exported to temporary
+	// files first.
+	files := make([]*os.File, 0, len(names))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		f, err := ioutil.TempFile("", "urcf-export")
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+		q := query
+		q.Name = name
+		count, err := Export(f, format, q, source)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, ManifestFile{
+			Name:  name,
+			File:  fmt.Sprintf("%s-%d.%s", strings.Replace(name, "/", "_", -1), len(files)-1, Extension(format)),
+			Count: count,
+		})
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	content, err := json.MarshalIndent(&manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{Name: ManifestName, Mode: 0644, Size: int64(len(content)),
+		ModTime: manifest.Created})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(content)
+	if err != nil {
+		return err
+	}
+	for i, f := range files {
+		size, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		_, err = f.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		err = tw.WriteHeader(&tar.Header{Name: manifest.Files[i].File, Mode: 0644, Size: size,
+			ModTime: manifest.Created})
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		if err != nil {
+			return err
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// pageSize is how many logs a paged source fetches at once.
+const pageSize = 1000
+
+// Paged returns a Source fetching the logs by pages with fetch, which returns up to limit
+// logs query matches with an id over afterID, ordered by id.
+func Paged(fetch func(query models.LogQuery, afterID int64, limit uint32) ([]models.Log, error)) Source {
+	return func(query models.LogQuery, fn func(log *models.Log) error) error {
+		var afterID int64
+		for {
+			logs, err := fetch(query, afterID, pageSize)
+			if err != nil {
+				return err
+			}
+			for i := range logs {
+				err = fn(&logs[i])
+				if err != nil {
+					return err
+				}
+			}
+			if len(logs) < pageSize {
+				return nil
+			}
+			afterID = logs[len(logs)-1].ID
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package export
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhsyourai/URCF-engine/models"
+)
+
+var testLogs = []models.Log{
+	{ID: 1, Name: "web", Message: "started", Level: models.InfoLevel, CreateTime: time.Unix(1500000000, 0).UTC()},
+	{ID: 2, Name: "db", Message: "slow, query", Level: models.WarnLevel, CreateTime: time.Unix(1500000001, 0).UTC(),
+		Fields: map[string]interface{}{"ms": 300.0}},
+	{ID: 3, Name: "web", Message: "failed", Level: models.ErrorLevel, CreateTime: time.Unix(1500000002, 0).UTC()},
+}
+
+// source serves testLogs by name, as a repository would.
+func source(query models.LogQuery, fn func(log *models.Log) error) error {
+	for i := range testLogs {
+		if query.Name != "" && testLogs[i].Name != query.Name {
+			continue
+		}
+		err := fn(&testLogs[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExport(t *testing.T) {
+	var buf bytes.Buffer
+	count, err := Export(&buf, JSONL, models.LogQuery{}, source)
+	if err != nil || count != 3 {
+		t.Fatalf("jsonl export got %d (%v)", count, err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var log models.Log
+	if len(lines) != 3 || json.Unmarshal([]byte(lines[1]), &log) != nil || log.Message != "slow, query" {
+		t.Fatalf("jsonl export got %q", buf.String())
+	}
+
+	buf.Reset()
+	_, err = Export(&buf, CSV, models.LogQuery{Name: "db"}, source)
+	if err != nil {
+		t.Fatalf("csv export error(%v)", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(records) != 2 || records[0][0] != "id" || records[1][4] != "slow, query" ||
+		records[1][3] != "warning" || records[1][8] != `{"ms":300}` {
+		t.Fatalf("csv export got %v (%v)", records, err)
+	}
+
+	buf.Reset()
+	_, err = Export(&buf, Text, models.LogQuery{Name: "web"}, source)
+	if err != nil {
+		t.Fatalf("text export error(%v)", err)
+	}
+	if buf.String() != "2017-07-14T02:40:00Z INFO    web: started\n2017-07-14T02:40:02Z ERROR   web: failed\n" {
+		t.Fatalf("text export got %q", buf.String())
+	}
+
+	if _, err = Export(&buf, "xml", models.LogQuery{}, source); err != ErrUnknownFormat {
+		t.Fatalf("xml export error got %v", err)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	var buf bytes.Buffer
+	err := Bundle(&buf, JSONL, models.LogQuery{}, []string{"web", "db", "web", "none", "no/ne", "no_ne"}, source)
+	if err != nil {
+		t.Fatalf("Bundle error(%v)", err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip error(%v)", err)
+	}
+	tr := tar.NewReader(gz)
+	files := make(map[string]string)
+	var order []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar error(%v)", err)
+		}
+		content, _ := ioutil.ReadAll(tr)
+		files[header.Name] = string(content)
+		order = append(order, header.Name)
+	}
+	if strings.Join(order, ",") != ManifestName+",web-0.jsonl,db-1.jsonl,none-2.jsonl,no_ne-3.jsonl,no_ne-4.jsonl" {
+		t.Fatalf("bundle files got %v", order)
+	}
+	var manifest Manifest
+	err = json.Unmarshal([]byte(files[ManifestName]), &manifest)
+	if err != nil || manifest.Format != JSONL || len(manifest.Files) != 5 || manifest.Files[0].Count != 2 ||
+		manifest.Files[1].Count != 1 || manifest.Files[2].Count != 0 {
+		t.Fatalf("manifest got %+v (%v)", manifest, err)
+	}
+	if strings.Count(files["web-0.jsonl"], "\n") != 2 || files["none-2.jsonl"] != "" {
+		t.Fatalf("bundle content got %v", files)
+	}
+}
+
+func TestPaged(t *testing.T) {
+	var all []models.Log
+	for i := int64(1); i <= pageSize+5; i++ {
+		all = append(all, models.Log{ID: i * 2})
+	}
+	var calls int
+	paged := Paged(func(query models.LogQuery, afterID int64, limit uint32) ([]models.Log, error) {
+		calls++
+		var logs []models.Log
+		for _, log := range all {
+			if log.ID > afterID && len(logs) < int(limit) {
+				logs = append(logs, log)
+			}
+		}
+		return logs, nil
+	})
+	var ids []int64
+	err := paged(models.LogQuery{}, func(log *models.Log) error {
+		ids = append(ids, log.ID)
+		return nil
+	})
+	if err != nil || len(ids) != len(all) || ids[len(ids)-1] != all[len(all)-1].ID || calls != 2 {
+		t.Fatalf("paged got %d logs in %d calls (%v)", len(ids), calls, err)
+	}
+}
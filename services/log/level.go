@@ -0,0 +1,128 @@
+package log
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/configuration"
+)
+
+// The levels are kept in the configuration, the default one under levelKey and the one of
+// each logger under levelsKey followed by its name.
+const (
+	levelKey  = "log.level"
+	levelsKey = "log.levels"
+)
+
+// levelOf returns the level of the logger name, levelLock held.
+func (s *logService) levelOf(name string) Level {
+	if level, ok := s.levels[name]; ok {
+		return level
+	}
+	return s.level
+}
+
+// applyLevels sets the level of every logger made so far, levelLock held.
+func (s *logService) applyLevels() {
+	for name, logger := range s.loggers {
+		logger.SetLevel(logrus.Level(s.levelOf(name)))
+	}
+}
+
+func (s *logService) ChangeLevel(level Level) error {
+	err := s.conf.Put(levelKey, models.Level(level).String())
+	if err != nil {
+		return err
+	}
+	s.levelLock.Lock()
+	defer s.levelLock.Unlock()
+	s.level = level
+	s.applyLevels()
+	return nil
+}
+
+func (s *logService) SetLevel(name string, level Level) error {
+	err := s.conf.Put(levelsKey+"."+name, models.Level(level).String())
+	if err != nil {
+		return err
+	}
+	s.levelLock.Lock()
+	defer s.levelLock.Unlock()
+	s.levels[name] = level
+	s.applyLevels()
+	return nil
+}
+
+func (s *logService) ResetLevel(name string) error {
+	s.levelLock.Lock()
+	defer s.levelLock.Unlock()
+	if _, ok := s.levels[name]; !ok {
+		return nil
+	}
+	_, err := s.conf.Delete(levelsKey + "." + name)
+	if err != nil {
+		return err
+	}
+	delete(s.levels, name)
+	s.applyLevels()
+	return nil
+}
+
+func (s *logService) Levels() (Level, map[string]Level) {
+	s.levelLock.Lock()
+	defer s.levelLock.Unlock()
+	levels := make(map[string]Level, len(s.levels))
+	for name, level := range s.levels {
+		levels[name] = level
+	}
+	return s.level, levels
+}
+
+func parseConfigLevel(value interface{}) (Level, error) {
+	var str string
+	switch v := value.(type) {
+	case string:
+		str = v
+	case []byte:
+		str = string(v)
+	}
+	level, err := models.ParseLevel(str)
+	return Level(level), err
+}
+
+// loadLevels reads the levels kept in the configuration.
+func (s *logService) loadLevels() {
+	s.levelLock.Lock()
+	defer s.levelLock.Unlock()
+	if node, err := s.conf.Get(levelKey); err == nil && node.Value != nil {
+		level, err := parseConfigLevel(node.Value)
+		if err != nil {
+			logrus.Warnf("log level %s error: %v", levelKey, err)
+		} else {
+			s.level = level
+		}
+	}
+	root, err := s.conf.Get(levelsKey)
+	if err != nil {
+		return
+	}
+	// Names may hold dots, so the levels may be anywhere below the root.
+	var walk func(node *configuration.Node)
+	walk = func(node *configuration.Node) {
+		nodes, _ := node.GetAll()
+		for _, node := range nodes {
+			if node.Value != nil {
+				level, err := parseConfigLevel(node.Value)
+				if err != nil {
+					logrus.Warnf("log level %s error: %v", node.Key, err)
+				} else {
+					s.levels[strings.TrimPrefix(node.Key, levelsKey+".")] = level
+				}
+			}
+			walk(node)
+		}
+	}
+	walk(root)
+	s.applyLevels()
+}
@@ -3,19 +3,29 @@ package log
 import (
 	"bufio"
 	"encoding/json"
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"github.com/zhsyourai/URCF-engine/config"
 	"github.com/zhsyourai/URCF-engine/models"
 	"github.com/zhsyourai/URCF-engine/repositories"
 	"github.com/zhsyourai/URCF-engine/repositories/log"
 	"github.com/zhsyourai/URCF-engine/services"
+	"github.com/zhsyourai/URCF-engine/services/configuration"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+	"github.com/zhsyourai/URCF-engine/services/log/export"
+	"github.com/zhsyourai/URCF-engine/services/log/sink"
 	"io"
+	"path"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 )
 
+// ErrFullTextUnsupported is returned by searches matching messages when SQLite lacks FTS5.
+var ErrFullTextUnsupported = log.ErrFullTextUnsupported
+
 type Level uint32
 
 const (
@@ -32,8 +42,29 @@ type Service interface {
 	GetLogger(name string) (*logrus.Entry, error)
 	WarpReader(name string, r io.Reader) error
 	ListAll(page uint32, size uint32, sort string, order string) (int64, []models.Log, error)
+	Search(query models.LogQuery, page uint32, size uint32, sort string, order string) (int64, []models.Log, error)
+	// SearchAfter returns up to limit logs query matches with an id over afterID, ordered by id.
+	SearchAfter(query models.LogQuery, afterID int64, limit uint32) ([]models.Log, error)
+	// Export writes the logs query matches to w as jsonl, csv or text, and returns how many.
+	Export(w io.Writer, format string, query models.LogQuery) (int64, error)
+	// ExportBundle writes a tar.gz to w with a manifest and a file of the logs of each name.
+	ExportBundle(w io.Writer, format string, query models.LogQuery, names []string) error
 	Clean(ids ...int64) error
+	// Tail follows the logs inserted from now on passing filter, starting with up to the last
+	// ones inserted before.
+	Tail(filter models.LogTailFilter, last int) (*Tail, error)
+	// ChangeLevel sets the level of the loggers without a level of their own.
 	ChangeLevel(level Level) error
+	// SetLevel sets the level of the logger name, also applied to the lines of WarpReader.
+	SetLevel(name string, level Level) error
+	// ResetLevel makes the logger name follow the default level again.
+	ResetLevel(name string) error
+	// Levels returns the default level and the levels set per logger name.
+	Levels() (Level, map[string]Level)
+	// Stats tells how many logs were written and lost.
+	Stats() Stats
+	// Compact applies the retention limits of the configuration, it also runs periodically.
+	Compact() (Compaction, error)
 }
 
 var instance *logService
@@ -42,8 +73,15 @@ var once sync.Once
 func GetInstance() Service {
 	once.Do(func() {
 		instance = &logService{
-			level: InfoLevel,
-			repo:  log.NewLogRepository(),
+			level:   InfoLevel,
+			levels:  make(map[string]Level),
+			loggers: make(map[string]*logrus.Logger),
+			tails:   make(map[*Tail]struct{}),
+			repo:    log.NewLogRepository(),
+			conf:    configuration.GetInstance(),
+		}
+		if !config.PROD {
+			instance.level = DebugLevel
 		}
 	})
 	return instance
@@ -51,8 +89,24 @@ func GetInstance() Service {
 
 type logService struct {
 	services.InitHelper
-	level Level
-	repo  log.Repository
+	levelLock      sync.Mutex
+	level          Level
+	levels         map[string]Level
+	loggers        map[string]*logrus.Logger
+	repo           log.Repository
+	conf           configuration.Service
+	compactLock    sync.Mutex
+	stopCompaction chan struct{}
+	sinkLock       sync.RWMutex
+	sinks          []*sink.Forwarder
+	tailLock       sync.Mutex
+	tails          map[*Tail]struct{}
+	writerLock     sync.RWMutex
+	queue          chan *models.Log
+	writerDone     chan struct{}
+	written        uint64
+	dropped        uint64
+	failed         uint64
 }
 
 type logWriter struct{ *logService }
@@ -77,48 +131,122 @@ func (l *logWriter) Write(p []byte) (int, error) {
 	if err != nil {
 		return 0, nil
 	}
-	err = l.repo.InsertLog(&models.Log{
+	log := &models.Log{
 		Name:       entry["name"].(string),
 		Message:    entry["msg"].(string),
 		CreateTime: parseTime,
 		Level:      level,
-	})
+		Fields:     make(map[string]interface{}),
+	}
+	for k, v := range entry {
+		switch k {
+		case "name", "msg", "level", "time":
+		case "caller":
+			log.Caller = fmt.Sprint(v)
+		case "trace_id":
+			log.TraceID = fmt.Sprint(v)
+		case "span_id":
+			log.SpanID = fmt.Sprint(v)
+		default:
+			log.Fields[k] = v
+		}
+	}
+	l.forward(log)
+	if l.enqueue(log) {
+		return len(p), nil
+	}
+	err = l.repo.InsertLog(log)
 	if err != nil {
+		atomic.AddUint64(&l.failed, 1)
 		return 0, nil
 	}
+	atomic.AddUint64(&l.written, 1)
+	l.broadcast(log)
 	return len(p), nil
 }
 
+// forward hands log to the sinks, which copy it.
+func (s *logService) forward(log *models.Log) {
+	s.sinkLock.RLock()
+	defer s.sinkLock.RUnlock()
+	for _, f := range s.sinks {
+		f.Forward(*log)
+	}
+}
+
+// openSinks starts forwarding to the sinks of the configuration. A sink that can't be
+// created is left out.
+func (s *logService) openSinks() {
+	conf := global_configuration.GetGlobalConfig().Get()
+	s.sinkLock.Lock()
+	defer s.sinkLock.Unlock()
+	for i, sinkConf := range conf.Log.Sinks {
+		if sinkConf.Name == "" {
+			sinkConf.Name = fmt.Sprintf("%s-%d", sinkConf.Type, i)
+		}
+		spool := path.Join(conf.Sys.WorkPath, "log-sinks", sinkConf.Name)
+		f, err := sink.NewForwarder(sinkConf, spool)
+		if err != nil {
+			logrus.Errorf("log sink %s error: %v", sinkConf.Name, err)
+			continue
+		}
+		s.sinks = append(s.sinks, f)
+	}
+}
+
+func (s *logService) closeSinks() {
+	s.sinkLock.Lock()
+	sinks := s.sinks
+	s.sinks = nil
+	s.sinkLock.Unlock()
+	for _, f := range sinks {
+		err := f.Close()
+		if err != nil {
+			logrus.Warnf("log sink close error: %v", err)
+		}
+	}
+}
+
 func (s *logService) Initialize(arguments ...interface{}) error {
 	return s.CallInitialize(func() error {
+		conf := global_configuration.GetGlobalConfig().Get().Log
+		s.startWriter(conf)
+		interval := conf.CompactInterval
+		if interval > 0 {
+			s.stopCompaction = make(chan struct{})
+			go s.runCompaction(time.Second*time.Duration(interval), s.stopCompaction)
+		}
+		s.loadLevels()
+		s.openSinks()
 		return nil
 	})
 }
 
 func (s *logService) UnInitialize(arguments ...interface{}) error {
 	return s.CallUnInitialize(func() error {
+		if s.stopCompaction != nil {
+			close(s.stopCompaction)
+			s.stopCompaction = nil
+		}
+		s.closeSinks()
+		s.stopWriter()
 		return nil
 	})
 }
 
-func (s *logService) ChangeLevel(level Level) error {
-	s.level = level
-	return nil
-}
-
+// GetLogger returns the logger of name, at the level set for it.
 func (s *logService) GetLogger(name string) (*logrus.Entry, error) {
-	logger := &logrus.Logger{
-		Formatter: new(logrus.JSONFormatter),
-		Hooks:     make(logrus.LevelHooks),
-		Level:     logrus.Level(s.level),
-	}
-	if !config.PROD {
-		logger.SetLevel(logrus.DebugLevel)
-	} else {
-		logger.SetLevel(logrus.InfoLevel)
-	}
-	logger.Out = &logWriter{
-		s,
+	s.levelLock.Lock()
+	defer s.levelLock.Unlock()
+	logger, ok := s.loggers[name]
+	if !ok {
+		logger = &logrus.Logger{
+			Formatter: new(logrus.JSONFormatter),
+			Hooks:     make(logrus.LevelHooks),
+			Level:     logrus.Level(s.levelOf(name)),
+			Out:       &logWriter{s},
+		}
+		s.loggers[name] = logger
 	}
 	return logger.WithField("name", name), nil
 }
@@ -153,6 +281,44 @@ func (s *logService) ListAll(page uint32, size uint32, sort string, order string
 	return
 }
 
+func (s *logService) Search(query models.LogQuery, page uint32, size uint32, sort string,
+	order string) (total int64, logs []models.Log, err error) {
+	total, err = s.repo.CountSearch(query)
+	if err != nil {
+		return 0, []models.Log{}, err
+	}
+	var sorts []repositories.Sort
+	if sort != "" {
+		o, err := repositories.ParseOrder(order)
+		if err != nil {
+			return 0, []models.Log{}, err
+		}
+		sorts = []repositories.Sort{
+			{
+				Name:  sort,
+				Order: o,
+			},
+		}
+	}
+	logs, err = s.repo.Search(query, page, size, sorts)
+	if err != nil {
+		return 0, []models.Log{}, err
+	}
+	return
+}
+
+func (s *logService) SearchAfter(query models.LogQuery, afterID int64, limit uint32) ([]models.Log, error) {
+	return s.repo.SearchAfter(query, afterID, limit)
+}
+
+func (s *logService) Export(w io.Writer, format string, query models.LogQuery) (int64, error) {
+	return export.Export(w, format, query, export.Paged(s.repo.SearchAfter))
+}
+
+func (s *logService) ExportBundle(w io.Writer, format string, query models.LogQuery, names []string) error {
+	return export.Bundle(w, format, query, names, export.Paged(s.repo.SearchAfter))
+}
+
 func (s *logService) Clean(ids ...int64) error {
 	if len(ids) == 0 {
 		return s.repo.DeleteAll()
@@ -213,9 +379,7 @@ func (s *logService) WarpReader(name string, r io.Reader) error {
 				if err != nil {
 					logger.Info(line)
 				} else {
-					for k, v := range kvPairs {
-						logger = logger.WithField(k, v)
-					}
+					logger := logger.WithFields(kvPairs)
 					level, err := logrus.ParseLevel(entry.Level)
 					if err != nil {
 						logger.Debug(line)
@@ -1 +1,181 @@
 package log
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+)
+
+func TestLevels(t *testing.T) {
+	s := GetInstance().(*logService)
+	defaultLevel, _ := s.Levels()
+	entry, err := s.GetLogger("web")
+	if err != nil {
+		t.Fatalf("%s(%s)", "GetLogger error", fmt.Sprint(err))
+	}
+	if entry.Logger.GetLevel() != logrus.Level(defaultLevel) {
+		t.Fatalf("logger level got %v", entry.Logger.GetLevel())
+	}
+
+	for _, level := range []Level{ErrorLevel, WarnLevel} {
+		err = s.SetLevel("web", level)
+		if err != nil {
+			t.Fatalf("%s(%s)", "SetLevel error", fmt.Sprint(err))
+		}
+	}
+	err = s.SetLevel("plugin.noisy", ErrorLevel)
+	if err != nil {
+		t.Fatalf("%s(%s)", "SetLevel error", fmt.Sprint(err))
+	}
+	if entry.Logger.GetLevel() != logrus.WarnLevel {
+		t.Fatalf("logger level got %v", entry.Logger.GetLevel())
+	}
+	_, levels := s.Levels()
+	if len(levels) != 2 || levels["web"] != WarnLevel || levels["plugin.noisy"] != ErrorLevel {
+		t.Fatalf("levels got %v", levels)
+	}
+
+	loaded := &logService{levels: make(map[string]Level), loggers: make(map[string]*logrus.Logger), conf: s.conf}
+	loaded.loadLevels()
+	if _, levels = loaded.Levels(); len(levels) != 2 || levels["web"] != WarnLevel ||
+		levels["plugin.noisy"] != ErrorLevel {
+		t.Fatalf("loaded levels got %v", levels)
+	}
+
+	for _, name := range []string{"web", "plugin.noisy"} {
+		err = s.ResetLevel(name)
+		if err != nil {
+			t.Fatalf("%s(%s)", "ResetLevel error", fmt.Sprint(err))
+		}
+	}
+	if entry.Logger.GetLevel() != logrus.Level(defaultLevel) {
+		t.Fatalf("reset logger level got %v", entry.Logger.GetLevel())
+	}
+	if _, levels = s.Levels(); len(levels) != 0 {
+		t.Fatalf("reset levels got %v", levels)
+	}
+}
+
+func TestTail(t *testing.T) {
+	s := GetInstance().(*logService)
+	err := s.Clean()
+	if err != nil {
+		t.Fatalf("%s(%s)", "Clean error", fmt.Sprint(err))
+	}
+	logger, err := s.GetLogger("tail")
+	if err != nil {
+		t.Fatalf("%s(%s)", "GetLogger error", fmt.Sprint(err))
+	}
+	logger.Warn("disk full")
+	logger.Warn("cpu hot")
+	logger.Info("disk fine")
+
+	if _, err = s.Tail(models.LogTailFilter{Pattern: "("}, 0); err == nil {
+		t.Fatalf("%s", "Tail with a bad pattern should fail")
+	}
+	level := models.WarnLevel
+	tail, err := s.Tail(models.LogTailFilter{Name: "tail", MinLevel: &level, Pattern: "^disk"}, 10)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Tail error", fmt.Sprint(err))
+	}
+	defer tail.Close()
+	if len(tail.History) != 1 || tail.History[0].Message != "disk full" {
+		t.Fatalf("tail history got %+v", tail.History)
+	}
+	logger.Info("disk ok")
+	logger.Warn("cpu still hot")
+	logger.Error("disk failed")
+	select {
+	case log := <-tail.Logs():
+		if log.Message != "disk failed" {
+			t.Fatalf("tail got %+v", log)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s", "tail got nothing")
+	}
+
+	slow, err := s.Tail(models.LogTailFilter{}, 0)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Tail error", fmt.Sprint(err))
+	}
+	for i := 0; i <= tailChanSize; i++ {
+		s.broadcast(&models.Log{ID: int64(1000000 + i), Name: "tail", Message: "flood"})
+	}
+	for range slow.Logs() {
+	}
+	if slow.Err() != ErrTailTooSlow {
+		t.Fatalf("slow tail error got %v", slow.Err())
+	}
+	select {
+	case log := <-tail.Logs():
+		t.Fatalf("filtered tail got %+v", log)
+	default:
+	}
+}
+
+func TestTailDropUpTo(t *testing.T) {
+	tail := &Tail{logs: make(chan models.Log, tailChanSize)}
+	for id := int64(1); id <= 4; id++ {
+		tail.logs <- models.Log{ID: id}
+	}
+	tail.dropUpTo(2)
+	if len(tail.logs) != 2 || (<-tail.logs).ID != 3 || (<-tail.logs).ID != 4 {
+		t.Fatalf("%s", "dropUpTo error (history logs kept or live logs lost)")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	s := GetInstance().(*logService)
+	err := s.Clean()
+	if err != nil {
+		t.Fatalf("%s(%s)", "Clean error", fmt.Sprint(err))
+	}
+	before := s.Stats()
+	tail, err := s.Tail(models.LogTailFilter{Name: "writer"}, 0)
+	if err != nil {
+		t.Fatalf("%s(%s)", "Tail error", fmt.Sprint(err))
+	}
+	defer tail.Close()
+
+	s.startWriter(global_configuration.Log{WriteQueue: 100, WriteBatch: 10, WriteInterval: 10})
+	logger, err := s.GetLogger("writer")
+	if err != nil {
+		t.Fatalf("%s(%s)", "GetLogger error", fmt.Sprint(err))
+	}
+	for i := 0; i < 25; i++ {
+		logger.Infof("line %d", i)
+	}
+	s.stopWriter()
+
+	count, err := s.repo.CountByName("writer")
+	if err != nil || count != 25 {
+		t.Fatalf("written logs got %d (%v)", count, err)
+	}
+	if stats := s.Stats(); stats.Written-before.Written != 25 || stats.Dropped != before.Dropped {
+		t.Fatalf("stats got %+v", stats)
+	}
+	for i := 0; i < 25; i++ {
+		log := <-tail.Logs()
+		if log.Message != fmt.Sprintf("line %d", i) || log.ID == 0 {
+			t.Fatalf("tail got %+v", log)
+		}
+	}
+
+	// A writer that doesn't keep up drops what doesn't fit in its queue.
+	s.writerLock.Lock()
+	s.queue = make(chan *models.Log, 1)
+	s.writerLock.Unlock()
+	for i := 0; i < 3; i++ {
+		logger.Info("dropped")
+	}
+	s.writerLock.Lock()
+	s.queue = nil
+	s.writerLock.Unlock()
+	if stats := s.Stats(); stats.Dropped-before.Dropped != 2 {
+		t.Fatalf("dropped stats got %+v", stats)
+	}
+}
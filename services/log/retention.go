@@ -0,0 +1,218 @@
+package log
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+)
+
+// archiveBatch is how many logs are read at once while archiving.
+const archiveBatch = 1000
+
+// Compaction tells how many logs a compaction deleted for each retention limit.
+type Compaction struct {
+	Expired int64 `json:"expired"`
+	Trimmed int64 `json:"trimmed"`
+	Shrunk  int64 `json:"shrunk"`
+	// Archive is the file the deleted logs were archived to, if any.
+	Archive string `json:"archive,omitempty"`
+}
+
+// archive writes logs as gzipped JSON lines to a file created on the first write.
+type archive struct {
+	path string
+	file *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+}
+
+func newArchive(workPath string, now time.Time) *archive {
+	name := "logs-" + now.UTC().Format("20060102T150405Z") + ".jsonl.gz"
+	return &archive{path: path.Join(workPath, "log-archive", name)}
+}
+
+func (a *archive) write(logs []models.Log) (err error) {
+	if a.file == nil {
+		err = os.MkdirAll(path.Dir(a.path), 0750)
+		if err != nil {
+			return
+		}
+		a.file, err = os.OpenFile(a.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+		if err != nil {
+			return
+		}
+		a.gz = gzip.NewWriter(a.file)
+		a.enc = json.NewEncoder(a.gz)
+	}
+	for i := range logs {
+		err = a.enc.Encode(&logs[i])
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// sync makes the logs written so far durable, before they are deleted from the database.
+func (a *archive) sync() error {
+	if a.file == nil {
+		return nil
+	}
+	err := a.gz.Flush()
+	if err != nil {
+		return err
+	}
+	return a.file.Sync()
+}
+
+func (a *archive) Close() error {
+	if a.file == nil {
+		return nil
+	}
+	err := a.gz.Close()
+	if e := a.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// cut deletes the logs of name, or of every name if empty, up to toID. They are archived
+// first if a is not nil.
+func (s *logService) cut(a *archive, name string, toID int64) (int64, error) {
+	if toID <= 0 {
+		return 0, nil
+	}
+	if a != nil {
+		var fromID int64
+		for {
+			logs, err := s.repo.FindLogsInRange(name, fromID, toID, archiveBatch)
+			if err != nil {
+				return 0, err
+			}
+			if len(logs) == 0 {
+				break
+			}
+			err = a.write(logs)
+			if err != nil {
+				return 0, err
+			}
+			fromID = logs[len(logs)-1].ID
+		}
+		err := a.sync()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return s.repo.DeleteLogsUpTo(name, toID)
+}
+
+// Compact applies the retention limits: age first, then rows per name, then database size.
+func (s *logService) Compact() (result Compaction, err error) {
+	s.compactLock.Lock()
+	defer s.compactLock.Unlock()
+
+	conf := global_configuration.GetGlobalConfig().Get()
+	var a *archive
+	if conf.Log.Archive {
+		a = newArchive(conf.Sys.WorkPath, time.Now())
+		defer func() {
+			if e := a.Close(); err == nil {
+				err = e
+			}
+			if a.file != nil {
+				result.Archive = a.path
+			}
+		}()
+	}
+
+	if conf.Log.MaxAge > 0 {
+		var toID int64
+		toID, err = s.repo.MaxIDBefore(time.Now().Add(-time.Second * time.Duration(conf.Log.MaxAge)))
+		if err != nil {
+			return
+		}
+		result.Expired, err = s.cut(a, "", toID)
+		if err != nil {
+			return
+		}
+	}
+
+	if conf.Log.MaxRowsPerName > 0 {
+		var counts map[string]int64
+		counts, err = s.repo.CountGroupByName()
+		if err != nil {
+			return
+		}
+		for name, count := range counts {
+			if count <= conf.Log.MaxRowsPerName {
+				continue
+			}
+			var toID, n int64
+			toID, err = s.repo.NthNewestID(name, conf.Log.MaxRowsPerName+1)
+			if err != nil {
+				return
+			}
+			n, err = s.cut(a, name, toID)
+			result.Trimmed += n
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	if conf.Log.MaxDatabaseSize > 0 {
+		var size int64
+		size, err = s.repo.Size()
+		if err != nil || size <= conf.Log.MaxDatabaseSize {
+			return
+		}
+		var total, toID int64
+		total, err = s.repo.CountAll()
+		if err != nil {
+			return
+		}
+		// Logs are taken as the same size, and a tenth more goes so that new ones fit.
+		keep := total - total*(size-conf.Log.MaxDatabaseSize)/size - total/10
+		if keep < 0 {
+			keep = 0
+		}
+		toID, err = s.repo.NthNewestID("", keep+1)
+		if err != nil {
+			return
+		}
+		result.Shrunk, err = s.cut(a, "", toID)
+		if err != nil || result.Shrunk == 0 {
+			return
+		}
+		err = s.repo.Vacuum()
+	}
+	return
+}
+
+// runCompaction compacts the logs every interval until stop is closed.
+func (s *logService) runCompaction(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		result, err := s.Compact()
+		if err != nil {
+			logrus.Errorf("log compaction error: %v", err)
+			continue
+		}
+		if result.Expired+result.Trimmed+result.Shrunk > 0 {
+			logrus.Infof("log compaction deleted %d expired, %d trimmed and %d shrunk logs",
+				result.Expired, result.Trimmed, result.Shrunk)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/zhsyourai/URCF-engine/models"
+)
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatalf("TempDir error(%v)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := newArchive(dir, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	if a.sync() != nil || a.Close() != nil {
+		t.Fatalf("%s", "empty archive error")
+	}
+	if _, err := os.Stat(a.path); !os.IsNotExist(err) {
+		t.Fatalf("empty archive created %s", a.path)
+	}
+	logs := []models.Log{
+		{ID: 1, Name: "web", Message: "started", Level: models.InfoLevel},
+		{ID: 2, Name: "web", Message: "failed", Level: models.ErrorLevel},
+	}
+	err = a.write(logs[:1])
+	if err == nil {
+		err = a.sync()
+	}
+	if err == nil {
+		err = a.write(logs[1:])
+	}
+	if err == nil {
+		err = a.Close()
+	}
+	if err != nil {
+		t.Fatalf("archive write error(%v)", err)
+	}
+
+	f, err := os.Open(a.path)
+	if err != nil {
+		t.Fatalf("archive open error(%v)", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("archive gzip error(%v)", err)
+	}
+	scanner := bufio.NewScanner(gz)
+	var read []models.Log
+	for scanner.Scan() {
+		var log models.Log
+		err = json.Unmarshal(scanner.Bytes(), &log)
+		if err != nil {
+			t.Fatalf("archive line error(%v)", err)
+		}
+		read = append(read, log)
+	}
+	if len(read) != 2 || read[1].Message != "failed" || read[1].Level != models.ErrorLevel {
+		t.Fatalf("archive read %v", read)
+	}
+}
@@ -0,0 +1,42 @@
+package sink
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+)
+
+// fileSink appends logs to a file as JSON lines.
+type fileSink struct {
+	file *os.File
+	enc  *json.Encoder
+}
+
+func newFileSink(conf global_configuration.LogSink) (*fileSink, error) {
+	err := os.MkdirAll(path.Dir(conf.Path), 0750)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(conf.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+func (s *fileSink) Send(logs []models.Log) error {
+	for i := range logs {
+		err := s.enc.Encode(newRecord(&logs[i]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	return s.file.Close()
+}
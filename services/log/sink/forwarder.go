@@ -0,0 +1,277 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+)
+
+const (
+	defaultBatchSize     = 100
+	defaultFlushInterval = 5
+	defaultMaxBuffer     = 64 << 20
+	// queueBatches is how many batches wait for a sink before logs are dropped.
+	queueBatches = 10
+)
+
+// Forwarder sends the logs at least as severe as its level to a sink in batches, without
+// holding up the caller. Batches the sink fails on are spooled to a directory and sent again
+// before any newer one.
+type Forwarder struct {
+	name      string
+	sink      Sink
+	level     models.Level
+	batchSize int
+	interval  time.Duration
+	spool     string
+	maxBuffer int64
+	queue     chan models.Log
+	dropped   uint64
+	lock      sync.RWMutex
+	closed    bool
+	done      chan struct{}
+}
+
+// NewForwarder creates the sink conf describes and starts forwarding to it. Failed batches
+// are spooled under spoolPath.
+func NewForwarder(conf global_configuration.LogSink, spoolPath string) (*Forwarder, error) {
+	level := models.DebugLevel
+	if conf.Level != "" {
+		var err error
+		level, err = models.ParseLevel(conf.Level)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s, err := New(conf)
+	if err != nil {
+		return nil, err
+	}
+	f := &Forwarder{
+		name:      conf.Name,
+		sink:      s,
+		level:     level,
+		batchSize: conf.BatchSize,
+		interval:  time.Second * time.Duration(conf.FlushInterval),
+		spool:     spoolPath,
+		maxBuffer: conf.MaxBuffer,
+		done:      make(chan struct{}),
+	}
+	if f.batchSize <= 0 {
+		f.batchSize = defaultBatchSize
+	}
+	if f.interval <= 0 {
+		f.interval = time.Second * defaultFlushInterval
+	}
+	if f.maxBuffer <= 0 {
+		f.maxBuffer = defaultMaxBuffer
+	}
+	f.queue = make(chan models.Log, f.batchSize*queueBatches)
+	go f.run()
+	return f, nil
+}
+
+// Forward queues log if it is severe enough. It is dropped if the queue is full.
+func (f *Forwarder) Forward(log models.Log) {
+	if log.Level > f.level {
+		return
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if f.closed {
+		return
+	}
+	select {
+	case f.queue <- log:
+	default:
+		atomic.AddUint64(&f.dropped, 1)
+	}
+}
+
+func (f *Forwarder) Name() string {
+	return f.name
+}
+
+// Dropped returns how many logs were lost, because the queue or the spool was full.
+func (f *Forwarder) Dropped() uint64 {
+	return atomic.LoadUint64(&f.dropped)
+}
+
+// Close sends the queued logs, leaving the ones the sink fails on spooled, and closes the sink.
+func (f *Forwarder) Close() error {
+	f.lock.Lock()
+	if f.closed {
+		f.lock.Unlock()
+		return nil
+	}
+	f.closed = true
+	close(f.queue)
+	f.lock.Unlock()
+	<-f.done
+	return f.sink.Close()
+}
+
+func (f *Forwarder) run() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	batch := make([]models.Log, 0, f.batchSize)
+	for {
+		select {
+		case log, ok := <-f.queue:
+			if !ok {
+				f.flush(batch)
+				return
+			}
+			batch = append(batch, log)
+			if len(batch) < f.batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		f.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// flush sends the spooled batches then batch, spooling it if anything fails.
+func (f *Forwarder) flush(batch []models.Log) {
+	err := f.resend()
+	if err == nil {
+		if len(batch) == 0 {
+			return
+		}
+		err = f.sink.Send(batch)
+		if err == nil {
+			return
+		}
+	}
+	if len(batch) == 0 {
+		return
+	}
+	logrus.Warnf("log sink %s send error: %v", f.name, err)
+	err = f.spoolBatch(batch)
+	if err != nil {
+		logrus.Errorf("log sink %s spool error: %v", f.name, err)
+		atomic.AddUint64(&f.dropped, uint64(len(batch)))
+	}
+}
+
+// spooled returns the spool files, oldest first.
+func (f *Forwarder) spooled() ([]os.FileInfo, error) {
+	files, err := ioutil.ReadDir(f.spool)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+	return files, nil
+}
+
+// resend sends the spooled batches in order, stopping at the first failing one.
+func (f *Forwarder) resend() error {
+	files, err := f.spooled()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name := path.Join(f.spool, file.Name())
+		logs, err := readSpool(name)
+		if err != nil {
+			logrus.Errorf("log sink %s spool %s read error: %v", f.name, name, err)
+			os.Remove(name)
+			continue
+		}
+		err = f.sink.Send(logs)
+		if err != nil {
+			return err
+		}
+		os.Remove(name)
+	}
+	return nil
+}
+
+func readSpool(name string) ([]models.Log, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var logs []models.Log
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var log models.Log
+		err = json.Unmarshal(scanner.Bytes(), &log)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+	return logs, scanner.Err()
+}
+
+// spoolBatch writes batch to a new spool file, then deletes the oldest ones over the buffer
+// size.
+func (f *Forwarder) spoolBatch(batch []models.Log) error {
+	err := os.MkdirAll(f.spool, 0750)
+	if err != nil {
+		return err
+	}
+	name := path.Join(f.spool, fmt.Sprintf("%020d.jsonl", time.Now().UnixNano()))
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(file)
+	for i := range batch {
+		err = enc.Encode(&batch[i])
+		if err != nil {
+			break
+		}
+	}
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(name)
+		return err
+	}
+
+	files, err := f.spooled()
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, file := range files {
+		size += file.Size()
+	}
+	// The batch just spooled is kept, even alone over the size.
+	for _, file := range files[:len(files)-1] {
+		if size <= f.maxBuffer {
+			break
+		}
+		name := path.Join(f.spool, file.Name())
+		logs, _ := readSpool(name)
+		os.Remove(name)
+		size -= file.Size()
+		atomic.AddUint64(&f.dropped, uint64(len(logs)))
+		logrus.Warnf("log sink %s buffer is full, dropped %d logs", f.name, len(logs))
+	}
+	return nil
+}
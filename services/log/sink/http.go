@@ -0,0 +1,141 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+)
+
+const httpTimeout = 10 * time.Second
+
+// httpSink pushes logs to Loki or to the bulk API of Elasticsearch.
+type httpSink struct {
+	url     string
+	format  string
+	index   string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newHTTPSink(conf global_configuration.LogSink) (*httpSink, error) {
+	switch conf.Format {
+	case "loki", "elasticsearch":
+	default:
+		return nil, ErrUnknownSinkFormat
+	}
+	index := conf.Index
+	if index == "" {
+		index = "urcf-logs"
+	}
+	return &httpSink{
+		url:     conf.URL,
+		format:  conf.Format,
+		index:   index,
+		headers: conf.Headers,
+		client:  &http.Client{Timeout: httpTimeout},
+	}, nil
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// loki encodes a push request, with a stream for every name and level.
+func (s *httpSink) loki(logs []models.Log) ([]byte, error) {
+	var streams []*lokiStream
+	byLabels := make(map[[2]string]*lokiStream)
+	for i := range logs {
+		log := &logs[i]
+		labels := [2]string{log.Name, log.Level.String()}
+		stream := byLabels[labels]
+		if stream == nil {
+			stream = &lokiStream{Stream: map[string]string{"name": labels[0], "level": labels[1]}}
+			byLabels[labels] = stream
+			streams = append(streams, stream)
+		}
+		line, err := json.Marshal(newRecord(log))
+		if err != nil {
+			return nil, err
+		}
+		stream.Values = append(stream.Values,
+			[2]string{strconv.FormatInt(log.CreateTime.UnixNano(), 10), string(line)})
+	}
+	return json.Marshal(map[string]interface{}{"streams": streams})
+}
+
+// elasticsearch encodes a bulk request indexing every log.
+func (s *httpSink) elasticsearch(logs []models.Log) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	action := map[string]interface{}{"index": map[string]string{"_index": s.index}}
+	for i := range logs {
+		err := enc.Encode(action)
+		if err != nil {
+			return nil, err
+		}
+		err = enc.Encode(newRecord(&logs[i]))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *httpSink) Send(logs []models.Log) error {
+	var body []byte
+	var err error
+	contentType := "application/json"
+	if s.format == "loki" {
+		body, err = s.loki(logs)
+	} else {
+		body, err = s.elasticsearch(logs)
+		contentType = "application/x-ndjson"
+	}
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return fmt.Errorf("%s: %s", s.url, resp.Status)
+	}
+	if s.format == "elasticsearch" {
+		// A bulk request succeeds even when items fail.
+		var result struct {
+			Errors bool `json:"errors"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			return err
+		}
+		if result.Errors {
+			return fmt.Errorf("%s: some logs were not indexed", s.url)
+		}
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	return nil
+}
@@ -0,0 +1,57 @@
+package sink
+
+import (
+	"errors"
+	"time"
+
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+)
+
+var ErrUnknownSinkType = errors.New("unknown log sink type")
+var ErrUnknownSinkFormat = errors.New("unknown log sink format")
+
+// Sink sends logs somewhere out of the engine.
+type Sink interface {
+	// Send sends logs, in order. On error, they may have been partly sent.
+	Send(logs []models.Log) error
+	Close() error
+}
+
+// New creates the sink conf describes.
+func New(conf global_configuration.LogSink) (Sink, error) {
+	switch conf.Type {
+	case "syslog":
+		return newSyslogSink(conf)
+	case "file":
+		return newFileSink(conf)
+	case "http":
+		return newHTTPSink(conf)
+	}
+	return nil, ErrUnknownSinkType
+}
+
+// record is how a log is encoded by the sinks sending JSON.
+type record struct {
+	Timestamp time.Time              `json:"@timestamp"`
+	Name      string                 `json:"name"`
+	Level     string                 `json:"level"`
+	Message   string                 `json:"message"`
+	Caller    string                 `json:"caller,omitempty"`
+	TraceID   string                 `json:"trace_id,omitempty"`
+	SpanID    string                 `json:"span_id,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+func newRecord(log *models.Log) *record {
+	return &record{
+		Timestamp: log.CreateTime,
+		Name:      log.Name,
+		Level:     log.Level.String(),
+		Message:   log.Message,
+		Caller:    log.Caller,
+		TraceID:   log.TraceID,
+		SpanID:    log.SpanID,
+		Fields:    log.Fields,
+	}
+}
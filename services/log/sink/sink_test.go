@@ -0,0 +1,283 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+)
+
+var testLogs = []models.Log{
+	{Name: "web", Message: "started", Level: models.InfoLevel, CreateTime: time.Unix(1500000000, 0)},
+	{Name: "web", Message: "failed", Level: models.ErrorLevel, CreateTime: time.Unix(1500000001, 0),
+		TraceID: "abc", Fields: map[string]interface{}{"port": "80"}},
+	{Name: "db", Message: "ready", Level: models.InfoLevel, CreateTime: time.Unix(1500000002, 0)},
+}
+
+// stub records the bodies posted to it, failing while fail is positive.
+type stub struct {
+	lock   sync.Mutex
+	fail   int
+	bodies []string
+	header http.Header
+	server *httptest.Server
+}
+
+func newStub(response string) *stub {
+	s := &stub{}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if s.fail > 0 {
+			s.fail--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		s.bodies = append(s.bodies, string(body))
+		s.header = r.Header
+		w.Write([]byte(response))
+	}))
+	return s
+}
+
+func (s *stub) received() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+func TestLoki(t *testing.T) {
+	stub := newStub("")
+	defer stub.server.Close()
+	s, err := New(global_configuration.LogSink{Type: "http", Format: "loki", URL: stub.server.URL,
+		Headers: map[string]string{"X-Scope-OrgID": "urcf"}})
+	if err != nil {
+		t.Fatalf("New error(%v)", err)
+	}
+	err = s.Send(testLogs)
+	if err != nil {
+		t.Fatalf("Send error(%v)", err)
+	}
+	bodies := stub.received()
+	if len(bodies) != 1 || stub.header.Get("X-Scope-OrgID") != "urcf" {
+		t.Fatalf("loki got %v %v", bodies, stub.header)
+	}
+	var push struct {
+		Streams []lokiStream `json:"streams"`
+	}
+	err = json.Unmarshal([]byte(bodies[0]), &push)
+	if err != nil {
+		t.Fatalf("push decode error(%v)", err)
+	}
+	if len(push.Streams) != 3 || push.Streams[0].Stream["name"] != "web" ||
+		push.Streams[0].Stream["level"] != "info" || push.Streams[0].Values[0][0] != "1500000000000000000" {
+		t.Fatalf("loki streams got %+v", push.Streams)
+	}
+	var r record
+	err = json.Unmarshal([]byte(push.Streams[1].Values[0][1]), &r)
+	if err != nil || r.Message != "failed" || r.TraceID != "abc" || r.Fields["port"] != "80" {
+		t.Fatalf("loki line got %+v (%v)", r, err)
+	}
+}
+
+func TestElasticsearch(t *testing.T) {
+	stub := newStub(`{"errors":false}`)
+	defer stub.server.Close()
+	s, err := New(global_configuration.LogSink{Type: "http", Format: "elasticsearch", URL: stub.server.URL,
+		Index: "logs"})
+	if err != nil {
+		t.Fatalf("New error(%v)", err)
+	}
+	err = s.Send(testLogs)
+	if err != nil {
+		t.Fatalf("Send error(%v)", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stub.received()[0]), "\n")
+	if len(lines) != 6 || lines[0] != `{"index":{"_index":"logs"}}` ||
+		!strings.Contains(lines[3], `"message":"failed"`) ||
+		stub.header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("bulk got %v", lines)
+	}
+
+	failing := newStub(`{"errors":true}`)
+	defer failing.server.Close()
+	s, _ = New(global_configuration.LogSink{Type: "http", Format: "elasticsearch", URL: failing.server.URL})
+	if s.Send(testLogs) == nil {
+		t.Fatalf("%s", "bulk with errors should fail")
+	}
+}
+
+func TestForwarderRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatalf("TempDir error(%v)", err)
+	}
+	defer os.RemoveAll(dir)
+	stub := newStub("")
+	defer stub.server.Close()
+	stub.fail = 2
+
+	f, err := NewForwarder(global_configuration.LogSink{Name: "loki", Type: "http", Format: "loki",
+		Level: "info", URL: stub.server.URL, BatchSize: 1, FlushInterval: 3600}, dir)
+	if err != nil {
+		t.Fatalf("NewForwarder error(%v)", err)
+	}
+	f.Forward(models.Log{Name: "web", Message: "debug", Level: models.DebugLevel})
+	for _, log := range testLogs {
+		f.Forward(log)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("Close error(%v)", err)
+	}
+
+	var messages []string
+	for _, body := range stub.received() {
+		var push struct {
+			Streams []lokiStream `json:"streams"`
+		}
+		json.Unmarshal([]byte(body), &push)
+		for _, stream := range push.Streams {
+			for _, value := range stream.Values {
+				var r record
+				json.Unmarshal([]byte(value[1]), &r)
+				messages = append(messages, r.Message)
+			}
+		}
+	}
+	if strings.Join(messages, ",") != "started,failed,ready" {
+		t.Fatalf("forwarded got %v", messages)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 0 || f.Dropped() != 0 {
+		t.Fatalf("spool got %d files, dropped %d", len(files), f.Dropped())
+	}
+}
+
+func TestForwarderSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatalf("TempDir error(%v)", err)
+	}
+	defer os.RemoveAll(dir)
+	stub := newStub("")
+	stub.server.Close()
+
+	f, err := NewForwarder(global_configuration.LogSink{Name: "loki", Type: "http", Format: "loki",
+		URL: stub.server.URL, BatchSize: 1, FlushInterval: 3600, MaxBuffer: 1}, dir)
+	if err != nil {
+		t.Fatalf("NewForwarder error(%v)", err)
+	}
+	for _, log := range testLogs {
+		f.Forward(log)
+	}
+	f.Close()
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 || f.Dropped() != 2 {
+		t.Fatalf("spool got %d files, dropped %d", len(files), f.Dropped())
+	}
+	logs, err := readSpool(path.Join(dir, files[0].Name()))
+	if err != nil || len(logs) != 1 || logs[0].Message != "ready" {
+		t.Fatalf("spool got %+v (%v)", logs, err)
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatalf("TempDir error(%v)", err)
+	}
+	defer os.RemoveAll(dir)
+	name := path.Join(dir, "logs", "urcf.jsonl")
+	s, err := New(global_configuration.LogSink{Type: "file", Path: name})
+	if err != nil {
+		t.Fatalf("New error(%v)", err)
+	}
+	err = s.Send(testLogs)
+	if err == nil {
+		err = s.Close()
+	}
+	if err != nil {
+		t.Fatalf("Send error(%v)", err)
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("Open error(%v)", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	var n int
+	for ; scanner.Scan(); n++ {
+		var r record
+		if json.Unmarshal(scanner.Bytes(), &r) != nil || r.Message != testLogs[n].Message {
+			t.Fatalf("line %d got %s", n, scanner.Text())
+		}
+	}
+	if n != len(testLogs) {
+		t.Fatalf("file got %d lines", n)
+	}
+}
+
+func TestSyslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket error(%v)", err)
+	}
+	defer conn.Close()
+	s, err := New(global_configuration.LogSink{Type: "syslog", Network: "udp", Address: conn.LocalAddr().String()})
+	if err != nil {
+		t.Fatalf("New error(%v)", err)
+	}
+	defer s.Close()
+	err = s.Send(testLogs[1:2])
+	if err != nil {
+		t.Fatalf("Send error(%v)", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom error(%v)", err)
+	}
+	msg := string(buf[:n])
+	// local0 and error.
+	if !strings.HasPrefix(msg, "<131>1 ") || !strings.Contains(msg, " web ") || !strings.HasSuffix(msg, " - - failed") {
+		t.Fatalf("syslog got %q", msg)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error(%v)", err)
+	}
+	defer l.Close()
+	s, _ = New(global_configuration.LogSink{Type: "syslog", Network: "tcp", Address: l.Addr().String()})
+	defer s.Close()
+	err = s.Send(testLogs[:1])
+	if err != nil {
+		t.Fatalf("Send error(%v)", err)
+	}
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept error(%v)", err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _ = c.Read(buf)
+	frame := strings.SplitN(string(buf[:n]), " ", 2)
+	if len(frame) != 2 || frame[0] != strconv.Itoa(len(frame[1])) {
+		t.Fatalf("syslog frame got %q", buf[:n])
+	}
+}
@@ -0,0 +1,124 @@
+package sink
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+)
+
+const (
+	syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+	syslogTimeout    = 10 * time.Second
+	// defaultFacility is local0.
+	defaultFacility = 16
+)
+
+// syslogSink sends logs as RFC 5424 messages over UDP, TCP or a unix socket. Messages on a
+// stream are framed by octet counting (RFC 6587).
+type syslogSink struct {
+	network  string
+	address  string
+	facility int
+	hostname string
+	conn     net.Conn
+}
+
+func newSyslogSink(conf global_configuration.LogSink) (*syslogSink, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	facility := conf.Facility
+	if facility == 0 {
+		facility = defaultFacility
+	}
+	network := conf.Network
+	if network == "" {
+		network = "udp"
+	}
+	return &syslogSink{
+		network:  network,
+		address:  conf.Address,
+		facility: facility,
+		hostname: hostname,
+	}, nil
+}
+
+func severity(level models.Level) int {
+	switch level {
+	case models.PanicLevel:
+		return 0
+	case models.FatalLevel:
+		return 2
+	case models.ErrorLevel:
+		return 3
+	case models.WarnLevel:
+		return 4
+	case models.InfoLevel:
+		return 6
+	}
+	return 7
+}
+
+// appName turns a log name into an APP-NAME, printable ASCII up to 48 characters.
+func appName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		return "-"
+	}
+	if len(name) > 48 {
+		name = name[:48]
+	}
+	return name
+}
+
+func (s *syslogSink) format(log *models.Log) string {
+	return fmt.Sprintf("<%d>1 %s %s %s %d - - %s", s.facility*8+severity(log.Level),
+		log.CreateTime.Format(syslogTimeFormat), s.hostname, appName(log.Name), os.Getpid(), log.Message)
+}
+
+func (s *syslogSink) stream() bool {
+	return s.network != "udp" && s.network != "udp4" && s.network != "udp6" && s.network != "unixgram"
+}
+
+func (s *syslogSink) Send(logs []models.Log) (err error) {
+	if s.conn == nil {
+		s.conn, err = net.DialTimeout(s.network, s.address, syslogTimeout)
+		if err != nil {
+			s.conn = nil
+			return
+		}
+	}
+	for i := range logs {
+		msg := s.format(&logs[i])
+		if s.stream() {
+			msg = fmt.Sprintf("%d %s", len(msg), msg)
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+		_, err = s.conn.Write([]byte(msg))
+		if err != nil {
+			// The connection is opened again on the next send.
+			s.conn.Close()
+			s.conn = nil
+			return
+		}
+	}
+	return
+}
+
+func (s *syslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
@@ -0,0 +1,156 @@
+package log
+
+import (
+	"errors"
+	"regexp"
+	"sync"
+
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/repositories"
+)
+
+// ErrTailTooSlow is the error of a tail dropped because it didn't keep up with the logs.
+var ErrTailTooSlow = errors.New("log tail dropped, it did not keep up with the logs")
+
+const (
+	tailChanSize = 256
+	// maxTailHistory is the most logs a tail starts with, the most a search returns.
+	maxTailHistory = 100
+)
+
+// Tail follows the logs inserted after it was opened that pass its filter. History holds
+// up to the last logs inserted before, among which only the ones matching the pattern are
+// kept. Close must be called once the tail isn't needed anymore.
+type Tail struct {
+	History []models.Log
+	filter  models.LogTailFilter
+	pattern *regexp.Regexp
+	logs    chan models.Log
+	// after is the ID of the last log of History, live logs up to it are already there.
+	after   int64
+	err     error
+	service *logService
+	once    sync.Once
+}
+
+// Logs returns the channel of the followed logs, closed when the tail is closed or dropped.
+func (t *Tail) Logs() <-chan models.Log {
+	return t.logs
+}
+
+// Err returns ErrTailTooSlow once Logs is closed if the tail was dropped.
+func (t *Tail) Err() error {
+	t.service.tailLock.Lock()
+	defer t.service.tailLock.Unlock()
+	return t.err
+}
+
+func (t *Tail) Close() {
+	t.service.tailLock.Lock()
+	defer t.service.tailLock.Unlock()
+	t.close(nil)
+}
+
+// close closes the tail, tailLock held.
+func (t *Tail) close(err error) {
+	t.once.Do(func() {
+		delete(t.service.tails, t)
+		t.err = err
+		close(t.logs)
+	})
+}
+
+func (t *Tail) match(log *models.Log) bool {
+	if t.filter.Name != "" && log.Name != t.filter.Name {
+		return false
+	}
+	if t.filter.MinLevel != nil && log.Level > *t.filter.MinLevel {
+		return false
+	}
+	return t.pattern == nil || t.pattern.MatchString(log.Message)
+}
+
+func (s *logService) Tail(filter models.LogTailFilter, last int) (*Tail, error) {
+	t := &Tail{
+		filter:  filter,
+		logs:    make(chan models.Log, tailChanSize),
+		service: s,
+	}
+	if filter.Pattern != "" {
+		var err error
+		t.pattern, err = regexp.Compile(filter.Pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Logs inserted while the history is read are both in it and sent, the ones already
+	// queued are dropped once the last ID of the history is known, see dropUpTo.
+	s.tailLock.Lock()
+	s.tails[t] = struct{}{}
+	s.tailLock.Unlock()
+	if last > maxTailHistory {
+		last = maxTailHistory
+	}
+	if last > 0 {
+		logs, err := s.repo.Search(models.LogQuery{Name: filter.Name, MinLevel: filter.MinLevel}, 0,
+			uint32(last), []repositories.Sort{{Name: "id", Order: repositories.DESC}})
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		for i := len(logs) - 1; i >= 0; i-- {
+			if t.match(&logs[i]) {
+				t.History = append(t.History, logs[i])
+			}
+		}
+		if len(logs) > 0 {
+			s.tailLock.Lock()
+			t.after = logs[0].ID
+			t.dropUpTo(t.after)
+			s.tailLock.Unlock()
+		}
+	}
+	return t, nil
+}
+
+// dropUpTo drops the queued logs with an ID up to id, tailLock held. Nothing reads Logs
+// before Tail returns, so the kept ones are queued again in order.
+func (t *Tail) dropUpTo(id int64) {
+	var kept []models.Log
+	for {
+		select {
+		case log, ok := <-t.logs:
+			if !ok {
+				// Dropped as too slow meanwhile.
+				return
+			}
+			if log.ID > id {
+				kept = append(kept, log)
+			}
+			continue
+		default:
+		}
+		break
+	}
+	for _, log := range kept {
+		t.logs <- log
+	}
+}
+
+// broadcast sends log to the tails it passes the filter of. A tail that doesn't keep up is
+// dropped instead of holding up the writer.
+func (s *logService) broadcast(log *models.Log) {
+	s.tailLock.Lock()
+	defer s.tailLock.Unlock()
+	for t := range s.tails {
+		if log.ID <= t.after || !t.match(log) {
+			continue
+		}
+		select {
+		case t.logs <- *log:
+		default:
+			t.close(ErrTailTooSlow)
+		}
+	}
+}
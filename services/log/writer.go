@@ -0,0 +1,131 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+)
+
+// Stats counts what became of the logs written.
+type Stats struct {
+	// Queued logs wait to be written to the database.
+	Queued  int    `json:"queued"`
+	Written uint64 `json:"written"`
+	// Dropped logs didn't fit in the queue, Failed ones were lost to a database error.
+	Dropped uint64 `json:"dropped"`
+	Failed  uint64 `json:"failed"`
+	// SinkDropped are the logs each sink lost.
+	SinkDropped map[string]uint64 `json:"sink_dropped"`
+}
+
+// startWriter starts writing the logs to the database in batches. Until then, and once
+// stopped, they are written one at a time.
+func (s *logService) startWriter(conf global_configuration.Log) {
+	queueSize := conf.WriteQueue
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	batchSize := conf.WriteBatch
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	interval := time.Millisecond * time.Duration(conf.WriteInterval)
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+	s.writerLock.Lock()
+	defer s.writerLock.Unlock()
+	s.queue = make(chan *models.Log, queueSize)
+	s.writerDone = make(chan struct{})
+	go s.runWriter(s.queue, batchSize, interval, s.writerDone)
+}
+
+// stopWriter writes the queued logs and stops the writer.
+func (s *logService) stopWriter() {
+	s.writerLock.Lock()
+	queue, done := s.queue, s.writerDone
+	s.queue = nil
+	s.writerLock.Unlock()
+	if queue == nil {
+		return
+	}
+	close(queue)
+	<-done
+}
+
+// enqueue hands log to the writer, dropping it if the queue is full. It returns false if
+// the writer isn't running.
+func (s *logService) enqueue(log *models.Log) bool {
+	s.writerLock.RLock()
+	defer s.writerLock.RUnlock()
+	if s.queue == nil {
+		return false
+	}
+	select {
+	case s.queue <- log:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+	return true
+}
+
+func (s *logService) runWriter(queue chan *models.Log, batchSize int, interval time.Duration,
+	done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	batch := make([]*models.Log, 0, batchSize)
+	for {
+		select {
+		case log, ok := <-queue:
+			if !ok {
+				s.writeBatch(batch)
+				return
+			}
+			batch = append(batch, log)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		s.writeBatch(batch)
+		batch = batch[:0]
+	}
+}
+
+func (s *logService) writeBatch(batch []*models.Log) {
+	if len(batch) == 0 {
+		return
+	}
+	err := s.repo.InsertLogs(batch)
+	if err != nil {
+		logrus.Errorf("log write error: %v", err)
+		atomic.AddUint64(&s.failed, uint64(len(batch)))
+		return
+	}
+	atomic.AddUint64(&s.written, uint64(len(batch)))
+	for _, log := range batch {
+		s.broadcast(log)
+	}
+}
+
+func (s *logService) Stats() Stats {
+	stats := Stats{
+		Written:     atomic.LoadUint64(&s.written),
+		Dropped:     atomic.LoadUint64(&s.dropped),
+		Failed:      atomic.LoadUint64(&s.failed),
+		SinkDropped: make(map[string]uint64),
+	}
+	s.writerLock.RLock()
+	stats.Queued = len(s.queue)
+	s.writerLock.RUnlock()
+	s.sinkLock.RLock()
+	for _, f := range s.sinks {
+		stats.SinkDropped[f.Name()] = f.Dropped()
+	}
+	s.sinkLock.RUnlock()
+	return stats
+}
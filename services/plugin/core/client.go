@@ -66,6 +66,7 @@ const (
 	EnvPluginListenerAddress  = "ENV_PLUGIN_LISTENER_ADDRESS"
 	EnvAllowPluginRpcProtocol = "ENV_ALLOW_PLUGIN_RPC_PROTOCOL"
 	EnvRequestVersion         = "ENV_REQUEST_VERSION"
+	EnvCoreServerAddress      = "ENV_CORE_SERVER_ADDRESS"
 
 	MsgCoreVersion = "CoreVersion"
 	MsgVersion     = "Version"
@@ -161,13 +162,27 @@ func (c *Client) Start() error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
+	coreAddr, err := CoreServerAddress()
+	if err != nil {
+		return err
+	}
+
 	env := make(map[string]string)
+	env[EnvCoreServerAddress] = utils.CovertToSchemeAddress(coreAddr)
 	env[EnvPluginListenerAddress] = utils.CovertToSchemeAddress(c.config.Address)
 	env[EnvAllowPluginRpcProtocol] = c.config.AllowedProtocols.String()
 	env[EnvRequestVersion] = c.config.Version.String()
 
 	procServ := processes.GetInstance()
-	process, err := procServ.Prepare(c.config.Name, c.config.WorkDir, c.config.Cmd, c.config.Args, env, models.HookLog)
+	process, err := procServ.Prepare(models.ProcessParam{
+		Name:    c.config.Name,
+		Cmd:     c.config.Cmd,
+		Args:    c.config.Args,
+		WorkDir: c.config.WorkDir,
+		Env:     env,
+		Option:  models.HookLog,
+		Groups:  []string{"plugins"},
+	})
 	if err != nil {
 		return err
 	}
@@ -204,7 +219,7 @@ func (c *Client) Start() error {
 	defer func() {
 		if c.status != clientStatusDone && c.status != clientStatusEarlyExit {
 			c.status = clientStatusStopped
-			procServ.Stop(c.config.Name)
+			procServ.Stop(c.config.Name, "plugin")
 		}
 	}()
 
@@ -321,7 +336,7 @@ func (c *Client) Stop() error {
 		return errors.New("client not run")
 	}
 	c.client.UnInitialization()
-	err := procServ.Stop(c.config.Name)
+	_, err := procServ.Stop(c.config.Name, "plugin")
 	if err != nil {
 		return err
 	}
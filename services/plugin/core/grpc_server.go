@@ -0,0 +1,104 @@
+package core
+
+import (
+	"encoding/json"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/configuration"
+	"github.com/zhsyourai/URCF-engine/services/plugin/core/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net"
+	"sync"
+)
+
+// The core server serves the plugins the services of the engine, at the address they get in
+// EnvCoreServerAddress. It is shared by all the plugins and started with the first one.
+var (
+	coreServerOnce sync.Once
+	coreServerAddr net.Addr
+	coreServerErr  error
+)
+
+func CoreServerAddress() (net.Addr, error) {
+	coreServerOnce.Do(func() {
+		var lis net.Listener
+		lis, coreServerErr = Listener(true)
+		if coreServerErr != nil {
+			return
+		}
+		server := grpc.NewServer()
+		proto.RegisterConfigurationInterfaceServer(server, &configurationServer{
+			service: configuration.GetInstance(),
+		})
+		coreServerAddr = lis.Addr()
+		go server.Serve(lis)
+	})
+	return coreServerAddr, coreServerErr
+}
+
+type configurationServer struct {
+	service configuration.Service
+}
+
+// Watch streams the configuration changes. A revision no longer kept fails with OutOfRange,
+// and a plugin that doesn't keep up with ResourceExhausted, after which it may watch again
+// from the last revision it got.
+func (s *configurationServer) Watch(request *proto.WatchRequest,
+	stream proto.ConfigurationInterface_WatchServer) error {
+	watcher, err := s.service.Watch(request.Prefix, request.Revision)
+	if err == configuration.ErrRevisionCompacted {
+		return status.Error(codes.OutOfRange, err.Error())
+	} else if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events():
+			if !ok {
+				if err := watcher.Err(); err != nil {
+					return status.Error(codes.ResourceExhausted, err.Error())
+				}
+				return nil
+			}
+			message, err := toProtoEvent(&event)
+			if err != nil {
+				return err
+			}
+			err = stream.Send(message)
+			if err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func toProtoEvent(event *models.ConfigEvent) (*proto.ConfigEvent, error) {
+	message := &proto.ConfigEvent{
+		Key:      event.Key,
+		Revision: event.Revision,
+		Time:     event.Time.UnixNano(),
+	}
+	if event.Type == models.DeleteEvent {
+		message.Type = proto.ConfigEvent_DELETE
+	}
+	if event.OldValue != nil {
+		value, err := json.Marshal(event.OldValue)
+		if err != nil {
+			return nil, err
+		}
+		message.OldValue = string(value)
+	}
+	if event.NewValue != nil {
+		value, err := json.Marshal(event.NewValue)
+		if err != nil {
+			return nil, err
+		}
+		message.NewValue = string(value)
+	}
+	return message, nil
+}
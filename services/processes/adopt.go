@@ -0,0 +1,431 @@
+package processes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhsyourai/URCF-engine/daemon"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+	"github.com/zhsyourai/URCF-engine/services/processes/cgroup"
+	"github.com/zhsyourai/URCF-engine/services/processes/types"
+)
+
+// adoptPollInterval is how often an adopted process, which can't be waited for, is checked.
+const adoptPollInterval = time.Second
+
+// processState is kept in the work path for every running process, so that a restarted
+// daemon can adopt the processes it had started.
+type processState struct {
+	Param models.ProcessParam `json:"param"`
+	Pid   int                 `json:"pid"`
+	// StartTicks is the start time of the process in /proc, telling it apart from another
+	// process that got the same pid.
+	StartTicks uint64    `json:"start_ticks"`
+	StartTime  time.Time `json:"start_time"`
+	Cgroup     string    `json:"cgroup"`
+	Restarts   int       `json:"restarts"`
+}
+
+func stateDir() string {
+	return path.Join(global_configuration.GetGlobalConfig().Get().Sys.WorkPath, "processes")
+}
+
+// stateFile returns the state file ext of the named process. The name is escaped, which maps
+// distinct names, such as "a/b" and "a_b", to distinct files.
+func stateFile(name string, ext string) string {
+	return path.Join(stateDir(), url.PathEscape(name)+ext)
+}
+
+// parseProcStart returns the state and the start time, in clock ticks after boot, from the
+// content of /proc/<pid>/stat.
+func parseProcStart(content string) (state string, startTicks uint64, err error) {
+	end := strings.LastIndexByte(content, ')')
+	if end < 0 {
+		return "", 0, errBadProcStat
+	}
+	// Fields after the command name, starting with the state, which is field 3.
+	fields := strings.Fields(content[end+1:])
+	if len(fields) < 20 {
+		return "", 0, errBadProcStat
+	}
+	startTicks, err = strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+	return fields[0], startTicks, nil
+}
+
+// procStart returns the start time of pid, failing when the process is gone or a zombie.
+func procStart(pid int) (uint64, error) {
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	state, startTicks, err := parseProcStart(string(stat))
+	if err != nil {
+		return 0, err
+	}
+	if state == "Z" || state == "X" {
+		return 0, ProcessNotRun
+	}
+	return startTicks, nil
+}
+
+// saveState writes the state and the pid file of the process of pp.
+func saveState(pp *processPair) error {
+	err := os.MkdirAll(stateDir(), 0700)
+	if err != nil {
+		return err
+	}
+	startTicks, err := procStart(pp.proc.Pid)
+	if err != nil {
+		return err
+	}
+	state := processState{
+		Param:      pp.proc.ProcessParam,
+		Pid:        pp.proc.Pid,
+		StartTicks: startTicks,
+		StartTime:  pp.startTime,
+		Cgroup:     pp.proc.Cgroup,
+		Restarts:   pp.proc.Statistics.Restarts,
+	}
+	content, err := json.Marshal(&state)
+	if err != nil {
+		return err
+	}
+	// The parameters hold the environment of the process, which may be secret.
+	err = ioutil.WriteFile(stateFile(pp.proc.Name, ".json"), content, 0600)
+	if err != nil {
+		return err
+	}
+	pp.proc.PidFile = stateFile(pp.proc.Name, ".pid")
+	return ioutil.WriteFile(pp.proc.PidFile, []byte(strconv.Itoa(pp.proc.Pid)+"\n"), 0644)
+}
+
+func removeState(name string) {
+	os.Remove(stateFile(name, ".json"))
+	os.Remove(stateFile(name, ".pid"))
+	for _, stream := range outputStreams {
+		os.Remove(stateFile(name, "."+stream))
+	}
+}
+
+// outputStreams are the streams a process writes to, each through a FIFO in the state dir.
+var outputStreams = []string{"stdout", "stderr", "data"}
+
+// openOutputFifo makes the FIFO a process writes stream to, and opens both of its ends, see
+// openFifo. The FIFO is made aside, at file, and the caller moves it in place once the process
+// is prepared, so that a Prepare that fails leaves the FIFO of the previous process alone.
+func openOutputFifo(name string, stream string) (reader *os.File, writer *os.File, file string, err error) {
+	err = os.MkdirAll(stateDir(), 0700)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	file = stateFile(name, "."+stream+".new")
+	reader, writer, err = openFifo(file)
+	return reader, writer, file, err
+}
+
+// openFifo makes the FIFO file and opens both of its ends. The writer end, given to a process,
+// is opened for reading as well, so that its writes never fail with EPIPE while no daemon
+// reads the FIFO, they block once the FIFO is full instead. A daemon restarted without a
+// handover reopens the FIFO to read the output of the adopted process.
+func openFifo(file string) (reader *os.File, writer *os.File, err error) {
+	os.Remove(file)
+	err = syscall.Mkfifo(file, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	writer, err = os.OpenFile(file, os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, err = os.OpenFile(file, os.O_RDONLY, 0)
+	if err != nil {
+		writer.Close()
+		return nil, nil, err
+	}
+	return reader, writer, nil
+}
+
+// reopenFifo opens the FIFO file for reading, or returns nil when there is none, such as for
+// a process started before FIFOs were used.
+func reopenFifo(file string) *os.File {
+	// Non blocking, so that a process gone meanwhile doesn't block the open.
+	f, err := os.OpenFile(file, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil
+	}
+	return f
+}
+
+// adopt takes over the processes started by a previous daemon that are still running, and
+// forgets the others.
+func (s *processesService) adopt() {
+	files, err := ioutil.ReadDir(stateDir())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("process state read error: %v", err)
+		}
+		return
+	}
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		content, err := ioutil.ReadFile(path.Join(stateDir(), file.Name()))
+		if err != nil {
+			log.Warnf("process state %s read error: %v", file.Name(), err)
+			continue
+		}
+		var state processState
+		err = json.Unmarshal(content, &state)
+		if err != nil {
+			log.Warnf("process state %s read error: %v", file.Name(), err)
+			continue
+		}
+		startTicks, err := procStart(state.Pid)
+		if err != nil || startTicks != state.StartTicks {
+			log.Infof("process %s (pid %d) is gone, forgetting it.", state.Param.Name, state.Pid)
+			removeState(state.Param.Name)
+			continue
+		}
+		err = s.adoptProcess(state)
+		if err != nil {
+			log.Errorf("process %s (pid %d) adopt error: %v", state.Param.Name, state.Pid, err)
+			continue
+		}
+		log.Infof("process %s (pid %d) adopted.", state.Param.Name, state.Pid)
+	}
+}
+
+// adoptProcess registers the running process of state. Its stdio is taken over if the
+// previous daemon handed it over on upgrade, otherwise its output is read again from its
+// FIFOs and its stdin went away. The process is not a child any more, so its exit is found
+// out by polling, without an exit code.
+func (s *processesService) adoptProcess(state processState) error {
+	pp, err := newProcessPair(state.Param)
+	if err != nil {
+		return err
+	}
+	process, err := os.FindProcess(state.Pid)
+	if err != nil {
+		return err
+	}
+	proc := pp.proc
+	proc.Process = process
+	proc.Pid = state.Pid
+	proc.PidFile = stateFile(proc.Name, ".pid")
+	proc.Cgroup = state.Cgroup
+	proc.Statistics.StartTime = state.StartTime
+	proc.Statistics.Restarts = state.Restarts
+	proc.Status = types.Running
+	pp.startTime = state.StartTime
+	if state.Cgroup != "" {
+		pp.cgroup = &cgroup.Group{Path: state.Cgroup}
+	}
+
+	s.prepareLock.Lock()
+	if _, loaded := s.procMap.LoadOrStore(proc.Name, pp); loaded {
+		s.prepareLock.Unlock()
+		return ProcessExist
+	}
+	s.prepareLock.Unlock()
+	err = s.inheritStdio(pp)
+	if err != nil {
+		log.Warnf("process %s output error: %v", proc.Name, err)
+	}
+
+	exitNotify := make(chan *os.ProcessState, 1)
+	pp.exitNotify = exitNotify
+	go func() {
+		pollExit(state.Pid, state.StartTicks)
+		close(pp.ExitingChan)
+		for _, c := range []io.Closer{proc.StdIn, proc.StdOut, proc.StdErr, proc.DataOut} {
+			if c != nil {
+				c.Close()
+			}
+		}
+		s.finish(pp)
+		exitNotify <- nil
+	}()
+
+	pp.lock.Lock()
+	s.startProbes(pp)
+	pp.lock.Unlock()
+
+	if pp.restartPolicy.Mode != models.RestartNever {
+		return s.watchDog.StartWatch(proc, exitNotify)
+	}
+	return nil
+}
+
+// pollExit returns once the process pid started at startTicks is gone.
+func pollExit(pid int, startTicks uint64) {
+	for {
+		<-time.After(adoptPollInterval)
+		// A daemon that replaced itself in place is still the parent, the zombie is reaped
+		// here. Otherwise this fails with ECHILD and init reaps it.
+		var status syscall.WaitStatus
+		syscall.Wait4(pid, &status, syscall.WNOHANG, nil)
+		current, err := procStart(pid)
+		if err != nil || current != startTicks {
+			return
+		}
+	}
+}
+
+func handoffName(name string, stream string) string {
+	return "process/" + name + "/" + stream
+}
+
+// inheritStdio takes over the stdio pipes of the process of pp handed over by the previous
+// daemon, if any. Otherwise its output FIFOs are reopened, only its stdin is gone then.
+func (s *processesService) inheritStdio(pp *processPair) error {
+	proc := pp.proc
+	if f := daemon.Inherited(handoffName(proc.Name, "stdin")); f != nil {
+		proc.StdIn = f
+	}
+	streams := map[string]*io.ReadCloser{
+		"stdout": &proc.StdOut,
+		"stderr": &proc.StdErr,
+		"data":   &proc.DataOut,
+	}
+	for stream, target := range streams {
+		if f := daemon.Inherited(handoffName(proc.Name, stream)); f != nil {
+			*target = f
+		} else if f := reopenFifo(stateFile(proc.Name, "."+stream)); f != nil {
+			*target = f
+		}
+	}
+	if proc.StdOut == nil || proc.StdErr == nil {
+		return nil
+	}
+	return s.pumpOutputs(proc.ProcessParam, proc.StdOut, proc.StdErr)
+}
+
+// HandOver stops watching the running processes and reading their output, then returns
+// duplicates of their stdio pipes for the new daemon of an upgrade. Until Resume, an exit is
+// left to the new daemon, which finds it out by polling and records it.
+func (s *processesService) HandOver() (map[string]*os.File, error) {
+	s.handOverLock.Lock()
+	defer s.handOverLock.Unlock()
+	s.handingOver = true
+	files := make(map[string]*os.File)
+	var err error
+	s.procMap.Range(func(key, value interface{}) bool {
+		pp := value.(*processPair)
+		pp.lock.Lock()
+		defer pp.lock.Unlock()
+		proc := pp.proc
+		if proc.Status != types.Running {
+			return true
+		}
+		s.handedOver = append(s.handedOver, pp)
+		s.watchDog.StopWatch(proc)
+		// The pumps return once their read times out, the pipes stay open for the handover.
+		for _, r := range []io.ReadCloser{proc.StdOut, proc.StdErr} {
+			if file, ok := r.(*os.File); ok {
+				if e := file.SetReadDeadline(time.Now()); e != nil {
+					log.Warnf("process %s output is still read until the upgrade: %v", proc.Name, e)
+				}
+			}
+		}
+		streams := map[string]interface{}{
+			"stdin":  proc.StdIn,
+			"stdout": proc.StdOut,
+			"stderr": proc.StdErr,
+			"data":   proc.DataOut,
+		}
+		for stream, f := range streams {
+			file, ok := f.(*os.File)
+			if !ok {
+				continue
+			}
+			dup, e := dupFile(file)
+			if e != nil {
+				err = e
+				return false
+			}
+			files[handoffName(proc.Name, stream)] = dup
+		}
+		return true
+	})
+	if err != nil {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, err
+	}
+	return files, nil
+}
+
+// dupFile duplicates file without going through its Fd, which would put it in blocking mode
+// and so keep its read deadline from stopping the output pumps.
+func dupFile(file *os.File) (*os.File, error) {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	fd, dupErr := -1, error(nil)
+	err = conn.Control(func(raw uintptr) {
+		fd, dupErr = syscall.Dup(int(raw))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if dupErr != nil {
+		return nil, dupErr
+	}
+	syscall.CloseOnExec(fd)
+	return os.NewFile(uintptr(fd), file.Name()), nil
+}
+
+// Resume watches the processes and reads their output again after a failed upgrade, and
+// records the exits left to the new daemon meanwhile.
+func (s *processesService) Resume() {
+	s.handOverLock.Lock()
+	pairs, unfinished := s.handedOver, s.unfinished
+	s.handingOver = false
+	s.handedOver, s.unfinished = nil, nil
+	s.handOverLock.Unlock()
+
+	for _, pp := range unfinished {
+		s.release(pp)
+	}
+	for _, pp := range pairs {
+		pp.lock.Lock()
+		proc := pp.proc
+		if !pp.exited() {
+			for _, r := range []io.ReadCloser{proc.StdOut, proc.StdErr} {
+				if file, ok := r.(*os.File); ok {
+					file.SetReadDeadline(time.Time{})
+				}
+			}
+			if proc.StdOut != nil && proc.StdErr != nil {
+				if err := s.pumpOutputs(proc.ProcessParam, proc.StdOut, proc.StdErr); err != nil {
+					log.Warnf("process %s output error: %v", proc.Name, err)
+				}
+			}
+		}
+		// Not stopped on request meanwhile, its exit, if any, is still waiting on exitNotify.
+		if pp.restartPolicy.Mode != models.RestartNever && (pp.stopBy == "" || pp.unhealthy) {
+			if err := s.watchDog.StartWatch(proc, pp.exitNotify); err != nil {
+				log.Warnf("process %s watch error: %v", proc.Name, err)
+			}
+		}
+		pp.lock.Unlock()
+	}
+}
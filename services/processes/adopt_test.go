@@ -0,0 +1,148 @@
+package processes
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/processes/types"
+	"github.com/zhsyourai/URCF-engine/services/processes/watchdog"
+)
+
+func TestParseProcStart(t *testing.T) {
+	stat := "1234 (my (odd) proc) S 1 1234 1234 0 -1 4194560 1200 0 0 0 250 50 0 0 20 0 3 0 " +
+		"91234 123456789 2048 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 2 0 0 0 0 0"
+	state, ticks, err := parseProcStart(stat)
+	if err != nil {
+		t.Fatalf("%s(%v)", "Parse stat error", err)
+	}
+	if state != "S" || ticks != 91234 {
+		t.Fatalf("Parse stat error (state %s ticks %d)", state, ticks)
+	}
+	_, _, err = parseProcStart("1234 (broken")
+	if err == nil {
+		t.Fatalf("%s", "Parse stat error (broken stat accepted)")
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	if stateFile("a/b", ".json") == stateFile("a_b", ".json") {
+		t.Fatalf("%s", "State file error (a/b and a_b share a file)")
+	}
+	if filepath.Dir(stateFile("../../etc/passwd", ".json")) != stateDir() {
+		t.Fatalf("%s", "State file error (file outside the state dir)")
+	}
+}
+
+func TestPollExit(t *testing.T) {
+	if _, err := procStart(os.Getpid()); err != nil {
+		t.Skipf("no /proc (%v)", err)
+	}
+	cmd := exec.Command("sleep", "1")
+	err := cmd.Start()
+	if err != nil {
+		t.Skipf("sleep start error(%v)", err)
+	}
+	startTicks, err := procStart(cmd.Process.Pid)
+	if err != nil {
+		t.Fatalf("%s(%v)", "procStart error", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		pollExit(cmd.Process.Pid, startTicks)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s", "pollExit did not notice the exit")
+	}
+	if _, err = procStart(cmd.Process.Pid); err == nil {
+		t.Fatalf("%s", "procStart error (exited process still running)")
+	}
+}
+
+func TestHandOver(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("%s(%v)", "Pipe error", err)
+	}
+	defer r.Close()
+	defer w.Close()
+	s := &processesService{watchDog: watchdog.GetInstance()}
+	running, _ := newProcessPair(models.ProcessParam{Name: "web"})
+	running.proc.Status = types.Running
+	running.proc.StdIn = w
+	running.proc.StdOut = r
+	exited, _ := newProcessPair(models.ProcessParam{Name: "old"})
+	exited.proc.Status = types.Exited
+	exited.proc.StdOut = r
+	s.procMap.Store("web", running)
+	s.procMap.Store("old", exited)
+
+	files, err := s.HandOver()
+	if err != nil {
+		t.Fatalf("%s(%v)", "HandOver error", err)
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	if len(files) != 2 || files["process/web/stdin"] == nil || files["process/web/stdout"] == nil {
+		t.Fatalf("HandOver got %v", files)
+	}
+	if !s.handingOver || len(s.handedOver) != 1 || s.handedOver[0] != running {
+		t.Fatalf("%s", "HandOver error (running process not handed over)")
+	}
+
+	// The old daemon stops reading, the new one reads what the process writes.
+	w.Write([]byte("line\n"))
+	buf := make([]byte, 16)
+	if _, err = r.Read(buf); err == nil {
+		t.Fatalf("%s", "Read after HandOver error (output still read)")
+	}
+	n, err := files["process/web/stdout"].Read(buf)
+	if err != nil || string(buf[:n]) != "line\n" {
+		t.Fatalf("Read handed over file got %q(%v)", buf[:n], err)
+	}
+}
+
+func TestFifoOutlivesReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fifo")
+	if err != nil {
+		t.Fatalf("%s(%v)", "TempDir error", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "web.stdout")
+	r, w, err := openFifo(file)
+	if err != nil {
+		t.Fatalf("%s(%v)", "openFifo error", err)
+	}
+	defer w.Close()
+
+	// The daemon goes away, the process keeps writing.
+	r.Close()
+	_, err = w.Write([]byte("line\n"))
+	if err != nil {
+		t.Fatalf("%s(%v)", "Write without reader error", err)
+	}
+
+	r = reopenFifo(file)
+	if r == nil {
+		t.Fatalf("%s", "reopenFifo error (FIFO not reopened)")
+	}
+	defer r.Close()
+	buf := make([]byte, 16)
+	n, err := r.Read(buf)
+	if err != nil || string(buf[:n]) != "line\n" {
+		t.Fatalf("Read after reopen got %q(%v)", buf[:n], err)
+	}
+	if reopenFifo(filepath.Join(dir, "missing")) != nil {
+		t.Fatalf("%s", "reopenFifo error (missing FIFO opened)")
+	}
+}
@@ -1,7 +1,7 @@
 package autostart
 
 import (
-	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,15 +11,28 @@ import (
 	"github.com/zhsyourai/URCF-engine/repositories/autostart"
 	"github.com/zhsyourai/URCF-engine/services"
 	"github.com/zhsyourai/URCF-engine/services/processes"
-	"github.com/zhsyourai/URCF-engine/services/processes/types"
 )
 
+var ErrAutoStartExist = errors.New("autostart entry exist")
+var ErrAutoStartNotExist = errors.New("can't find target process")
+var ErrNameAndCmdCannotBeEmpty = errors.New("name and cmd can't be empty")
+
+// CycleError lists the entries forming a dependency cycle, the first one being repeated at
+// the end.
+type CycleError []string
+
+func (e CycleError) Error() string {
+	return "autostart dependency cycle: " + strings.Join(e, " -> ")
+}
+
 type Service interface {
 	services.ServiceLifeCycle
+	List() ([]models.AutoStart, error)
 	StartAll() error
+	StopAll() error
 	EnableAll() error
 	DisableAll() error
-	Add(process types.Process, startDelay int32, stopDelay int32, priority int32, parallel bool) (int64, error)
+	Add(as models.AutoStart) (int64, error)
 	Remove(id int64) error
 	Disable(id int64) error
 	Enable(id int64) error
@@ -29,10 +42,9 @@ type autoStart struct {
 	services.InitHelper
 	sync.Mutex
 	init             bool
-	processes        map[string]*types.Process
 	repo             autostart.Repository
 	cache            map[int64]*models.AutoStart
-	sortCacheKey     []string
+	started          []*models.AutoStart
 	processesService processes.Service
 }
 
@@ -44,7 +56,7 @@ func (a *autoStart) Initialize(arguments ...interface{}) error {
 
 func (a *autoStart) UnInitialize(arguments ...interface{}) error {
 	return a.CallUnInitialize(func() error {
-		return nil
+		return a.StopAll()
 	})
 }
 
@@ -55,8 +67,6 @@ func GetInstance() Service {
 	once.Do(func() {
 		instance = &autoStart{
 			cache:            make(map[int64]*models.AutoStart),
-			sortCacheKey:     make([]string, 0),
-			processes:        make(map[string]*types.Process),
 			repo:             autostart.NewAutostartRepository(),
 			init:             false,
 			processesService: processes.GetInstance(),
@@ -69,54 +79,169 @@ func initCache(a *autoStart) error {
 	a.Lock()
 	defer a.Unlock()
 	if !a.init {
-		a.init = true
 		all, err := a.repo.FindAll()
 		if err != nil {
 			return err
 		}
-		sort.Sort(sort.Reverse(models.ByPriority(all)))
-		for _, as := range all {
-			a.cache[as.ID] = &as
+		for i := range all {
+			a.cache[all[i].ID] = &all[i]
 		}
+		a.init = true
 	}
 	return nil
 }
 
+// processParam returns the ProcessParam of an autostart entry, using StopDelay as the stop
+// grace period when the entry doesn't set one itself.
+func processParam(as *models.AutoStart) models.ProcessParam {
+	param := as.ProcessParam
+	if param.StopTimeout == 0 {
+		param.StopTimeout = as.StopDelay
+	}
+	return param
+}
+
+// entries returns the cached entries sorted by priority, only the enabled ones if enabled is
+// set. The caller must hold the lock.
+func (a *autoStart) entries(enabled bool) []*models.AutoStart {
+	entries := make([]*models.AutoStart, 0, len(a.cache))
+	for _, as := range a.cache {
+		if !enabled || as.Enable {
+			entries = append(entries, as)
+		}
+	}
+	sortEntries(entries)
+	return entries
+}
+
+// startResult is closed once an entry has been started, or given up.
+type startResult struct {
+	done chan struct{}
+	ok   bool
+}
+
+// StartAll starts the enabled entries once their dependencies are started, so entries that
+// don't depend on each other start concurrently.
 func (a *autoStart) StartAll() error {
 	err := initCache(a)
 	if err != nil {
 		return err
 	}
-	go func() {
-		for _, as := range a.cache {
-			if as.Parallel {
-				a.processes[as.Name], err = a.processesService.Prepare(as.Name, as.WorkDir, as.Cmd,
-					as.Args, as.Env, as.Option)
-				if err != nil {
-					log.Errorf("process %s autostart error: %v", as.Name, err)
-				}
-				err = a.processesService.Start(as.Name)
-				if err != nil {
-					log.Errorf("process %s autostart error: %v", as.Name, err)
-				}
+	a.Lock()
+	entries := a.entries(true)
+	a.started = entries
+	a.Unlock()
+
+	deps := dependencies(entries)
+	if cycle := findCycle(deps); cycle != nil {
+		return CycleError(cycle)
+	}
+	results := make(map[string]*startResult, len(entries))
+	for _, as := range entries {
+		results[as.Name] = &startResult{done: make(chan struct{})}
+	}
+	for _, as := range entries {
+		go func(as *models.AutoStart) {
+			result := results[as.Name]
+			defer close(result.done)
+			result.ok = a.start(as, deps[as.Name], results)
+		}(as)
+	}
+	return nil
+}
+
+// start waits for the dependencies of an entry and starts it. It returns false when the entry
+// could not be started.
+func (a *autoStart) start(as *models.AutoStart, deps []dependency, results map[string]*startResult) bool {
+	for _, dep := range deps {
+		result, ok := results[dep.name]
+		if !ok {
+			if dep.required {
+				log.Errorf("process %s requires %s which is not an enabled autostart entry, not starting it.",
+					as.Name, dep.name)
+				return false
 			}
+			continue
 		}
-		for _, as := range a.cache {
-			if !as.Parallel {
-				<-time.After(time.Second * time.Duration(as.StartDelay))
-				a.processes[as.Name], err = a.processesService.Prepare(as.Name, as.WorkDir, as.Cmd,
-					as.Args, as.Env, as.Option)
-				if err != nil {
-					log.Warnf("process %s autostart error: %v", as.Name, err)
-				}
-				err = a.processesService.Start(as.Name)
-				if err != nil {
-					log.Errorf("process %s autostart error: %v", as.Name, err)
-				}
+		<-result.done
+		started := result.ok
+		if started && as.WaitReady {
+			if err := <-a.processesService.WaitReady(dep.name); err != nil {
+				started = false
 			}
 		}
-	}()
-	return err
+		if !started && dep.required {
+			log.Errorf("process %s requires %s which did not start, not starting it.", as.Name, dep.name)
+			return false
+		}
+	}
+
+	if a.processesService.IsAlive(as.Name) {
+		// Adopted from a previous daemon.
+		log.Infof("process %s is already running.", as.Name)
+		return true
+	}
+	<-time.After(time.Second * time.Duration(as.StartDelay))
+	_, err := a.processesService.Prepare(processParam(as))
+	if err != nil {
+		log.Errorf("process %s autostart error: %v", as.Name, err)
+		return false
+	}
+	err = a.processesService.Start(as.Name)
+	if err != nil {
+		log.Errorf("process %s autostart error: %v", as.Name, err)
+		return false
+	}
+	return true
+}
+
+// StopAll stops the entries started by StartAll in the reverse order, an entry is stopped once
+// every entry depending on it is stopped.
+func (a *autoStart) StopAll() error {
+	a.Lock()
+	entries := a.started
+	a.started = nil
+	a.Unlock()
+
+	deps := dependencies(entries)
+	waits := dependents(deps)
+	stopped := make(map[string]chan struct{}, len(entries))
+	for _, as := range entries {
+		stopped[as.Name] = make(chan struct{})
+	}
+	var wg sync.WaitGroup
+	for _, as := range entries {
+		wg.Add(1)
+		go func(as *models.AutoStart) {
+			defer wg.Done()
+			defer close(stopped[as.Name])
+			for _, name := range waits[as.Name] {
+				<-stopped[name]
+			}
+			_, err := a.processesService.Stop(as.Name, "autostart")
+			if err != nil && err != processes.ProcessNotRun && err != processes.ProcessNotExist {
+				log.Errorf("process %s autostop error: %v", as.Name, err)
+			}
+		}(as)
+	}
+	wg.Wait()
+	return nil
+}
+
+// List returns every entry sorted by priority.
+func (a *autoStart) List() ([]models.AutoStart, error) {
+	err := initCache(a)
+	if err != nil {
+		return nil, err
+	}
+	a.Lock()
+	defer a.Unlock()
+	entries := a.entries(false)
+	result := make([]models.AutoStart, 0, len(entries))
+	for _, as := range entries {
+		result = append(result, *as)
+	}
+	return result, nil
 }
 
 func (a *autoStart) EnableAll() error {
@@ -143,7 +268,7 @@ func (a *autoStart) DisableAll() error {
 	a.Lock()
 	defer a.Unlock()
 	for k, v := range a.cache {
-		a.cache[k].Enable = true
+		a.cache[k].Enable = false
 		a.repo.UpdateAutoStartByID(v.ID, map[string]interface{}{
 			"Enable": false,
 		})
@@ -151,25 +276,36 @@ func (a *autoStart) DisableAll() error {
 	return err
 }
 
-func (a *autoStart) Add(process types.Process, startDelay int32, stopDelay int32, priority int32, parallel bool) (id int64, err error) {
+// Add saves a new entry. It fails when the entry has no name or cmd, when an entry of the same
+// name exists or when the dependencies of the entry would make a cycle.
+func (a *autoStart) Add(as models.AutoStart) (id int64, err error) {
+	if as.Name == "" || as.Cmd == "" {
+		return 0, ErrNameAndCmdCannotBeEmpty
+	}
 	err = initCache(a)
 	if err != nil {
 		return
 	}
-	as := &models.AutoStart{
-		Priority:     priority,
-		StartDelay:   startDelay,
-		StopDelay:    stopDelay,
-		Enable:       true,
-		Parallel:     parallel,
-		ProcessParam: process.ProcessParam,
-	}
 	a.Lock()
 	defer a.Unlock()
-	err = a.repo.InsertAutoStart(as)
+	entries := a.entries(false)
+	for _, e := range entries {
+		if e.Name == as.Name {
+			return 0, ErrAutoStartExist
+		}
+	}
+	entries = append(entries, &as)
+	sortEntries(entries)
+	if cycle := findCycle(dependencies(entries)); cycle != nil {
+		return 0, CycleError(cycle)
+	}
+
+	as.Enable = true
+	err = a.repo.InsertAutoStart(&as)
 	if err != nil {
 		return
 	}
+	a.cache[as.ID] = &as
 	id = as.ID
 	return
 }
@@ -179,15 +315,13 @@ func (a *autoStart) Remove(id int64) error {
 	if err != nil {
 		return err
 	}
-	if a.cache[id] != nil {
-		a.Lock()
-		defer a.Unlock()
-		delete(a.cache, id)
-		_, err = a.repo.DeleteAutoStartByID(id)
-		if err != nil {
-			return err
-		}
+	a.Lock()
+	defer a.Unlock()
+	if a.cache[id] == nil {
+		return ErrAutoStartNotExist
 	}
+	delete(a.cache, id)
+	_, err = a.repo.DeleteAutoStartByID(id)
 	return err
 }
 
@@ -196,12 +330,12 @@ func (a *autoStart) Disable(id int64) error {
 	if err != nil {
 		return err
 	}
+	a.Lock()
+	defer a.Unlock()
 	as := a.cache[id]
 	if as == nil {
-		return errors.New("can't find target process")
+		return ErrAutoStartNotExist
 	}
-	a.Lock()
-	defer a.Unlock()
 	as.Enable = false
 	a.repo.UpdateAutoStartByID(id, map[string]interface{}{
 		"Enable": false,
@@ -214,12 +348,12 @@ func (a *autoStart) Enable(id int64) error {
 	if err != nil {
 		return err
 	}
+	a.Lock()
+	defer a.Unlock()
 	as := a.cache[id]
 	if as == nil {
-		return errors.New("can't find target process")
+		return ErrAutoStartNotExist
 	}
-	a.Lock()
-	defer a.Unlock()
 	as.Enable = true
 	a.repo.UpdateAutoStartByID(id, map[string]interface{}{
 		"Enable": true,
@@ -0,0 +1,173 @@
+package autostart
+
+import (
+	"sort"
+
+	"github.com/zhsyourai/URCF-engine/models"
+)
+
+// dependency is an edge of the startup graph, from an entry to one started before it.
+type dependency struct {
+	name     string
+	required bool
+}
+
+// sortEntries sorts entries by descending priority, then by name so that the order is stable.
+func sortEntries(entries []*models.AutoStart) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Priority != entries[j].Priority {
+			return entries[i].Priority > entries[j].Priority
+		}
+		return entries[i].Name < entries[j].Name
+	})
+}
+
+// dependencies returns the dependencies of every entry by name, entries must be sorted. Besides
+// its after and requires entries, a non parallel entry depends on the previous non parallel
+// entry, so those are still started one by one in priority order. That priority edge is left
+// out when the previous entry already depends on the entry, so the explicit dependencies win
+// over priorities and never make a cycle with them.
+func dependencies(entries []*models.AutoStart) map[string][]dependency {
+	deps := make(map[string][]dependency, len(entries))
+	for _, as := range entries {
+		edges := make([]dependency, 0, len(as.After)+len(as.Requires)+1)
+		for _, name := range as.Requires {
+			edges = append(edges, dependency{name: name, required: true})
+		}
+		for _, name := range as.After {
+			edges = append(edges, dependency{name: name})
+		}
+		deps[as.Name] = edges
+	}
+	previous := ""
+	for _, as := range entries {
+		if as.Parallel {
+			continue
+		}
+		if previous != "" && !dependsOn(deps, previous, as.Name) {
+			deps[as.Name] = append(deps[as.Name], dependency{name: previous})
+		}
+		previous = as.Name
+	}
+	return deps
+}
+
+// dependsOn reports whether from depends on to, directly or through other entries.
+func dependsOn(deps map[string][]dependency, from string, to string) bool {
+	seen := make(map[string]bool, len(deps))
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		if name == to {
+			return true
+		}
+		if seen[name] {
+			return false
+		}
+		seen[name] = true
+		for _, dep := range deps[name] {
+			if visit(dep.name) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(from)
+}
+
+// dependents reverses deps, it returns the entries depending on every entry by name.
+func dependents(deps map[string][]dependency) map[string][]string {
+	result := make(map[string][]string, len(deps))
+	for name, edges := range deps {
+		for _, dep := range edges {
+			if _, ok := deps[dep.name]; ok {
+				result[dep.name] = append(result[dep.name], name)
+			}
+		}
+	}
+	return result
+}
+
+// findCycle returns the names of entries forming a dependency cycle, the first name being
+// repeated at the end, or nil when deps is a DAG. Dependencies on unknown entries are ignored.
+func findCycle(deps map[string][]dependency) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	state := make(map[string]int, len(deps))
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range deps[name] {
+			if _, ok := deps[dep.name]; !ok {
+				continue
+			}
+			switch state[dep.name] {
+			case visiting:
+				for i, n := range path {
+					if n == dep.name {
+						return append(append([]string{}, path[i:]...), dep.name)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep.name); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, name := range names {
+		if state[name] == unvisited {
+			if cycle := visit(name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// Order returns entries sorted so that every entry comes after the entries it depends on, and
+// by priority otherwise. It fails with a CycleError when the dependencies make a cycle.
+func Order(entries []*models.AutoStart) ([]*models.AutoStart, error) {
+	sorted := append([]*models.AutoStart{}, entries...)
+	sortEntries(sorted)
+	deps := dependencies(sorted)
+	if cycle := findCycle(deps); cycle != nil {
+		return nil, CycleError(cycle)
+	}
+	byName := make(map[string]*models.AutoStart, len(sorted))
+	for _, as := range sorted {
+		byName[as.Name] = as
+	}
+	result := make([]*models.AutoStart, 0, len(sorted))
+	added := make(map[string]bool, len(sorted))
+	var visit func(as *models.AutoStart)
+	visit = func(as *models.AutoStart) {
+		if added[as.Name] {
+			return
+		}
+		added[as.Name] = true
+		for _, dep := range deps[as.Name] {
+			if depEntry, ok := byName[dep.name]; ok {
+				visit(depEntry)
+			}
+		}
+		result = append(result, as)
+	}
+	for _, as := range sorted {
+		visit(as)
+	}
+	return result, nil
+}
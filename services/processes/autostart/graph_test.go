@@ -0,0 +1,107 @@
+package autostart
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zhsyourai/URCF-engine/models"
+)
+
+func entry(name string, priority int32, parallel bool, after []string, requires []string) *models.AutoStart {
+	return &models.AutoStart{
+		Priority:     priority,
+		Parallel:     parallel,
+		After:        after,
+		Requires:     requires,
+		ProcessParam: models.ProcessParam{Name: name},
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	entries := []*models.AutoStart{
+		entry("web", 1, true, []string{"cache"}, []string{"db"}),
+		entry("db", 10, false, nil, nil),
+		entry("cache", 5, false, nil, nil),
+	}
+	sortEntries(entries)
+	if entries[0].Name != "db" || entries[1].Name != "cache" || entries[2].Name != "web" {
+		t.Fatalf("sortEntries wrong order %s %s %s", entries[0].Name, entries[1].Name, entries[2].Name)
+	}
+	deps := dependencies(entries)
+	if len(deps["db"]) != 0 {
+		t.Fatalf("db dependencies got %v", deps["db"])
+	}
+	if !reflect.DeepEqual(deps["cache"], []dependency{{name: "db"}}) {
+		t.Fatalf("cache dependencies got %v", deps["cache"])
+	}
+	if !reflect.DeepEqual(deps["web"], []dependency{{name: "db", required: true}, {name: "cache"}}) {
+		t.Fatalf("web dependencies got %v", deps["web"])
+	}
+	if got := dependents(deps)["db"]; len(got) != 2 {
+		t.Fatalf("db dependents got %v", got)
+	}
+	if cycle := findCycle(deps); cycle != nil {
+		t.Fatalf("findCycle got %v on a DAG", cycle)
+	}
+}
+
+func TestFindCycle(t *testing.T) {
+	entries := []*models.AutoStart{
+		entry("a", 0, true, []string{"c"}, nil),
+		entry("b", 0, true, nil, []string{"a"}),
+		entry("c", 0, true, []string{"b", "missing"}, nil),
+		entry("d", 0, true, []string{"a"}, nil),
+	}
+	cycle := findCycle(dependencies(entries))
+	if !reflect.DeepEqual(cycle, []string{"a", "c", "b", "a"}) {
+		t.Fatalf("findCycle got %v", cycle)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	entries := []*models.AutoStart{
+		entry("web", 10, true, nil, []string{"api"}),
+		entry("api", 5, true, []string{"db"}, nil),
+		entry("db", 1, true, nil, nil),
+		entry("tool", 7, true, nil, nil),
+	}
+	ordered, err := Order(entries)
+	if err != nil {
+		t.Fatalf("Order error(%v)", err)
+	}
+	names := make([]string, 0, len(ordered))
+	for _, as := range ordered {
+		names = append(names, as.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"db", "api", "web", "tool"}) {
+		t.Fatalf("Order got %v", names)
+	}
+	entries[2].After = []string{"web"}
+	if _, err = Order(entries); err == nil {
+		t.Fatal("Order should fail on a cycle")
+	}
+}
+
+func TestDependenciesAgainstPriority(t *testing.T) {
+	entries := []*models.AutoStart{
+		entry("a", 10, false, []string{"c"}, nil),
+		entry("b", 5, false, nil, nil),
+		entry("c", 1, false, nil, nil),
+	}
+	sortEntries(entries)
+	deps := dependencies(entries)
+	if cycle := findCycle(deps); cycle != nil {
+		t.Fatalf("findCycle got %v, after against priority is not a cycle", cycle)
+	}
+	ordered, err := Order(entries)
+	if err != nil {
+		t.Fatalf("Order error(%v)", err)
+	}
+	names := make([]string, 0, len(ordered))
+	for _, as := range ordered {
+		names = append(names, as.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"c", "a", "b"}) {
+		t.Fatalf("Order got %v", names)
+	}
+}
@@ -0,0 +1,149 @@
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zhsyourai/URCF-engine/models"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Root is where the cgroup v2 unified hierarchy is mounted.
+const Root = "/sys/fs/cgroup"
+
+const cpuPeriod = 100000
+
+var ErrNotSupport = errors.New("cgroup v2 is not available")
+var ErrInvalidName = errors.New("name is not a valid cgroup name")
+
+// Group is the cgroup v2 of a single managed process.
+type Group struct {
+	Path string
+}
+
+// limitFiles returns the interface files to write, and their content, to apply limits.
+func limitFiles(limits models.ResourceLimits) map[string]string {
+	files := make(map[string]string)
+	if limits.CPUQuota > 0 {
+		files["cpu.max"] = fmt.Sprintf("%d %d", int64(limits.CPUQuota)*cpuPeriod/100, cpuPeriod)
+	}
+	if limits.MemoryMax > 0 {
+		files["memory.max"] = strconv.FormatInt(limits.MemoryMax, 10)
+	}
+	if limits.PidsMax > 0 {
+		files["pids.max"] = strconv.FormatInt(limits.PidsMax, 10)
+	}
+	if limits.IOWeight > 0 {
+		files["io.weight"] = "default " + strconv.FormatUint(uint64(limits.IOWeight), 10)
+	}
+	return files
+}
+
+// controllers returns the controllers limits depends on.
+func controllers(limits models.ResourceLimits) (ret []string) {
+	if limits.CPUQuota > 0 {
+		ret = append(ret, "cpu")
+	}
+	if limits.MemoryMax > 0 {
+		ret = append(ret, "memory")
+	}
+	if limits.PidsMax > 0 {
+		ret = append(ret, "pids")
+	}
+	if limits.IOWeight > 0 {
+		ret = append(ret, "io")
+	}
+	return
+}
+
+func writeFile(dir string, name string, content string) error {
+	return ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644)
+}
+
+// enableControllers makes the controllers available to the children of every cgroup
+// between Root and dir.
+func enableControllers(dir string, names []string) error {
+	rel := strings.Trim(strings.TrimPrefix(dir, Root), "/")
+	current := Root
+	parts := strings.Split(rel, "/")
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		for _, name := range names {
+			err := writeFile(current, "cgroup.subtree_control", "+"+name)
+			if err != nil {
+				return fmt.Errorf("enable controller %s in %s: %v", name, current, err)
+			}
+		}
+		current = path.Join(current, part)
+		if err := os.Mkdir(current, 0755); err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// childDir returns the directory of the cgroup name below parent. The name is escaped, which
+// maps distinct names, such as "a/b" and "a_b", to distinct directories, and a name which would
+// not map to a single child directory, such as "..", is rejected.
+func childDir(parent string, name string) (string, error) {
+	name = url.PathEscape(name)
+	if name == "" || name == "." || name == ".." || strings.ContainsRune(name, 0) {
+		return "", ErrInvalidName
+	}
+	parentDir := path.Join(Root, parent)
+	if parentDir != Root && !strings.HasPrefix(parentDir, Root+"/") {
+		return "", fmt.Errorf("cgroup parent %q is outside %s", parent, Root)
+	}
+	return path.Join(parentDir, name), nil
+}
+
+// Create makes the cgroup name below parent, which is relative to Root, and applies limits.
+func Create(parent string, name string, limits models.ResourceLimits) (*Group, error) {
+	dir, err := childDir(parent, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path.Join(Root, "cgroup.controllers")); err != nil {
+		return nil, ErrNotSupport
+	}
+	err = enableControllers(dir, controllers(limits))
+	if err != nil {
+		return nil, err
+	}
+	g := &Group{Path: dir}
+	for file, content := range limitFiles(limits) {
+		err = writeFile(dir, file, content)
+		if err != nil {
+			g.Remove()
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// Open opens the directory of the cgroup, to start a process directly inside it with
+// SysProcAttr.CgroupFD.
+func (g *Group) Open() (*os.File, error) {
+	return os.OpenFile(g.Path, os.O_RDONLY|syscall.O_DIRECTORY, 0)
+}
+
+// Remove kills whatever is left in the cgroup and deletes it.
+func (g *Group) Remove() (err error) {
+	writeFile(g.Path, "cgroup.kill", "1")
+	for i := 0; i < 10; i++ {
+		err = os.Remove(g.Path)
+		if err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		<-time.After(100 * time.Millisecond)
+	}
+	return err
+}
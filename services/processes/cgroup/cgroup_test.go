@@ -0,0 +1,54 @@
+package cgroup
+
+import (
+	"testing"
+
+	"github.com/zhsyourai/URCF-engine/models"
+)
+
+func TestLimitFiles(t *testing.T) {
+	files := limitFiles(models.ResourceLimits{
+		CPUQuota:  50,
+		MemoryMax: 64 << 20,
+		PidsMax:   32,
+		IOWeight:  200,
+	})
+	expected := map[string]string{
+		"cpu.max":    "50000 100000",
+		"memory.max": "67108864",
+		"pids.max":   "32",
+		"io.weight":  "default 200",
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("Limit files error (len %d not equal %d)", len(files), len(expected))
+	}
+	for k, v := range expected {
+		if files[k] != v {
+			t.Fatalf("Limit files error (%s is %q not %q)", k, files[k], v)
+		}
+	}
+	if len(limitFiles(models.ResourceLimits{})) != 0 {
+		t.Fatalf("%s", "Limit files error (empty limits write files)")
+	}
+}
+
+func TestChildDir(t *testing.T) {
+	dir, err := childDir("urcf", "web/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != Root+"/urcf/web%2Fapi" {
+		t.Fatalf("Child dir error (%s is not %s)", dir, Root+"/urcf/web%2Fapi")
+	}
+	if other, _ := childDir("urcf", "web_api"); other == dir {
+		t.Fatalf("Child dir error (web/api and web_api share %s)", dir)
+	}
+	for _, name := range []string{"", ".", ".."} {
+		if _, err := childDir("urcf", name); err != ErrInvalidName {
+			t.Fatalf("Child dir error (name %q is not rejected)", name)
+		}
+	}
+	if _, err := childDir("../..", "web"); err == nil {
+		t.Fatalf("%s", "Child dir error (parent outside the root is not rejected)")
+	}
+}
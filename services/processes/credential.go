@@ -0,0 +1,194 @@
+package processes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zhsyourai/URCF-engine/models"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var ErrCapabilitiesNeedUser = errors.New("capabilities can only be retained when the process runs as another user")
+
+var capabilities = map[string]uintptr{
+	"CAP_CHOWN":              0,
+	"CAP_DAC_OVERRIDE":       1,
+	"CAP_DAC_READ_SEARCH":    2,
+	"CAP_FOWNER":             3,
+	"CAP_FSETID":             4,
+	"CAP_KILL":               5,
+	"CAP_SETGID":             6,
+	"CAP_SETUID":             7,
+	"CAP_SETPCAP":            8,
+	"CAP_LINUX_IMMUTABLE":    9,
+	"CAP_NET_BIND_SERVICE":   10,
+	"CAP_NET_BROADCAST":      11,
+	"CAP_NET_ADMIN":          12,
+	"CAP_NET_RAW":            13,
+	"CAP_IPC_LOCK":           14,
+	"CAP_IPC_OWNER":          15,
+	"CAP_SYS_MODULE":         16,
+	"CAP_SYS_RAWIO":          17,
+	"CAP_SYS_CHROOT":         18,
+	"CAP_SYS_PTRACE":         19,
+	"CAP_SYS_PACCT":          20,
+	"CAP_SYS_ADMIN":          21,
+	"CAP_SYS_BOOT":           22,
+	"CAP_SYS_NICE":           23,
+	"CAP_SYS_RESOURCE":       24,
+	"CAP_SYS_TIME":           25,
+	"CAP_SYS_TTY_CONFIG":     26,
+	"CAP_MKNOD":              27,
+	"CAP_LEASE":              28,
+	"CAP_AUDIT_WRITE":        29,
+	"CAP_AUDIT_CONTROL":      30,
+	"CAP_SETFCAP":            31,
+	"CAP_MAC_OVERRIDE":       32,
+	"CAP_MAC_ADMIN":          33,
+	"CAP_SYSLOG":             34,
+	"CAP_WAKE_ALARM":         35,
+	"CAP_BLOCK_SUSPEND":      36,
+	"CAP_AUDIT_READ":         37,
+	"CAP_PERFMON":            38,
+	"CAP_BPF":                39,
+	"CAP_CHECKPOINT_RESTORE": 40,
+}
+
+// umaskExecArg0 is the argv[0] of the daemon binary started to run a process with its own
+// umask, followed by the umask in octal, the command and its arguments. The umask is set there,
+// right before the command is executed, since changing the daemon umask would apply to the
+// files the daemon creates meanwhile as well.
+const umaskExecArg0 = "urcf-umask-exec"
+
+func init() {
+	if len(os.Args) < 3 || os.Args[0] != umaskExecArg0 {
+		return
+	}
+	umask, err := parseUmask(os.Args[1])
+	if err == nil {
+		syscall.Umask(umask)
+		err = syscall.Exec(os.Args[2], os.Args[2:], os.Environ())
+	}
+	fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[2], err)
+	os.Exit(127)
+}
+
+// umaskCommand returns the command and arguments starting argv with umask, see umaskExecArg0.
+// The daemon binary is started through /proc/self/exe, which still works once it is replaced.
+func umaskCommand(umask int, argv []string) (string, []string) {
+	return "/proc/self/exe", append([]string{umaskExecArg0, fmt.Sprintf("%03o", umask)}, argv...)
+}
+
+func parseCapabilities(names []string) ([]uintptr, error) {
+	ret := make([]uintptr, 0, len(names))
+	for _, name := range names {
+		upper := strings.ToUpper(name)
+		if !strings.HasPrefix(upper, "CAP_") {
+			upper = "CAP_" + upper
+		}
+		capability, ok := capabilities[upper]
+		if !ok {
+			return nil, fmt.Errorf("not a valid Capability: %q", name)
+		}
+		ret = append(ret, capability)
+	}
+	return ret, nil
+}
+
+// parseUmask parses an octal umask, -1 meaning the daemon umask is inherited.
+func parseUmask(umask string) (int, error) {
+	if umask == "" {
+		return -1, nil
+	}
+	ret, err := strconv.ParseUint(umask, 8, 32)
+	if err != nil || ret > 0777 {
+		return -1, fmt.Errorf("not a valid Umask: %q", umask)
+	}
+	return int(ret), nil
+}
+
+func lookupUser(name string) (uid uint32, gid uint32, groups []string, err error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		id, e := strconv.ParseUint(name, 10, 32)
+		if e != nil {
+			return
+		}
+		u, err = user.LookupId(name)
+		if err != nil {
+			// A numeric uid doesn't have to exist in the user database.
+			return uint32(id), uint32(id), nil, nil
+		}
+	}
+	id, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return
+	}
+	primary, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return
+	}
+	groups, _ = u.GroupIds()
+	return uint32(id), uint32(primary), groups, nil
+}
+
+func lookupGroup(name string) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(g.Gid, 10, 32)
+	return uint32(id), err
+}
+
+// buildCredential resolves the identity a process runs with. A nil Credential means the
+// process runs as the daemon.
+func buildCredential(c models.ProcessCredential) (*syscall.Credential, []uintptr, error) {
+	caps, err := parseCapabilities(c.Capabilities)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.User == "" && c.Group == "" {
+		if len(caps) != 0 {
+			return nil, nil, ErrCapabilitiesNeedUser
+		}
+		return nil, nil, nil
+	}
+
+	credential := &syscall.Credential{
+		Uid: uint32(syscall.Getuid()),
+		Gid: uint32(syscall.Getgid()),
+	}
+	var groups []string
+	if c.User != "" {
+		credential.Uid, credential.Gid, groups, err = lookupUser(c.User)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if len(caps) != 0 {
+		return nil, nil, ErrCapabilitiesNeedUser
+	}
+	if c.Group != "" {
+		credential.Gid, err = lookupGroup(c.Group)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(c.Groups) != 0 {
+		groups = c.Groups
+	}
+	for _, group := range groups {
+		gid, err := lookupGroup(group)
+		if err != nil {
+			return nil, nil, err
+		}
+		credential.Groups = append(credential.Groups, gid)
+	}
+	return credential, caps, nil
+}
@@ -0,0 +1,81 @@
+package processes
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/zhsyourai/URCF-engine/models"
+)
+
+func TestBuildCredential(t *testing.T) {
+	credential, caps, err := buildCredential(models.ProcessCredential{
+		User:         "65534",
+		Group:        "65533",
+		Groups:       []string{"100", "101"},
+		Capabilities: []string{"net_bind_service", "CAP_NET_RAW"},
+	})
+	if err != nil {
+		t.Fatalf("%s(%v)", "Build credential error", err)
+	}
+	if credential.Uid != 65534 || credential.Gid != 65533 || len(credential.Groups) != 2 {
+		t.Fatalf("Build credential error (%v)", credential)
+	}
+	if len(caps) != 2 || caps[0] != 10 || caps[1] != 13 {
+		t.Fatalf("Build credential error (caps %v)", caps)
+	}
+
+	credential, _, err = buildCredential(models.ProcessCredential{})
+	if err != nil || credential != nil {
+		t.Fatalf("Build credential error (%v, %v)", credential, err)
+	}
+
+	_, _, err = buildCredential(models.ProcessCredential{Capabilities: []string{"CAP_KILL"}})
+	if err != ErrCapabilitiesNeedUser {
+		t.Fatalf("Build credential error (%v)", err)
+	}
+
+	_, _, err = buildCredential(models.ProcessCredential{User: "65534", Capabilities: []string{"CAP_NOPE"}})
+	if err == nil {
+		t.Fatalf("%s", "Build credential error (unknown capability accepted)")
+	}
+}
+
+func TestParseUmask(t *testing.T) {
+	umask, err := parseUmask("027")
+	if err != nil || umask != 027 {
+		t.Fatalf("Parse umask error (%o, %v)", umask, err)
+	}
+	umask, err = parseUmask("")
+	if err != nil || umask != -1 {
+		t.Fatalf("Parse umask error (%d, %v)", umask, err)
+	}
+	_, err = parseUmask("999")
+	if err == nil {
+		t.Fatalf("%s", "Parse umask error (invalid umask accepted)")
+	}
+}
+
+func TestUmaskCommand(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skipf("no shell (%v)", err)
+	}
+	_, argv := umaskCommand(027, []string{"/bin/sh", "-c", "umask"})
+	// The test binary runs the init of this package as the daemon would.
+	cmd := exec.Command(os.Args[0])
+	cmd.Args = argv
+	old := syscall.Umask(022)
+	defer syscall.Umask(old)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("%s(%v)", "Umask exec error", err)
+	}
+	if strings.TrimSpace(string(out)) != "0027" {
+		t.Fatalf("Umask exec error (%s not equal 0027)", strings.TrimSpace(string(out)))
+	}
+	if current := syscall.Umask(022); current != 022 {
+		t.Fatalf("Umask exec error (daemon umask %o changed)", current)
+	}
+}
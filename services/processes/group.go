@@ -0,0 +1,142 @@
+package processes
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zhsyourai/URCF-engine/services/processes/types"
+)
+
+var GroupNotExist = errors.New("process group not exist")
+
+// GroupError holds the errors of the members a group operation failed on, by name.
+type GroupError map[string]error
+
+func (e GroupError) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	messages := make([]string, 0, len(e))
+	for _, name := range names {
+		messages = append(messages, name+": "+e[name].Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// members returns snapshots of the processes of group sorted by name.
+func (s *processesService) members(group string) []*types.Process {
+	members := []*types.Process{}
+	s.procMap.Range(func(key, value interface{}) bool {
+		pp := value.(*processPair)
+		for _, g := range pp.proc.Groups {
+			if g == group {
+				members = append(members, pp.snapshot())
+				break
+			}
+		}
+		return true
+	})
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+	return members
+}
+
+func (s *processesService) ListGroups() []*types.ProcessGroup {
+	groups := make(map[string]*types.ProcessGroup)
+	s.procMap.Range(func(key, value interface{}) bool {
+		for _, g := range value.(*processPair).proc.Groups {
+			if groups[g] == nil {
+				groups[g] = types.NewProcessGroup(g)
+			}
+		}
+		return true
+	})
+	result := make([]*types.ProcessGroup, 0, len(groups))
+	for name := range groups {
+		group, err := s.FindGroup(name)
+		if err == nil {
+			result = append(result, group)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (s *processesService) FindGroup(group string) (*types.ProcessGroup, error) {
+	members := s.members(group)
+	if len(members) == 0 {
+		return nil, GroupNotExist
+	}
+	result := types.NewProcessGroup(group)
+	for _, proc := range members {
+		result.Add(proc)
+	}
+	return result, nil
+}
+
+// forGroup runs action on every member of group concurrently, and collects the errors.
+func (s *processesService) forGroup(group string, action func(proc *types.Process) error) error {
+	members := s.members(group)
+	if len(members) == 0 {
+		return GroupNotExist
+	}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	errs := GroupError{}
+	for _, proc := range members {
+		wg.Add(1)
+		go func(proc *types.Process) {
+			defer wg.Done()
+			if err := action(proc); err != nil {
+				lock.Lock()
+				errs[proc.Name] = err
+				lock.Unlock()
+			}
+		}(proc)
+	}
+	wg.Wait()
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// StartGroup starts the members of group that are not running.
+func (s *processesService) StartGroup(group string) error {
+	return s.forGroup(group, func(proc *types.Process) error {
+		if proc.Status == types.Running {
+			return nil
+		}
+		return s.Start(proc.Name)
+	})
+}
+
+// StopGroup stops the running members of group.
+func (s *processesService) StopGroup(group string, by string) (map[string]types.StopResult, error) {
+	var lock sync.Mutex
+	results := make(map[string]types.StopResult)
+	err := s.forGroup(group, func(proc *types.Process) error {
+		if proc.Status != types.Running {
+			return nil
+		}
+		result, err := s.Stop(proc.Name, by)
+		lock.Lock()
+		results[proc.Name] = result
+		lock.Unlock()
+		return err
+	})
+	return results, err
+}
+
+func (s *processesService) RestartGroup(group string, by string) error {
+	return s.forGroup(group, func(proc *types.Process) error {
+		return s.Restart(proc.Name, by)
+	})
+}
@@ -0,0 +1,47 @@
+package processes
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/processes/types"
+)
+
+func TestGroups(t *testing.T) {
+	s := &processesService{}
+	for _, param := range []models.ProcessParam{
+		{Name: "web", Groups: []string{"app", "front"}},
+		{Name: "api", Groups: []string{"app"}},
+		{Name: "tool"},
+	} {
+		s.procMap.Store(param.Name, &processPair{proc: &types.Process{ProcessParam: param, Status: types.Running}})
+	}
+
+	groups := s.ListGroups()
+	if len(groups) != 2 || groups[0].Name != "app" || groups[1].Name != "front" {
+		t.Fatalf("%s(%s)", "ListGroups error", "groups not equ")
+	}
+	app, err := s.FindGroup("app")
+	if err != nil {
+		t.Fatalf("%s(%s)", "FindGroup error", fmt.Sprint(err))
+	}
+	if len(app.Members) != 2 || app.Members[0] != "api" || app.Statuses["Running"] != 2 {
+		t.Fatalf("%s(%s)", "FindGroup error", "members not equ")
+	}
+	if _, err = s.FindGroup("none"); err != GroupNotExist {
+		t.Fatalf("FindGroup error (%v not equal %v)", err, GroupNotExist)
+	}
+
+	err = s.forGroup("app", func(proc *types.Process) error {
+		if proc.Name == "web" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	groupErr, ok := err.(GroupError)
+	if !ok || len(groupErr) != 1 || groupErr["web"] == nil {
+		t.Fatalf("%s(%s)", "forGroup error", fmt.Sprint(err))
+	}
+}
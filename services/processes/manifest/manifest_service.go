@@ -0,0 +1,398 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+	"github.com/zhsyourai/URCF-engine/services/processes"
+	"github.com/zhsyourai/URCF-engine/services/processes/autostart"
+	"gopkg.in/yaml.v2"
+)
+
+// Action is what reconciling does to a process.
+type Action string
+
+const (
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+	// Conflict is reported for a manifest naming a process that was not created from a manifest,
+	// such a process is left alone.
+	Conflict Action = "conflict"
+)
+
+// debounce is how long the directory must stay quiet before changes are reconciled, editors
+// often write a file in several steps.
+const debounce = 500 * time.Millisecond
+
+// Change is a difference between the manifests and the managed processes. Error is why
+// reconciling it failed, if it did.
+type Change struct {
+	Name   string                  `json:"name"`
+	Action Action                  `json:"action"`
+	Old    *models.ProcessManifest `json:"old,omitempty"`
+	New    *models.ProcessManifest `json:"new,omitempty"`
+	Error  string                  `json:"error,omitempty"`
+}
+
+// Failed reports whether reconciling any of changes failed.
+func Failed(changes []Change) bool {
+	for _, change := range changes {
+		if change.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+type Service interface {
+	services.ServiceLifeCycle
+	// Diff returns the changes Reconcile would apply, without applying them.
+	Diff() ([]Change, error)
+	Reconcile() ([]Change, error)
+}
+
+type manifestService struct {
+	services.InitHelper
+	lock sync.Mutex
+	path string
+	// managed holds the manifests of the processes created from one by name. It is saved in
+	// managedFile, so the processes stay managed across daemon restarts.
+	managed     map[string]*models.ProcessManifest
+	managedFile string
+	// conflicts holds the conflicts already reported, so that they are only logged once.
+	conflicts        map[string]bool
+	processesService processes.Service
+	watcher          *fsnotify.Watcher
+	stop             chan struct{}
+}
+
+var instance *manifestService
+var once sync.Once
+
+func GetInstance() Service {
+	once.Do(func() {
+		conf := global_configuration.GetGlobalConfig().Get()
+		instance = &manifestService{
+			path:             conf.Processes.ManifestPath,
+			managed:          make(map[string]*models.ProcessManifest),
+			managedFile:      path.Join(conf.Sys.WorkPath, "manifests.json"),
+			conflicts:        make(map[string]bool),
+			processesService: processes.GetInstance(),
+		}
+	})
+	return instance
+}
+
+func (s *manifestService) Initialize(arguments ...interface{}) error {
+	return s.CallInitialize(func() error {
+		if _, err := os.Stat(s.path); os.IsNotExist(err) {
+			os.MkdirAll(s.path, 0770)
+		}
+		err := s.loadManaged()
+		if err != nil {
+			return err
+		}
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		err = watcher.Add(s.path)
+		if err != nil {
+			watcher.Close()
+			return err
+		}
+		s.watcher = watcher
+		s.stop = make(chan struct{})
+		go s.run()
+		return nil
+	})
+}
+
+func (s *manifestService) UnInitialize(arguments ...interface{}) error {
+	return s.CallUnInitialize(func() error {
+		close(s.stop)
+		return s.watcher.Close()
+	})
+}
+
+// run reconciles at start, after the directory changed and every manifest interval.
+func (s *manifestService) run() {
+	var tick, changed <-chan time.Time
+	interval := global_configuration.GetGlobalConfig().Get().Processes.ManifestInterval
+	if interval > 0 {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	s.reconcileAndLog()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			if isManifest(event.Name) {
+				changed = time.After(debounce)
+			}
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warnf("manifest watch error: %v", err)
+		case <-changed:
+			changed = nil
+			s.reconcileAndLog()
+		case <-tick:
+			s.reconcileAndLog()
+		}
+	}
+}
+
+func (s *manifestService) reconcileAndLog() {
+	changes, err := s.Reconcile()
+	if err != nil {
+		log.Errorf("manifest reconcile error: %v", err)
+		return
+	}
+	for _, change := range changes {
+		// Conflicts stay until resolved by hand, Reconcile logs them once.
+		if change.Action != Conflict {
+			log.Infof("manifest reconcile: %s %s", change.Action, change.Name)
+		}
+	}
+}
+
+// loadManaged reads the manifests of the processes managed before the daemon restarted.
+func (s *manifestService) loadManaged() error {
+	content, err := ioutil.ReadFile(s.managedFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, &s.managed)
+}
+
+// saveManaged writes the manifests of the managed processes. The caller must hold the lock.
+func (s *manifestService) saveManaged() error {
+	if s.managedFile == "" {
+		return nil
+	}
+	content, err := json.Marshal(s.managed)
+	if err != nil {
+		return err
+	}
+	// The parameters hold the environment of the processes, which may be secret.
+	return ioutil.WriteFile(s.managedFile, content, 0600)
+}
+
+func isManifest(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	return (ext == ".yml" || ext == ".yaml") && !strings.HasPrefix(filepath.Base(file), ".")
+}
+
+// load reads every manifest of the directory. The files that can't be read are returned
+// apart, the processes they declared before are kept as they are.
+func (s *manifestService) load() (map[string]*models.ProcessManifest, map[string]bool, error) {
+	files, err := ioutil.ReadDir(s.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifests := make(map[string]*models.ProcessManifest)
+	broken := make(map[string]bool)
+	for _, info := range files {
+		if info.IsDir() || !isManifest(info.Name()) {
+			continue
+		}
+		file := filepath.Join(s.path, info.Name())
+		manifest, err := readManifest(file)
+		if err == nil {
+			if other, ok := manifests[manifest.Name]; ok {
+				err = fmt.Errorf("process %s is already declared in %s", manifest.Name, other.File)
+			}
+		}
+		if err != nil {
+			log.Errorf("manifest %s error: %v", file, err)
+			broken[file] = true
+			continue
+		}
+		manifests[manifest.Name] = manifest
+	}
+	return manifests, broken, nil
+}
+
+func readManifest(file string) (*models.ProcessManifest, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &models.ProcessManifest{}
+	err = yaml.UnmarshalStrict(data, manifest)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Name == "" || manifest.Cmd == "" {
+		return nil, fmt.Errorf("name and cmd can't be empty")
+	}
+	manifest.File = file
+	return manifest, nil
+}
+
+// same reports whether a and b declare the same process, wherever they are declared.
+func same(a *models.ProcessManifest, b *models.ProcessManifest) bool {
+	return a.Disable == b.Disable && reflect.DeepEqual(a.AutoStart, b.AutoStart)
+}
+
+// diff compares the manifests with the managed processes. The caller must hold the lock.
+func (s *manifestService) diff() ([]Change, map[string]*models.ProcessManifest, error) {
+	manifests, broken, err := s.load()
+	if err != nil {
+		return nil, nil, err
+	}
+	changes := []Change{}
+	for name, old := range s.managed {
+		if _, ok := manifests[name]; !ok && !broken[old.File] {
+			changes = append(changes, Change{Name: name, Action: Delete, Old: old})
+		}
+	}
+	entries := make([]*models.AutoStart, 0, len(manifests))
+	for _, manifest := range manifests {
+		entries = append(entries, &manifest.AutoStart)
+	}
+	ordered, err := autostart.Order(entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, as := range ordered {
+		manifest := manifests[as.Name]
+		old, ok := s.managed[as.Name]
+		exists := s.processesService.FindByName(as.Name) != nil
+		switch {
+		case !ok && exists:
+			changes = append(changes, Change{Name: as.Name, Action: Conflict, New: manifest})
+		case !ok:
+			changes = append(changes, Change{Name: as.Name, Action: Create, New: manifest})
+		case !same(old, manifest):
+			changes = append(changes, Change{Name: as.Name, Action: Update, Old: old, New: manifest})
+		case !exists:
+			// Managed before the daemon restarted, but not adopted.
+			changes = append(changes, Change{Name: as.Name, Action: Create, New: manifest})
+		}
+	}
+	return changes, manifests, nil
+}
+
+func (s *manifestService) Diff() ([]Change, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	changes, _, err := s.diff()
+	return changes, err
+}
+
+// Reconcile applies the changes of the manifests, deleted processes first then the others
+// in dependency order. A process requiring one that failed to start is not started. The
+// changes that failed hold their error.
+func (s *manifestService) Reconcile() ([]Change, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	changes, manifests, err := s.diff()
+	if err != nil {
+		return nil, err
+	}
+	failed := make(map[string]bool)
+	conflicts := make(map[string]bool)
+	for i := range changes {
+		change := &changes[i]
+		switch change.Action {
+		case Delete:
+			err = s.remove(change.Name)
+			if err == nil {
+				delete(s.managed, change.Name)
+			}
+		case Update:
+			err = s.remove(change.Name)
+			if err != nil {
+				break
+			}
+			delete(s.managed, change.Name)
+			fallthrough
+		case Create:
+			err = s.apply(change.New, failed)
+			if err == nil {
+				s.managed[change.Name] = change.New
+			}
+		case Conflict:
+			err = processes.ProcessExist
+			conflicts[change.Name] = true
+		}
+		if err != nil {
+			failed[change.Name] = true
+			change.Error = err.Error()
+			if change.Action != Conflict || !s.conflicts[change.Name] {
+				log.Errorf("manifest %s %s error: %v", change.Action, change.Name, err)
+			}
+		}
+	}
+	s.conflicts = conflicts
+	// Keep track of manifests moved to another file.
+	for name, manifest := range manifests {
+		if managed, ok := s.managed[name]; ok && same(managed, manifest) {
+			s.managed[name] = manifest
+		}
+	}
+	if err = s.saveManaged(); err != nil {
+		log.Errorf("manifest save error: %v", err)
+	}
+	return changes, nil
+}
+
+// remove stops a managed process and forgets it.
+func (s *manifestService) remove(name string) error {
+	_, err := s.processesService.Stop(name, "manifest")
+	if err != nil && err != processes.ProcessNotRun && err != processes.ProcessNotExist {
+		return err
+	}
+	err = s.processesService.Remove(name)
+	if err == processes.ProcessNotExist {
+		return nil
+	}
+	return err
+}
+
+// apply prepares the process of manifest and starts it unless it is disabled.
+func (s *manifestService) apply(manifest *models.ProcessManifest, failed map[string]bool) error {
+	for _, name := range manifest.Requires {
+		if failed[name] {
+			return fmt.Errorf("required process %s failed", name)
+		}
+	}
+	param := manifest.ProcessParam
+	if param.StopTimeout == 0 {
+		param.StopTimeout = manifest.StopDelay
+	}
+	_, err := s.processesService.Prepare(param)
+	if err != nil {
+		return err
+	}
+	if manifest.Disable {
+		return nil
+	}
+	return s.processesService.Start(manifest.Name)
+}
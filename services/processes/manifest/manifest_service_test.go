@@ -0,0 +1,120 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/processes"
+	"github.com/zhsyourai/URCF-engine/services/processes/types"
+)
+
+// fakeProcesses only knows the processes it is given.
+type fakeProcesses struct {
+	processes.Service
+	names map[string]bool
+}
+
+func (f *fakeProcesses) FindByName(name string) *types.Process {
+	if f.names[name] {
+		return &types.Process{}
+	}
+	return nil
+}
+
+func writeManifest(t *testing.T, dir string, file string, content string) {
+	err := ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("write manifest error(%v)", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatalf("TempDir error(%v)", err)
+	}
+	defer os.RemoveAll(dir)
+	writeManifest(t, dir, "web.yml", "name: web\ncmd: /bin/web\nrequires: [db]\n")
+	writeManifest(t, dir, "db.yaml", "name: db\ncmd: /bin/db\nargs: [--new]\n")
+	writeManifest(t, dir, "api.yml", "name: api\ncmd: /bin/api\n")
+	writeManifest(t, dir, "broken.yml", "name: [\n")
+	writeManifest(t, dir, "notes.txt", "name: notes\n")
+
+	s := &manifestService{
+		path: dir,
+		managed: map[string]*models.ProcessManifest{
+			"db": {AutoStart: models.AutoStart{ProcessParam: models.ProcessParam{Name: "db", Cmd: "/bin/db"}},
+				File: filepath.Join(dir, "db.yaml")},
+			"old": {AutoStart: models.AutoStart{ProcessParam: models.ProcessParam{Name: "old", Cmd: "/bin/old"}},
+				File: filepath.Join(dir, "old.yml")},
+			"kept": {AutoStart: models.AutoStart{ProcessParam: models.ProcessParam{Name: "kept", Cmd: "/bin/kept"}},
+				File: filepath.Join(dir, "broken.yml")},
+		},
+		processesService: &fakeProcesses{names: map[string]bool{"api": true}},
+	}
+	changes, err := s.Diff()
+	if err != nil {
+		t.Fatalf("Diff error(%v)", err)
+	}
+	actions := make(map[string]Action)
+	order := make(map[string]int)
+	for i, change := range changes {
+		actions[change.Name] = change.Action
+		order[change.Name] = i
+	}
+	expected := map[string]Action{"old": Delete, "db": Update, "web": Create, "api": Conflict}
+	if len(actions) != len(expected) {
+		t.Fatalf("Diff got %v", actions)
+	}
+	for name, action := range expected {
+		if actions[name] != action {
+			t.Fatalf("Diff %s got %s, want %s", name, actions[name], action)
+		}
+	}
+	if order["db"] > order["web"] {
+		t.Fatalf("Diff should update db before creating web, got %v", changes)
+	}
+}
+
+func TestDiffAfterRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatalf("TempDir error(%v)", err)
+	}
+	defer os.RemoveAll(dir)
+	writeManifest(t, dir, "web.yml", "name: web\ncmd: /bin/web\n")
+	writeManifest(t, dir, "api.yml", "name: api\ncmd: /bin/api\n")
+
+	s := &manifestService{
+		path:             dir,
+		managed:          make(map[string]*models.ProcessManifest),
+		managedFile:      filepath.Join(dir, "managed.json"),
+		processesService: &fakeProcesses{names: map[string]bool{"web": true}},
+	}
+	s.managed["web"], _ = readManifest(filepath.Join(dir, "web.yml"))
+	s.managed["api"], _ = readManifest(filepath.Join(dir, "api.yml"))
+	if err = s.saveManaged(); err != nil {
+		t.Fatalf("saveManaged error(%v)", err)
+	}
+
+	// web was adopted by the new daemon, api died with the old one.
+	restarted := &manifestService{
+		path:             dir,
+		managed:          make(map[string]*models.ProcessManifest),
+		managedFile:      s.managedFile,
+		processesService: s.processesService,
+	}
+	if err = restarted.loadManaged(); err != nil {
+		t.Fatalf("loadManaged error(%v)", err)
+	}
+	changes, err := restarted.Diff()
+	if err != nil {
+		t.Fatalf("Diff error(%v)", err)
+	}
+	if len(changes) != 1 || changes[0].Name != "api" || changes[0].Action != Create {
+		t.Fatalf("Diff after restart got %v", changes)
+	}
+}
@@ -0,0 +1,250 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/processes/cgroup"
+	"github.com/zhsyourai/URCF-engine/services/processes/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	DefaultProbeInterval         = 10
+	DefaultProbeTimeout          = 1
+	DefaultProbeFailureThreshold = 3
+)
+
+var ErrProbeNotServing = errors.New("service is not serving")
+
+// probeClient doesn't follow redirects, so that a 3xx succeeds as documented and a probe never
+// ends up checking another host.
+var probeClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// probeEnv is what the exec probes of a process run with: its work dir, its credential and its
+// cgroup, so that a probe can do no more than the process.
+type probeEnv struct {
+	workDir string
+	sys     syscall.SysProcAttr
+	cgroup  *cgroup.Group
+	// err is why the credential of the process can't be applied, exec probes fail with it.
+	err error
+}
+
+// newProbeEnv returns the probe env of pp. The caller must hold pp.lock.
+func newProbeEnv(pp *processPair) probeEnv {
+	env := probeEnv{
+		workDir: pp.proc.WorkDir,
+		cgroup:  pp.cgroup,
+	}
+	env.sys.Credential, env.sys.AmbientCaps, env.err = buildCredential(pp.proc.Credential)
+	return env
+}
+
+// resolveProbe returns probe with its defaults filled in, or nil when probe is nil.
+func resolveProbe(probe *models.Probe) (*models.Probe, error) {
+	if probe == nil {
+		return nil, nil
+	}
+	resolved := *probe
+	switch resolved.Type {
+	case models.ProbeExec:
+		if len(resolved.Command) == 0 {
+			return nil, errors.New("exec probe needs a command")
+		}
+	case models.ProbeTCP, models.ProbeGRPC:
+		if resolved.Address == "" {
+			return nil, fmt.Errorf("%s probe needs an address", resolved.Type)
+		}
+	case models.ProbeHTTP:
+		if resolved.URL == "" {
+			return nil, errors.New("http probe needs an url")
+		}
+	default:
+		return nil, fmt.Errorf("unknown probe type %q", resolved.Type)
+	}
+	if resolved.InitialDelay < 0 {
+		resolved.InitialDelay = 0
+	}
+	if resolved.Interval <= 0 {
+		resolved.Interval = DefaultProbeInterval
+	}
+	if resolved.Timeout <= 0 {
+		resolved.Timeout = DefaultProbeTimeout
+	}
+	if resolved.FailureThreshold <= 0 {
+		resolved.FailureThreshold = DefaultProbeFailureThreshold
+	}
+	return &resolved, nil
+}
+
+// check runs probe once against a process, exec probes running in env.
+func check(ctx context.Context, probe *models.Probe, env probeEnv) error {
+	switch probe.Type {
+	case models.ProbeExec:
+		if env.err != nil {
+			return env.err
+		}
+		sys := env.sys
+		if env.cgroup != nil {
+			dir, err := env.cgroup.Open()
+			if err != nil {
+				return err
+			}
+			defer dir.Close()
+			sys.UseCgroupFD = true
+			sys.CgroupFD = int(dir.Fd())
+		}
+		cmd := exec.CommandContext(ctx, probe.Command[0], probe.Command[1:]...)
+		cmd.Dir = env.workDir
+		cmd.SysProcAttr = &sys
+		return cmd.Run()
+	case models.ProbeTCP:
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", probe.Address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case models.ProbeHTTP:
+		req, err := http.NewRequest(http.MethodGet, probe.URL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := probeClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return fmt.Errorf("http status %d", resp.StatusCode)
+		}
+		return nil
+	case models.ProbeGRPC:
+		conn, err := grpc.DialContext(ctx, probe.Address, grpc.WithInsecure(), grpc.WithBlock())
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{
+			Service: probe.Service,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+			return ErrProbeNotServing
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown probe type %q", probe.Type)
+}
+
+// runProbe checks a process with probe until it exits, starting after the initial delay. It
+// calls onChange with true on the first success after a failure or since the start, and with
+// false once the probe failed FailureThreshold times in a row.
+func runProbe(pp *processPair, probe *models.Probe, kind string, env probeEnv, onChange func(ok bool)) {
+	if probe.InitialDelay > 0 {
+		select {
+		case <-pp.ExitingChan:
+			return
+		case <-time.After(time.Duration(probe.InitialDelay) * time.Second):
+		}
+	}
+	ticker := time.NewTicker(time.Duration(probe.Interval) * time.Second)
+	defer ticker.Stop()
+	failures := 0
+	known, ok := false, false
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(probe.Timeout)*time.Second)
+		err := check(ctx, probe, env)
+		cancel()
+		if err == nil {
+			failures = 0
+			if !known || !ok {
+				known, ok = true, true
+				onChange(true)
+			}
+		} else {
+			failures++
+			log.Debugf("process %s %s probe failed(%d/%d): %v", pp.proc.Name, kind, failures,
+				probe.FailureThreshold, err)
+			if failures >= probe.FailureThreshold && (!known || ok) {
+				known, ok = true, false
+				log.Warnf("process %s %s probe failed %d times: %v", pp.proc.Name, kind, failures, err)
+				onChange(false)
+			}
+		}
+		select {
+		case <-pp.ExitingChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// startProbes starts the liveness and readiness probes of a process that just started. The
+// caller must hold pp.lock.
+func (s *processesService) startProbes(pp *processPair) {
+	env := newProbeEnv(pp)
+	if pp.readiness == nil {
+		pp.markReady(true)
+	} else {
+		go runProbe(pp, pp.readiness, "readiness", env, func(ok bool) {
+			s.setReady(pp, ok)
+		})
+	}
+	if pp.liveness != nil {
+		go runProbe(pp, pp.liveness, "liveness", env, func(ok bool) {
+			if !ok {
+				s.terminateUnhealthy(pp)
+			}
+		})
+	}
+}
+
+func (s *processesService) setReady(pp *processPair, ready bool) {
+	pp.lock.Lock()
+	defer pp.lock.Unlock()
+	pp.markReady(ready)
+}
+
+// markReady records whether the process of pp is ready. The caller must hold pp.lock.
+func (pp *processPair) markReady(ready bool) {
+	pp.proc.Ready = ready
+	if ready {
+		pp.readyOnce.Do(func() {
+			close(pp.readyChan)
+		})
+	}
+}
+
+// terminateUnhealthy stops a process whose liveness probe failed. Its watch is kept, so its
+// restart policy applies as if it had exited on its own, and it counts as a failure whatever
+// its exit code, see handleDeath.
+func (s *processesService) terminateUnhealthy(pp *processPair) {
+	pp.lock.Lock()
+	defer pp.lock.Unlock()
+	if pp.proc.Status != types.Running {
+		return
+	}
+	log.Warnf("process %s is not alive, terminating it.", pp.proc.Name)
+	pp.unhealthy = true
+	_, err := s.terminate(pp, "liveness probe")
+	if err != nil {
+		log.Warnf("process %s terminate error: %v", pp.proc.Name, err)
+	}
+}
@@ -0,0 +1,85 @@
+package processes
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zhsyourai/URCF-engine/models"
+)
+
+func TestResolveProbe(t *testing.T) {
+	probe, err := resolveProbe(&models.Probe{Type: models.ProbeTCP, Address: "127.0.0.1:80"})
+	if err != nil {
+		t.Fatalf("%s(%s)", "resolveProbe error", fmt.Sprint(err))
+	}
+	if probe.Interval != DefaultProbeInterval || probe.Timeout != DefaultProbeTimeout ||
+		probe.FailureThreshold != DefaultProbeFailureThreshold {
+		t.Fatalf("resolveProbe error (%v not equal the defaults)", *probe)
+	}
+	probe, err = resolveProbe(&models.Probe{Type: models.ProbeTCP, Address: "127.0.0.1:80", InitialDelay: -1})
+	if err != nil || probe.InitialDelay != 0 {
+		t.Fatalf("%s(%s)", "resolveProbe error", "negative initial delay kept")
+	}
+	if probe, _ = resolveProbe(nil); probe != nil {
+		t.Fatalf("resolveProbe error (%v not equal %v)", probe, nil)
+	}
+	for _, p := range []models.Probe{{Type: "ping"}, {Type: models.ProbeExec}, {Type: models.ProbeHTTP},
+		{Type: models.ProbeGRPC}} {
+		if _, err = resolveProbe(&p); err == nil {
+			t.Fatalf("resolveProbe error (%v accepted)", p)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	env := probeEnv{workDir: "."}
+	if err := check(ctx, &models.Probe{Type: models.ProbeExec, Command: []string{"true"}}, env); err != nil {
+		t.Fatalf("%s(%s)", "Exec probe error", fmt.Sprint(err))
+	}
+	if err := check(ctx, &models.Probe{Type: models.ProbeExec, Command: []string{"false"}}, env); err == nil {
+		t.Fatalf("%s", "Exec probe error (false succeeded)")
+	}
+	failed := probeEnv{workDir: ".", err: ErrCapabilitiesNeedUser}
+	if err := check(ctx, &models.Probe{Type: models.ProbeExec, Command: []string{"true"}}, failed); err == nil {
+		t.Fatalf("%s", "Exec probe error (run without the credential of the process)")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s(%s)", "Listen error", fmt.Sprint(err))
+	}
+	addr := l.Addr().String()
+	if err = check(ctx, &models.Probe{Type: models.ProbeTCP, Address: addr}, env); err != nil {
+		t.Fatalf("%s(%s)", "TCP probe error", fmt.Sprint(err))
+	}
+	l.Close()
+	if err = check(ctx, &models.Probe{Type: models.ProbeTCP, Address: addr}, env); err == nil {
+		t.Fatalf("%s", "TCP probe error (closed port succeeded)")
+	}
+
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status == http.StatusFound {
+			// Followed, the probe would fail to connect.
+			w.Header().Set("Location", "http://127.0.0.1:1/")
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	if err = check(ctx, &models.Probe{Type: models.ProbeHTTP, URL: server.URL}, env); err != nil {
+		t.Fatalf("%s(%s)", "HTTP probe error", fmt.Sprint(err))
+	}
+	status = http.StatusFound
+	if err = check(ctx, &models.Probe{Type: models.ProbeHTTP, URL: server.URL}, env); err != nil {
+		t.Fatalf("%s(%s)", "Redirect HTTP probe error", fmt.Sprint(err))
+	}
+	status = http.StatusServiceUnavailable
+	if err = check(ctx, &models.Probe{Type: models.ProbeHTTP, URL: server.URL}, env); err == nil {
+		t.Fatalf("%s", "HTTP probe error (unavailable status succeeded)")
+	}
+}
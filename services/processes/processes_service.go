@@ -1,58 +1,151 @@
 package processes
 
 import (
+	"bufio"
 	"errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/repositories"
+	"github.com/zhsyourai/URCF-engine/repositories/process_run"
 	"github.com/zhsyourai/URCF-engine/services"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
 	logservice "github.com/zhsyourai/URCF-engine/services/log"
+	"github.com/zhsyourai/URCF-engine/services/processes/cgroup"
 	"github.com/zhsyourai/URCF-engine/services/processes/types"
 	"github.com/zhsyourai/URCF-engine/services/processes/watchdog"
+	"io"
 	"os"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 )
 
 var ProcessExist = errors.New("process exist")
 var ProcessNotExist = errors.New("process not exist")
 var ProcessNotRun = errors.New("process does not run")
+var ProcessRunning = errors.New("process is running")
+var ProcessAdopted = errors.New("process is adopted, its stdio is gone")
+var ProcessReplaced = errors.New("process was prepared again meanwhile")
+
+// DefaultStopTimeout is the grace period a process has to exit after its stop signal
+// when ProcessParam.StopTimeout is not set.
+const DefaultStopTimeout = 10 * time.Second
 
 type Service interface {
 	services.ServiceLifeCycle
-	Prepare(name string, workDir string, cmd string, args []string, env map[string]string,
-		option models.ProcessOption) (*types.Process, error)
+	Prepare(param models.ProcessParam) (*types.Process, error)
 	ListAll() []*types.Process
 	FindByName(name string) *types.Process
 	Start(name string) error
-	Stop(name string) error
-	Restart(name string) error
-	Kill(name string) error
+	Stop(name string, by string) (types.StopResult, error)
+	Restart(name string, by string) error
+	Kill(name string, by string) error
 	Clean(name string) error
+	Remove(name string) error
 	Watch(name string) error
 	Wait(name string) <-chan error
+	WaitReady(name string) <-chan error
 	IsAlive(name string) bool
+	Tail(name string, last int) (*types.OutputTail, error)
+	Attach(name string, user string, mode types.AttachMode) (io.WriteCloser, error)
+	ListRuns(name string, page uint32, size uint32, sort string, order string) (int64, []models.ProcessRun, error)
+	ListGroups() []*types.ProcessGroup
+	FindGroup(group string) (*types.ProcessGroup, error)
+	StartGroup(group string) error
+	StopGroup(group string, by string) (map[string]types.StopResult, error)
+	RestartGroup(group string, by string) error
+	// HandOver stops watching the running processes and reading their output, and returns
+	// their stdio pipes, for a daemon upgrade. Resume undoes it when the upgrade fails.
+	HandOver() (map[string]*os.File, error)
+	Resume()
 }
 
 type processPair struct {
-	proc         *types.Process
-	procAttr     *os.ProcAttr
-	finalArgs    []string
-	lock         sync.Mutex
-	ExitingChan  chan struct{}
-	ExitDoneChan chan struct{}
+	proc          *types.Process
+	procAttr      *os.ProcAttr
+	finalArgs     []string
+	stopSignal    syscall.Signal
+	stopTimeout   time.Duration
+	restartPolicy models.RestartPolicy
+	liveness      *models.Probe
+	readiness     *models.Probe
+	umask         int
+	cgroup        *cgroup.Group
+	startTime     time.Time
+	stopBy        string
+	unhealthy     bool
+	exitState     *os.ProcessState
+	exitNotify    chan *os.ProcessState
+	readyChan     chan struct{}
+	readyOnce     sync.Once
+	lock          sync.Mutex
+	stdInLock     sync.Mutex
+	discardChan   chan struct{}
+	ExitingChan   chan struct{}
+	ExitDoneChan  chan struct{}
+}
+
+// discard releases the pipes of pp if its process was never started, and reports whether it
+// did. The caller must hold pp.lock.
+func (pp *processPair) discard() bool {
+	if pp.proc.Process != nil {
+		return false
+	}
+	if !pp.discarded() {
+		close(pp.discardChan)
+	}
+	return true
+}
+
+func (pp *processPair) discarded() bool {
+	select {
+	case <-pp.discardChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// exited reports whether the process of pp has been started and is gone.
+func (pp *processPair) exited() bool {
+	select {
+	case <-pp.ExitDoneChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// snapshot returns a copy of the process of pp, safe to read while the process keeps changing.
+func (pp *processPair) snapshot() *types.Process {
+	pp.lock.Lock()
+	defer pp.lock.Unlock()
+	proc := *pp.proc
+	proc.Statistics.History = append([]types.ResourceSample(nil), pp.proc.Statistics.History...)
+	return &proc
 }
 
 // processesService is a os.processesService wrapper with Statistics and more info that will be used on Master to maintain
 // the process health.
 type processesService struct {
 	services.InitHelper
-	procMap  sync.Map
-	watchDog watchdog.Service
+	procMap     sync.Map
+	outputs     sync.Map
+	restarts    sync.Map
+	prepareLock sync.Mutex
+	watchDog    watchdog.Service
+	runs        process_run.Repository
+	// handOverLock guards the processes handed over to a new daemon, see HandOver.
+	handOverLock sync.Mutex
+	handingOver  bool
+	handedOver   []*processPair
+	unfinished   []*processPair
 }
 
 func (s *processesService) Initialize(arguments ...interface{}) error {
 	return s.CallInitialize(func() error {
+		s.adopt()
 		return nil
 	})
 }
@@ -70,8 +163,10 @@ func GetInstance() Service {
 	once.Do(func() {
 		instance = &processesService{
 			watchDog: watchdog.GetInstance(),
+			runs:     process_run.NewProcessRunRepository(),
 		}
 		go instance.runAutoReStart()
+		go instance.runSampler()
 	})
 	return instance
 }
@@ -84,79 +179,237 @@ func buildEnv(env map[string]string) (result []string) {
 }
 
 func (s *processesService) runAutoReStart() {
-	for proc := range s.watchDog.GetDeathsChan() {
-		if proc.Option&models.AutoRestart != 0 {
-			log.Infof("process %s does not have keep alive set. Will not be restarted.", proc.Name)
-			continue
-		}
-		log.Infof("Restarting process %s.", proc.Name)
-		if s.IsAlive(proc.Name) {
-			log.Warnf("process %s was supposed to be dead, but it is alive.", proc.Name)
-		}
-
-		err := s.Restart(proc.Name)
-		if err != nil {
-			log.Warnf("Could not restart process %s due to %s.", proc.Name, err)
-		}
+	for death := range s.watchDog.GetDeathsChan() {
+		go s.handleDeath(death)
 	}
 }
 
 func (s *processesService) ListAll() (processes []*types.Process) {
 	processes = []*types.Process{}
 	s.procMap.Range(func(key, value interface{}) bool {
-		processPair := value.(*processPair)
-		processes = append(processes, processPair.proc)
+		processes = append(processes, value.(*processPair).snapshot())
 		return true
 	})
 	return
 }
 
-func (s *processesService) Prepare(name string, workDir string, cmd string, args []string, env map[string]string,
-	option models.ProcessOption) (*types.Process, error) {
-	var err error
-	_, loaded := s.procMap.Load(name)
-	if loaded {
-		return nil, ProcessExist
-	}
-	proc := &types.Process{
-		ProcessParam: models.ProcessParam{
-			Name:    name,
-			Cmd:     cmd,
-			Args:    args,
-			Env:     env,
-			WorkDir: workDir,
-			Option:  option,
-		},
+// output returns the output of the named process. It outlives the process, so tails keep
+// receiving lines across restarts, and is only dropped once the process is cleaned or removed.
+func (s *processesService) output(name string) *types.Output {
+	if o, ok := s.outputs.Load(name); ok {
+		return o.(*types.Output)
 	}
-	rStdIn, lStdIn, err := os.Pipe()
-	if err != nil {
-		return nil, err
+	bufferLines := global_configuration.GetGlobalConfig().Get().Processes.OutputBufferLines
+	o, _ := s.outputs.LoadOrStore(name, types.NewOutput(bufferLines))
+	return o.(*types.Output)
+}
+
+// dropOutput drops the output of the named process, ending its tails.
+func (s *processesService) dropOutput(name string) {
+	if o, ok := s.outputs.Load(name); ok {
+		s.outputs.Delete(name)
+		o.(*types.Output).Close()
 	}
-	proc.StdIn = lStdIn
-	lStdOut, rStdOut, err := os.Pipe()
+}
+
+// pumpOutput publishes every line read from r to output, and copies it to hook when the
+// process output is also sent to the log service.
+func pumpOutput(output *types.Output, stream string, r io.Reader, hook io.WriteCloser) {
+	if hook != nil {
+		defer hook.Close()
+	}
+	bufR := bufio.NewReader(r)
+	for {
+		line, err := bufR.ReadString('\n')
+		if line != "" {
+			output.Publish(types.OutputLine{
+				Stream: stream,
+				Text:   strings.TrimRight(line, "\r\n"),
+				Time:   time.Now(),
+			})
+			if hook != nil {
+				hook.Write([]byte(line))
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// pumpOutputs publishes the output of the process of param read from stdOut and stdErr, and
+// sends it to the log service with the HookLog option.
+func (s *processesService) pumpOutputs(param models.ProcessParam, stdOut io.Reader, stdErr io.Reader) error {
+	var stdOutHook, stdErrHook io.WriteCloser
+	if param.Option&models.HookLog != 0 {
+		var stdOutLog, stdErrLog io.Reader
+		stdErrLog, stdErrHook = io.Pipe()
+		err := logservice.GetInstance().WarpReader(param.Name, stdErrLog)
+		if err != nil {
+			stdErrHook.Close()
+			return err
+		}
+		stdOutLog, stdOutHook = io.Pipe()
+		err = logservice.GetInstance().WarpReader(param.Name, stdOutLog)
+		if err != nil {
+			stdErrHook.Close()
+			stdOutHook.Close()
+			return err
+		}
+	}
+	output := s.output(param.Name)
+	go pumpOutput(output, types.StdOutStream, stdOut, stdOutHook)
+	go pumpOutput(output, types.StdErrStream, stdErr, stdErrHook)
+	return nil
+}
+
+// signalGroup sends sig to the whole process group led by pid, so grandchildren receive it as well.
+func signalGroup(pid int, sig syscall.Signal) error {
+	return syscall.Kill(-pid, sig)
+}
+
+// newProcessPair resolves the stop, restart and probe settings of param for a new process.
+func newProcessPair(param models.ProcessParam) (*processPair, error) {
+	restartPolicy, err := resolveRestartPolicy(param)
 	if err != nil {
 		return nil, err
 	}
-	proc.StdOut = lStdOut
-	lStdErr, rStdErr, err := os.Pipe()
+	liveness, err := resolveProbe(param.Liveness)
 	if err != nil {
 		return nil, err
 	}
-	proc.StdErr = lStdErr
-	lDataOut, rDataOut, err := os.Pipe()
+	readiness, err := resolveProbe(param.Readiness)
 	if err != nil {
 		return nil, err
 	}
-	proc.DataOut = lDataOut
-	if option&models.HookLog != 0 {
-		err = logservice.GetInstance().WarpReader(name, lStdErr)
+	stopSignal := syscall.SIGTERM
+	if param.StopSignal != "" {
+		stopSignal, err = models.ParseSignal(param.StopSignal)
 		if err != nil {
 			return nil, err
 		}
-		err = logservice.GetInstance().WarpReader(name, lStdOut)
+	}
+	stopTimeout := DefaultStopTimeout
+	if param.StopTimeout > 0 {
+		stopTimeout = time.Second * time.Duration(param.StopTimeout)
+	}
+	return &processPair{
+		proc: &types.Process{
+			ProcessParam: param,
+		},
+		stopSignal:    stopSignal,
+		stopTimeout:   stopTimeout,
+		restartPolicy: restartPolicy,
+		liveness:      liveness,
+		readiness:     readiness,
+		umask:         -1,
+		readyChan:     make(chan struct{}),
+		discardChan:   make(chan struct{}),
+		ExitingChan:   make(chan struct{}),
+		ExitDoneChan:  make(chan struct{}),
+	}, nil
+}
+
+// finish marks the process of pp exited, and cleans up after it unless a new daemon it was
+// handed over to does.
+func (s *processesService) finish(pp *processPair) {
+	pp.lock.Lock()
+	pp.proc.Status = types.Exited
+	pp.proc.Ready = false
+	pp.lock.Unlock()
+	s.handOverLock.Lock()
+	handingOver := s.handingOver
+	if handingOver {
+		s.unfinished = append(s.unfinished, pp)
+	}
+	s.handOverLock.Unlock()
+	if !handingOver {
+		s.release(pp)
+	}
+	close(pp.ExitDoneChan)
+}
+
+// release removes the cgroup and the state of the exited process of pp and records its run.
+func (s *processesService) release(pp *processPair) {
+	if pp.cgroup != nil {
+		if err := pp.cgroup.Remove(); err != nil {
+			log.Warnf("process %s cgroup %s remove error: %v", pp.proc.Name, pp.cgroup.Path, err)
+		}
+	}
+	s.recordRun(pp)
+	removeState(pp.proc.Name)
+}
+
+// Prepare sets up a process to be started. A process of the same name that exited, or that
+// was prepared but never started, for instance because it failed to start, is replaced once
+// the new one is set up.
+func (s *processesService) Prepare(param models.ProcessParam) (*types.Process, error) {
+	name := param.Name
+	s.prepareLock.Lock()
+	defer s.prepareLock.Unlock()
+	var old *processPair
+	if result, loaded := s.procMap.Load(name); loaded {
+		// Held until the process is replaced, so that it is not started meanwhile.
+		old = result.(*processPair)
+		old.lock.Lock()
+		defer old.lock.Unlock()
+		if !old.exited() && old.proc.Process != nil {
+			return nil, ProcessExist
+		}
+	}
+	pp, err := newProcessPair(param)
+	if err != nil {
+		return nil, err
+	}
+	credential, ambientCaps, err := buildCredential(param.Credential)
+	if err != nil {
+		return nil, err
+	}
+	umask, err := parseUmask(param.Credential.Umask)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*os.File
+	var fifos []string
+	fail := func(err error) (*types.Process, error) {
+		for _, f := range files {
+			f.Close()
+		}
+		for _, fifo := range fifos {
+			os.Remove(fifo)
+		}
+		return nil, err
+	}
+	proc := pp.proc
+	rStdIn, lStdIn, err := os.Pipe()
+	if err != nil {
+		return fail(err)
+	}
+	files = append(files, rStdIn, lStdIn)
+	proc.StdIn = lStdIn
+	streams := make(map[string][2]*os.File, len(outputStreams))
+	for _, stream := range outputStreams {
+		reader, writer, fifo, err := openOutputFifo(name, stream)
 		if err != nil {
-			return nil, err
+			return fail(err)
 		}
+		files = append(files, reader, writer)
+		fifos = append(fifos, fifo)
+		streams[stream] = [2]*os.File{reader, writer}
+	}
+	lStdOut, rStdOut := streams["stdout"][0], streams["stdout"][1]
+	lStdErr, rStdErr := streams["stderr"][0], streams["stderr"][1]
+	lDataOut, rDataOut := streams["data"][0], streams["data"][1]
+	proc.StdOut = lStdOut
+	proc.StdErr = lStdErr
+	proc.DataOut = lDataOut
+	err = s.pumpOutputs(param, lStdOut, lStdErr)
+	if err != nil {
+		return fail(err)
+	}
+	for _, fifo := range fifos {
+		os.Rename(fifo, strings.TrimSuffix(fifo, ".new"))
 	}
 
 	finalEnv := make(map[string]string)
@@ -164,11 +417,11 @@ func (s *processesService) Prepare(name string, workDir string, cmd string, args
 		es := strings.Split(e, "=")
 		finalEnv[es[0]] = es[1]
 	}
-	for k, v := range env {
+	for k, v := range param.Env {
 		finalEnv[k] = v
 	}
 	procAttr := &os.ProcAttr{
-		Dir: workDir,
+		Dir: param.WorkDir,
 		Env: buildEnv(finalEnv),
 		Files: []*os.File{
 			rStdIn,
@@ -176,18 +429,24 @@ func (s *processesService) Prepare(name string, workDir string, cmd string, args
 			rStdErr,
 			rDataOut,
 		},
+		Sys: &syscall.SysProcAttr{
+			Setpgid:     true,
+			Credential:  credential,
+			AmbientCaps: ambientCaps,
+		},
 	}
 
-	pp := &processPair{
-		proc:         proc,
-		procAttr:     procAttr,
-		finalArgs:    append([]string{cmd}, args...),
-		ExitingChan:  make(chan struct{}),
-		ExitDoneChan: make(chan struct{}),
-	}
+	pp.procAttr = procAttr
+	pp.finalArgs = append([]string{param.Cmd}, param.Args...)
+	pp.umask = umask
 
 	go func() {
-		<-pp.ExitingChan
+		exited := true
+		select {
+		case <-pp.ExitingChan:
+		case <-pp.discardChan:
+			exited = false
+		}
 		proc.StdIn.Close()
 		proc.StdOut.Close()
 		proc.DataOut.Close()
@@ -195,25 +454,41 @@ func (s *processesService) Prepare(name string, workDir string, cmd string, args
 		for _, f := range procAttr.Files {
 			f.Close()
 		}
-		s.procMap.Delete(name)
-		proc.Status = types.Exited
-		close(pp.ExitDoneChan)
+		if exited {
+			s.finish(pp)
+		}
 	}()
 
 	proc.Status = types.Prepare
-
-	_, loaded = s.procMap.LoadOrStore(name, pp)
-	if loaded {
-		close(pp.ExitingChan)
-		return nil, ProcessExist
+	if old != nil {
+		old.discard()
 	}
+	s.procMap.Store(name, pp)
 
-	return proc, err
+	return proc, nil
+}
+
+// renew replaces an exited process by a freshly prepared one with the same parameters, so
+// that it can be started again. The restart count carries over.
+func (s *processesService) renew(pp *processPair) (*processPair, error) {
+	if !pp.exited() {
+		return pp, nil
+	}
+	proc, err := s.Prepare(pp.proc.ProcessParam)
+	if err != nil {
+		return nil, err
+	}
+	proc.Statistics.Restarts = pp.proc.Statistics.Restarts
+	result, ok := s.procMap.Load(proc.Name)
+	if !ok {
+		return nil, ProcessNotExist
+	}
+	return result.(*processPair), nil
 }
 
 func (s *processesService) FindByName(name string) *types.Process {
 	if p, ok := s.procMap.Load(name); ok {
-		return p.(*processPair).proc
+		return p.(*processPair).snapshot()
 	}
 	return nil
 }
@@ -223,77 +498,179 @@ func (s *processesService) Start(name string) error {
 	if !ok {
 		return ProcessNotExist
 	}
-	pp := result.(*processPair)
+	pp, err := s.renew(result.(*processPair))
+	if err != nil {
+		return err
+	}
 	pp.lock.Lock()
+	if pp.discarded() {
+		pp.lock.Unlock()
+		return ProcessReplaced
+	}
 	defer pp.lock.Unlock()
 
-	process, err := os.StartProcess(pp.proc.Cmd, pp.finalArgs, pp.procAttr)
+	if pp.proc.Process != nil {
+		return ProcessExist
+	}
+	if !pp.proc.Limits.IsZero() {
+		parent := global_configuration.GetGlobalConfig().Get().Processes.CgroupParent
+		pp.cgroup, err = cgroup.Create(parent, pp.proc.Name, pp.proc.Limits)
+		if err != nil {
+			return err
+		}
+		pp.proc.Cgroup = pp.cgroup.Path
+	}
+
+	process, err := s.startProcess(pp)
 	if err != nil {
+		if pp.cgroup != nil {
+			pp.cgroup.Remove()
+			pp.cgroup = nil
+			pp.proc.Cgroup = ""
+		}
 		return err
 	}
 
+	pp.proc.Process = process
+	pp.proc.Pid = process.Pid
+	pp.proc.Statistics.InitUpTime()
+	pp.proc.Status = types.Running
+	pp.startTime = time.Now()
+
+	// The exit notification is buffered so that it is kept for a watch started later.
+	exitNotify := make(chan *os.ProcessState, 1)
+	pp.exitNotify = exitNotify
 	go func() {
-		pp.proc.Process.Wait()
+		state, _ := process.Wait()
+		pp.exitState = state
 		close(pp.ExitingChan)
+		<-pp.ExitDoneChan
+		exitNotify <- state
 	}()
 
-	if pp.proc.Option&models.AutoRestart != 0 {
-		err = s.watchDog.StartWatch(pp.proc)
+	err = saveState(pp)
+	if err != nil {
+		log.Warnf("process %s state save error: %v", pp.proc.Name, err)
+	}
+
+	s.startProbes(pp)
+
+	if pp.restartPolicy.Mode != models.RestartNever {
+		err = s.watchDog.StartWatch(pp.proc, exitNotify)
 		if err != nil {
 			return err
 		}
 	}
-
-	pp.proc.Process = process
-	pp.proc.Pid = process.Pid
-	pp.proc.Statistics.InitUpTime()
-	pp.proc.Status = types.Running
 	return nil
 }
 
-func (s *processesService) Stop(name string) error {
-	result, ok := s.procMap.Load(name)
-	if !ok {
-		return ProcessNotExist
+// startProcess starts the process of pp with its umask, directly inside its cgroup if it has
+// one so that nothing it forks early escapes the limits. The caller must hold pp.lock.
+func (s *processesService) startProcess(pp *processPair) (*os.Process, error) {
+	sys := pp.procAttr.Sys
+	if pp.cgroup != nil {
+		dir, err := pp.cgroup.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer dir.Close()
+		sys.UseCgroupFD = true
+		sys.CgroupFD = int(dir.Fd())
+		defer func() {
+			sys.UseCgroupFD = false
+			sys.CgroupFD = 0
+		}()
 	}
-	pp := result.(*processPair)
-	pp.lock.Lock()
-	defer pp.lock.Unlock()
+	cmd, argv := pp.proc.Cmd, pp.finalArgs
+	if pp.umask >= 0 {
+		cmd, argv = umaskCommand(pp.umask, argv)
+	}
+	return os.StartProcess(cmd, argv, pp.procAttr)
+}
 
+// stop stops watching the process and terminates it. The caller must hold pp.lock, see
+// terminate.
+func (s *processesService) stop(pp *processPair, by string) (types.StopResult, error) {
 	if pp.proc.Status != types.Running {
-		return ProcessNotRun
+		return types.StopNone, ProcessNotRun
 	}
+	s.watchDog.StopWatch(pp.proc)
+	return s.terminate(pp, by)
+}
 
+// terminate sends the stop signal to the process group and escalates to SIGKILL once the stop
+// timeout has elapsed. The caller must hold pp.lock, which is released while waiting for the
+// process to exit so that the process can still be inspected meanwhile. The status is Exiting
+// by then, so no other stop or start gets through.
+func (s *processesService) terminate(pp *processPair, by string) (types.StopResult, error) {
 	pp.proc.Status = types.Exiting
+	pp.stopBy = by
+
+	err := signalGroup(pp.proc.Pid, pp.stopSignal)
+	if err != nil {
+		return types.StopNone, err
+	}
+	pp.lock.Unlock()
+	defer pp.lock.Lock()
+	select {
+	case <-pp.ExitDoneChan:
+		return types.StopGraceful, nil
+	case <-time.After(pp.stopTimeout):
+	}
 
-	defer pp.proc.Process.Release()
-	return pp.proc.Process.Signal(syscall.SIGTERM)
+	log.Warnf("process %s did not exit within %s after %s, killing it.", pp.proc.Name, pp.stopTimeout,
+		pp.stopSignal)
+	err = signalGroup(pp.proc.Pid, syscall.SIGKILL)
+	if err != nil {
+		return types.StopNone, err
+	}
+	<-pp.ExitDoneChan
+	return types.StopKilled, nil
 }
 
-func (s *processesService) Restart(name string) error {
-	if s.IsAlive(name) {
-		err := s.Stop(name)
-		if err != nil {
-			return err
-		}
+func (s *processesService) Stop(name string, by string) (types.StopResult, error) {
+	result, ok := s.procMap.Load(name)
+	if !ok {
+		return types.StopNone, ProcessNotExist
 	}
+	pp := result.(*processPair)
+	pp.lock.Lock()
+	defer pp.lock.Unlock()
+
+	return s.stop(pp, by)
+}
+
+func (s *processesService) Restart(name string, by string) error {
 	result, ok := s.procMap.Load(name)
 	if !ok {
 		return ProcessNotExist
 	}
 	pp := result.(*processPair)
 	pp.lock.Lock()
-	defer pp.lock.Unlock()
+	if pp.proc.Status == types.Running {
+		_, err := s.stop(pp, by)
+		if err != nil {
+			pp.lock.Unlock()
+			return err
+		}
+	}
+	started := pp.proc.Process != nil
+	pp.lock.Unlock()
 
-	<-pp.ExitDoneChan
-	_, err := s.Prepare(pp.proc.Name, pp.proc.WorkDir, pp.proc.Cmd, pp.proc.Args, pp.proc.Env, pp.proc.Option)
-	if err != nil {
-		return err
+	if started {
+		<-pp.ExitDoneChan
+		pp, err := s.renew(pp)
+		if err != nil {
+			return err
+		}
+		pp.lock.Lock()
+		pp.proc.Statistics.AddRestart()
+		pp.lock.Unlock()
 	}
 	return s.Start(name)
 }
 
-func (s *processesService) Kill(name string) error {
+func (s *processesService) Kill(name string, by string) error {
 	result, ok := s.procMap.Load(name)
 	if !ok {
 		return ProcessNotExist
@@ -307,9 +684,10 @@ func (s *processesService) Kill(name string) error {
 	}
 
 	pp.proc.Status = types.Exiting
+	pp.stopBy = by
+	s.watchDog.StopWatch(pp.proc)
 
-	defer pp.proc.Process.Release()
-	return pp.proc.Process.Kill()
+	return signalGroup(pp.proc.Pid, syscall.SIGKILL)
 }
 
 func (s *processesService) Clean(name string) error {
@@ -322,13 +700,36 @@ func (s *processesService) Clean(name string) error {
 	defer pp.lock.Unlock()
 
 	if pp.proc.Status == types.Running {
-		s.Stop(name)
+		s.stop(pp, "clean")
+	}
+	if pp.discard() || pp.exited() {
+		s.procMap.Delete(name)
+		s.restarts.Delete(name)
+		s.dropOutput(name)
 	}
 
-	pp.proc.Process.Release()
 	return os.RemoveAll(pp.proc.WorkDir)
 }
 
+// Remove forgets a process that is not running, leaving its work directory alone.
+func (s *processesService) Remove(name string) error {
+	result, ok := s.procMap.Load(name)
+	if !ok {
+		return ProcessNotExist
+	}
+	pp := result.(*processPair)
+	pp.lock.Lock()
+	defer pp.lock.Unlock()
+
+	if !pp.discard() && !pp.exited() {
+		return ProcessRunning
+	}
+	s.procMap.Delete(name)
+	s.restarts.Delete(name)
+	s.dropOutput(name)
+	return nil
+}
+
 func (s *processesService) Watch(name string) error {
 	result, ok := s.procMap.Load(name)
 	if !ok {
@@ -338,7 +739,10 @@ func (s *processesService) Watch(name string) error {
 	pp.lock.Lock()
 	defer pp.lock.Unlock()
 
-	return s.watchDog.StartWatch(pp.proc)
+	if pp.exitNotify == nil {
+		return ProcessNotRun
+	}
+	return s.watchDog.StartWatch(pp.proc, pp.exitNotify)
 }
 
 func (s *processesService) Wait(name string) <-chan error {
@@ -360,6 +764,33 @@ func (s *processesService) Wait(name string) <-chan error {
 	return ret
 }
 
+// WaitReady returns a channel closed once the process is ready, or receiving ProcessNotRun if
+// it exits before.
+func (s *processesService) WaitReady(name string) <-chan error {
+	ret := make(chan error, 1)
+	result, ok := s.procMap.Load(name)
+	if !ok {
+		ret <- ProcessNotExist
+		return ret
+	}
+	pp := result.(*processPair)
+
+	go func() {
+		select {
+		case <-pp.readyChan:
+		case <-pp.ExitDoneChan:
+			select {
+			case <-pp.readyChan:
+			default:
+				ret <- ProcessNotRun
+			}
+		}
+		close(ret)
+	}()
+
+	return ret
+}
+
 func (s *processesService) IsAlive(name string) bool {
 	result, ok := s.procMap.Load(name)
 	if !ok {
@@ -369,9 +800,124 @@ func (s *processesService) IsAlive(name string) bool {
 	pp.lock.Lock()
 	defer pp.lock.Unlock()
 
-	_, err := os.FindProcess(pp.proc.Pid)
-	if err != nil {
+	if pp.proc.Process == nil {
 		return false
 	}
 	return pp.proc.Process.Signal(syscall.Signal(0)) == nil
 }
+
+func (s *processesService) Tail(name string, last int) (*types.OutputTail, error) {
+	if _, ok := s.outputs.Load(name); !ok {
+		return nil, ProcessNotExist
+	}
+	return s.output(name).Tail(last), nil
+}
+
+// attachment writes to the stdin of a process on behalf of an operator. Writes of concurrent
+// attachments to the same process never interleave.
+type attachment struct {
+	pp   *processPair
+	user string
+	mode types.AttachMode
+	once sync.Once
+}
+
+func (a *attachment) Write(p []byte) (int, error) {
+	a.pp.stdInLock.Lock()
+	defer a.pp.stdInLock.Unlock()
+	if a.mode == types.LineAttach && (len(p) == 0 || p[len(p)-1] != '\n') {
+		n, err := a.pp.proc.StdIn.Write(append(p, '\n'))
+		if n > len(p) {
+			n = len(p)
+		}
+		return n, err
+	}
+	return a.pp.proc.StdIn.Write(p)
+}
+
+func (a *attachment) Close() error {
+	a.once.Do(func() {
+		audit("%s detached from stdin of process %s", a.user, a.pp.proc.Name)
+	})
+	return nil
+}
+
+func audit(format string, args ...interface{}) {
+	logger, err := logservice.GetInstance().GetLogger("audit")
+	if err != nil {
+		log.Warnf("audit logger error: %v", err)
+		return
+	}
+	logger.Infof(format, args...)
+}
+
+func (s *processesService) Attach(name string, user string, mode types.AttachMode) (io.WriteCloser, error) {
+	result, ok := s.procMap.Load(name)
+	if !ok {
+		return nil, ProcessNotExist
+	}
+	pp := result.(*processPair)
+	pp.lock.Lock()
+	defer pp.lock.Unlock()
+
+	if pp.proc.Status != types.Running {
+		return nil, ProcessNotRun
+	}
+	if pp.proc.StdIn == nil {
+		return nil, ProcessAdopted
+	}
+	audit("%s attached to stdin of process %s in %s mode", user, name, mode)
+	return &attachment{
+		pp:   pp,
+		user: user,
+		mode: mode,
+	}, nil
+}
+
+// recordRun saves the run of an exited process in the run history.
+func (s *processesService) recordRun(pp *processPair) {
+	run := &models.ProcessRun{
+		Name:      pp.proc.Name,
+		Pid:       pp.proc.Pid,
+		StartTime: pp.startTime,
+		EndTime:   time.Now(),
+		ExitCode:  -1,
+		StopBy:    pp.stopBy,
+	}
+	if pp.exitState != nil {
+		run.ExitCode = pp.exitState.ExitCode()
+		if status, ok := pp.exitState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			run.Signal = models.SignalName(status.Signal())
+			run.CoreDump = status.CoreDump()
+		}
+	}
+	if err := s.runs.InsertProcessRun(run); err != nil {
+		log.Warnf("process %s run record error: %v", run.Name, err)
+	}
+}
+
+func (s *processesService) ListRuns(name string, page uint32, size uint32, sort string,
+	order string) (total int64, runs []models.ProcessRun, err error) {
+	total, err = s.runs.CountByName(name)
+	if err != nil {
+		return 0, []models.ProcessRun{}, err
+	}
+	var sorts []repositories.Sort
+	if sort != "" {
+		o, err := repositories.ParseOrder(order)
+		if err != nil {
+			return 0, []models.ProcessRun{}, err
+		}
+		sorts = []repositories.Sort{
+			{
+				Name:  sort,
+				Order: o,
+			},
+		}
+	}
+	runs, err = s.runs.FindProcessRunsByName(name, page, size, sorts)
+	if err != nil {
+		return 0, []models.ProcessRun{}, err
+	}
+	return
+}
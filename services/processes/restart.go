@@ -0,0 +1,187 @@
+package processes
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/processes/types"
+	"github.com/zhsyourai/URCF-engine/services/processes/watchdog"
+)
+
+const (
+	DefaultRestartInitialDelay = 1
+	DefaultRestartMaxDelay     = 60
+	DefaultRestartMaxRestarts  = 5
+	DefaultRestartWindow       = 60
+)
+
+// resolveRestartPolicy returns the restart policy of param with its mode and defaults filled in.
+func resolveRestartPolicy(param models.ProcessParam) (models.RestartPolicy, error) {
+	policy := param.RestartPolicy
+	switch policy.Mode {
+	case "":
+		if param.Option&models.AutoRestart != 0 {
+			policy.Mode = models.RestartAlways
+		} else {
+			policy.Mode = models.RestartNever
+		}
+	case models.RestartAlways, models.RestartOnFailure, models.RestartNever:
+	default:
+		return policy, fmt.Errorf("unknown restart mode %q", policy.Mode)
+	}
+	if policy.InitialDelay <= 0 {
+		policy.InitialDelay = DefaultRestartInitialDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = DefaultRestartMaxDelay
+	}
+	if policy.MaxDelay < policy.InitialDelay {
+		policy.MaxDelay = policy.InitialDelay
+	}
+	if policy.MaxRestarts <= 0 {
+		policy.MaxRestarts = DefaultRestartMaxRestarts
+	}
+	if policy.Window <= 0 {
+		policy.Window = DefaultRestartWindow
+	}
+	return policy, nil
+}
+
+// exitCode returns the exit code of a process, or 128 plus the signal number when it was
+// killed by a signal.
+func exitCode(state *os.ProcessState) int {
+	if state == nil {
+		return -1
+	}
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
+
+// shouldRestart reports whether a process that exited with code must be restarted.
+func shouldRestart(policy models.RestartPolicy, code int) bool {
+	switch policy.Mode {
+	case models.RestartAlways:
+		return true
+	case models.RestartOnFailure:
+		if code == 0 {
+			return false
+		}
+		if len(policy.ExitCodes) == 0 {
+			return true
+		}
+		for _, c := range policy.ExitCodes {
+			if c == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before the restart following attempt earlier ones. It doubles
+// from the initial delay up to the max delay, and half of it is random so that processes
+// failing together don't restart together.
+func backoff(policy models.RestartPolicy, attempt int) time.Duration {
+	maxDelay := time.Duration(policy.MaxDelay) * time.Second
+	delay := time.Duration(policy.InitialDelay) * time.Second
+	for i := 0; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// restartHistory keeps the times a process was restarted by its policy.
+type restartHistory struct {
+	lock  sync.Mutex
+	times []time.Time
+}
+
+// add prunes the restarts older than the policy window and records a new one at now. It
+// returns the number of restarts left in the window, and false when the process restarted
+// too often and must not be restarted anymore.
+func (h *restartHistory) add(policy models.RestartPolicy, now time.Time) (int, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	since := now.Add(-time.Duration(policy.Window) * time.Second)
+	i := 0
+	for i < len(h.times) && h.times[i].Before(since) {
+		i++
+	}
+	h.times = h.times[i:]
+	attempt := len(h.times)
+	if attempt >= policy.MaxRestarts {
+		h.times = nil
+		return attempt, false
+	}
+	h.times = append(h.times, now)
+	return attempt, true
+}
+
+func (s *processesService) restartHistory(name string) *restartHistory {
+	h, _ := s.restarts.LoadOrStore(name, &restartHistory{})
+	return h.(*restartHistory)
+}
+
+// handleDeath applies the restart policy of a process that exited on its own.
+func (s *processesService) handleDeath(death watchdog.Death) {
+	name := death.Proc.Name
+	result, ok := s.procMap.Load(name)
+	if !ok || result.(*processPair).proc != death.Proc {
+		return
+	}
+	pp := result.(*processPair)
+	code := exitCode(death.State)
+	pp.lock.Lock()
+	unhealthy := pp.unhealthy
+	pp.lock.Unlock()
+	if unhealthy {
+		if pp.restartPolicy.Mode == models.RestartNever {
+			return
+		}
+		log.Infof("process %s was killed by its liveness probe, it counts as a failure.", name)
+	} else if !shouldRestart(pp.restartPolicy, code) {
+		log.Infof("process %s exited with code %d, restart policy %s does not restart it.", name, code,
+			pp.restartPolicy.Mode)
+		return
+	}
+
+	attempt, ok := s.restartHistory(name).add(pp.restartPolicy, time.Now())
+	if !ok {
+		pp.lock.Lock()
+		pp.proc.Status = types.CrashLoop
+		pp.lock.Unlock()
+		log.Errorf("process %s restarted %d times within %ds, it is crash looping and will not be restarted.",
+			name, attempt, pp.restartPolicy.Window)
+		return
+	}
+	delay := backoff(pp.restartPolicy, attempt)
+	log.Infof("process %s exited with code %d, restarting in %s.", name, code, delay)
+	time.Sleep(delay)
+
+	if result, ok := s.procMap.Load(name); !ok || result.(*processPair) != pp {
+		log.Infof("process %s changed while waiting to restart, skip it.", name)
+		return
+	}
+	s.handOverLock.Lock()
+	handingOver := s.handingOver
+	s.handOverLock.Unlock()
+	if handingOver {
+		log.Infof("process %s is handed over to a new daemon, leaving its restart to it.", name)
+		return
+	}
+	if err := s.Restart(name, ""); err != nil {
+		log.Warnf("Could not restart process %s due to %s.", name, err)
+	}
+}
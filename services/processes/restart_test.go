@@ -0,0 +1,78 @@
+package processes
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zhsyourai/URCF-engine/models"
+)
+
+func TestResolveRestartPolicy(t *testing.T) {
+	policy, err := resolveRestartPolicy(models.ProcessParam{Option: models.AutoRestart})
+	if err != nil {
+		t.Fatalf("%s(%s)", "resolveRestartPolicy error", fmt.Sprint(err))
+	}
+	if policy.Mode != models.RestartAlways || policy.MaxRestarts != DefaultRestartMaxRestarts {
+		t.Fatalf("resolveRestartPolicy error (%v not equal %v)", policy.Mode, models.RestartAlways)
+	}
+	policy, _ = resolveRestartPolicy(models.ProcessParam{})
+	if policy.Mode != models.RestartNever {
+		t.Fatalf("resolveRestartPolicy error (%v not equal %v)", policy.Mode, models.RestartNever)
+	}
+	_, err = resolveRestartPolicy(models.ProcessParam{RestartPolicy: models.RestartPolicy{Mode: "sometimes"}})
+	if err == nil {
+		t.Fatalf("%s(%s)", "resolveRestartPolicy error", "unknown mode accepted")
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	onFailure := models.RestartPolicy{Mode: models.RestartOnFailure}
+	if shouldRestart(onFailure, 0) || !shouldRestart(onFailure, 1) {
+		t.Fatalf("%s(%s)", "shouldRestart error", "on-failure")
+	}
+	onFailure.ExitCodes = []int{2, 137}
+	if shouldRestart(onFailure, 1) || !shouldRestart(onFailure, 137) {
+		t.Fatalf("%s(%s)", "shouldRestart error", "on-failure exit codes")
+	}
+	if !shouldRestart(models.RestartPolicy{Mode: models.RestartAlways}, 0) {
+		t.Fatalf("%s(%s)", "shouldRestart error", "always")
+	}
+	if shouldRestart(models.RestartPolicy{Mode: models.RestartNever}, 1) {
+		t.Fatalf("%s(%s)", "shouldRestart error", "never")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := models.RestartPolicy{InitialDelay: 1, MaxDelay: 10}
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second,
+		10 * time.Second, 10 * time.Second} {
+		for i := 0; i < 20; i++ {
+			d := backoff(policy, attempt)
+			if d < max/2 || d > max {
+				t.Fatalf("backoff error (%v not within [%v, %v])", d, max/2, max)
+			}
+		}
+	}
+	if d := backoff(policy, 100); d > 10*time.Second {
+		t.Fatalf("backoff error (%v overflows)", d)
+	}
+}
+
+func TestRestartHistory(t *testing.T) {
+	policy := models.RestartPolicy{MaxRestarts: 2, Window: 10}
+	h := &restartHistory{}
+	now := time.Now()
+	if attempt, ok := h.add(policy, now); !ok || attempt != 0 {
+		t.Fatalf("restartHistory error (%v %v not equal 0 true)", attempt, ok)
+	}
+	if attempt, ok := h.add(policy, now.Add(time.Second)); !ok || attempt != 1 {
+		t.Fatalf("restartHistory error (%v %v not equal 1 true)", attempt, ok)
+	}
+	if _, ok := h.add(policy, now.Add(2*time.Second)); ok {
+		t.Fatalf("%s(%s)", "restartHistory error", "no crash loop within the window")
+	}
+	if attempt, ok := h.add(policy, now.Add(30*time.Second)); !ok || attempt != 0 {
+		t.Fatalf("restartHistory error (%v %v not equal 0 true)", attempt, ok)
+	}
+}
@@ -0,0 +1,131 @@
+package processes
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/zhsyourai/URCF-engine/services/global_configuration"
+	"github.com/zhsyourai/URCF-engine/services/processes/types"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errBadProcStat = errors.New("malformed /proc stat")
+
+// parseProcStat returns the CPU time, in clock ticks, from the content of /proc/<pid>/stat.
+func parseProcStat(content string) (cpuTicks uint64, err error) {
+	// The command name is between parentheses and may contain spaces.
+	end := strings.LastIndexByte(content, ')')
+	if end < 0 {
+		return 0, errBadProcStat
+	}
+	// Fields after the command name, starting with the state, which is field 3.
+	fields := strings.Fields(content[end+1:])
+	if len(fields) < 13 {
+		return 0, errBadProcStat
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return utime + stime, nil
+}
+
+// parseKeyValues parses the "key: value" lines of /proc/<pid>/status and /proc/<pid>/io.
+func parseKeyValues(content string) map[string]string {
+	ret := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) == 2 {
+			ret[parts[0]] = strings.TrimSpace(parts[1])
+		}
+	}
+	return ret
+}
+
+func parseInt(value string) int64 {
+	ret, _ := strconv.ParseInt(strings.TrimSuffix(value, " kB"), 10, 64)
+	if strings.HasSuffix(value, " kB") {
+		ret *= 1024
+	}
+	return ret
+}
+
+// sampleProcess reads the resource usage of pid from /proc.
+func sampleProcess(pid int) (sample types.ResourceSample, cpuTicks uint64, err error) {
+	dir := fmt.Sprintf("/proc/%d/", pid)
+	sample.Time = time.Now()
+
+	stat, err := ioutil.ReadFile(dir + "stat")
+	if err != nil {
+		return
+	}
+	cpuTicks, err = parseProcStat(string(stat))
+	if err != nil {
+		return
+	}
+
+	status, err := ioutil.ReadFile(dir + "status")
+	if err != nil {
+		return
+	}
+	statusValues := parseKeyValues(string(status))
+	sample.RSS = parseInt(statusValues["VmRSS"])
+	sample.Threads = int(parseInt(statusValues["Threads"]))
+
+	// io and fd need the same credentials as the process, so they are best effort.
+	if io, e := ioutil.ReadFile(dir + "io"); e == nil {
+		ioValues := parseKeyValues(string(io))
+		sample.ReadBytes = parseInt(ioValues["read_bytes"])
+		sample.WriteBytes = parseInt(ioValues["write_bytes"])
+	}
+	if fds, e := ioutil.ReadDir(dir + "fd"); e == nil {
+		sample.OpenFDs = len(fds)
+	} else if !os.IsPermission(e) {
+		err = e
+	}
+	return
+}
+
+// runSampler refreshes the uptime and resource usage of every running process. The statistics
+// are only changed under the process lock, see processPair.snapshot.
+func (s *processesService) runSampler() {
+	for {
+		conf := global_configuration.GetGlobalConfig().Get().Processes
+		interval := time.Second * time.Duration(conf.SampleInterval)
+		if interval <= 0 {
+			interval = 5 * time.Second
+		}
+		<-time.After(interval)
+
+		s.procMap.Range(func(key, value interface{}) bool {
+			pp := value.(*processPair)
+			pp.lock.Lock()
+			running, pid := pp.proc.Status == types.Running, pp.proc.Pid
+			pp.lock.Unlock()
+			if !running {
+				return true
+			}
+			sample, cpuTicks, err := sampleProcess(pid)
+
+			pp.lock.Lock()
+			defer pp.lock.Unlock()
+			if pp.proc.Status != types.Running || pp.proc.Pid != pid {
+				return true
+			}
+			pp.proc.Statistics.SetUpTime()
+			if err == nil {
+				pp.proc.Statistics.AddSample(sample, cpuTicks, conf.SampleHistory)
+			}
+			return true
+		})
+	}
+}
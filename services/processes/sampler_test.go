@@ -0,0 +1,31 @@
+package processes
+
+import (
+	"testing"
+)
+
+func TestParseProcStat(t *testing.T) {
+	stat := "1234 (my (odd) proc) S 1 1234 1234 0 -1 4194560 1200 0 0 0 250 50 0 0 20 0 3 0 " +
+		"91234 123456789 2048 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 2 0 0 0 0 0"
+	ticks, err := parseProcStat(stat)
+	if err != nil {
+		t.Fatalf("%s(%v)", "Parse stat error", err)
+	}
+	if ticks != 300 {
+		t.Fatalf("Parse stat error (ticks %d not equal 300)", ticks)
+	}
+	_, err = parseProcStat("1234 (broken")
+	if err == nil {
+		t.Fatalf("%s", "Parse stat error (broken stat accepted)")
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	values := parseKeyValues("Name:\tproc\nThreads:\t3\nVmRSS:\t   2048 kB\n")
+	if parseInt(values["Threads"]) != 3 {
+		t.Fatalf("Parse status error (threads %s)", values["Threads"])
+	}
+	if parseInt(values["VmRSS"]) != 2048*1024 {
+		t.Fatalf("Parse status error (rss %s)", values["VmRSS"])
+	}
+}
@@ -0,0 +1,375 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/repositories/job"
+	"github.com/zhsyourai/URCF-engine/services"
+	"github.com/zhsyourai/URCF-engine/services/processes"
+)
+
+var ErrJobExist = errors.New("job exist")
+var ErrJobNotExist = errors.New("job not exist")
+
+// maxQueued bounds the runs queued behind a running one with the queue overlap policy.
+const maxQueued = 10
+
+type Service interface {
+	services.ServiceLifeCycle
+	List() ([]models.Job, error)
+	Add(job models.Job) (int64, error)
+	Remove(id int64) error
+	Enable(id int64) error
+	Disable(id int64) error
+	// Trigger runs a job now, following its overlap policy.
+	Trigger(id int64) error
+	// ListRuns returns the run history of a job with the exit code of each run.
+	ListRuns(id int64, page uint32, size uint32, sort string, order string) (int64, []models.ProcessRun, error)
+}
+
+// entry is a scheduled job.
+type entry struct {
+	job      models.Job
+	schedule cron.Schedule
+	stop     chan struct{}
+	lock     sync.Mutex
+	running  bool
+	queued   int
+}
+
+type scheduler struct {
+	services.InitHelper
+	sync.Mutex
+	init             bool
+	repo             job.Repository
+	entries          map[int64]*entry
+	processesService processes.Service
+}
+
+var instance *scheduler
+var once sync.Once
+
+func GetInstance() Service {
+	once.Do(func() {
+		instance = &scheduler{
+			repo:             job.NewJobRepository(),
+			entries:          make(map[int64]*entry),
+			processesService: processes.GetInstance(),
+		}
+	})
+	return instance
+}
+
+func (s *scheduler) Initialize(arguments ...interface{}) error {
+	return s.CallInitialize(func() error {
+		return initEntries(s)
+	})
+}
+
+func (s *scheduler) UnInitialize(arguments ...interface{}) error {
+	return s.CallUnInitialize(func() error {
+		s.Lock()
+		defer s.Unlock()
+		for _, e := range s.entries {
+			e.unschedule()
+		}
+		return nil
+	})
+}
+
+func initEntries(s *scheduler) error {
+	s.Lock()
+	defer s.Unlock()
+	if !s.init {
+		all, err := s.repo.FindAll()
+		if err != nil {
+			return err
+		}
+		for _, j := range all {
+			schedule, err := parseSchedule(j)
+			if err != nil {
+				log.Errorf("job %s schedule error: %v", j.Name, err)
+				continue
+			}
+			e := &entry{job: j, schedule: schedule}
+			s.entries[j.ID] = e
+			if j.Enable {
+				s.schedule(e)
+			}
+		}
+		s.init = true
+	}
+	return nil
+}
+
+// parseSchedule returns the schedule of job, from its interval or its cron expression which
+// also accepts descriptors such as @hourly or @every 90s.
+func parseSchedule(job models.Job) (cron.Schedule, error) {
+	switch {
+	case job.Interval > 0 && job.Schedule != "":
+		return nil, errors.New("a job has either a schedule or an interval")
+	case job.Interval > 0:
+		return cron.Every(time.Duration(job.Interval) * time.Second), nil
+	case job.Schedule != "":
+		return cron.ParseStandard(job.Schedule)
+	}
+	return nil, errors.New("a job needs a schedule or an interval")
+}
+
+// jobParam returns the ProcessParam of a job run. Its output goes to the log service and it
+// is never restarted, the next run is up to the schedule.
+func jobParam(job models.Job) models.ProcessParam {
+	param := job.ProcessParam
+	param.Name = processName(job.Name)
+	param.Option |= models.HookLog
+	param.Option &^= models.AutoRestart
+	param.RestartPolicy.Mode = models.RestartNever
+	return param
+}
+
+// processName is the name of the process of the runs of the named job. Jobs have their own
+// namespace, so that a job never replaces a managed process of the same name.
+func processName(job string) string {
+	return "job/" + job
+}
+
+// schedule starts the timer loop of e. The caller must hold the lock.
+func (s *scheduler) schedule(e *entry) {
+	e.stop = make(chan struct{})
+	go s.loop(e, e.stop)
+}
+
+// unschedule stops the timer loop of e, a run in progress goes on.
+func (e *entry) unschedule() {
+	if e.stop != nil {
+		close(e.stop)
+		e.stop = nil
+	}
+}
+
+func (s *scheduler) loop(e *entry, stop chan struct{}) {
+	for {
+		next := e.schedule.Next(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.fire(e)
+	}
+}
+
+// fire starts a run of e, or applies its overlap policy if the previous run is still going.
+func (s *scheduler) fire(e *entry) {
+	e.lock.Lock()
+	if e.running {
+		defer e.lock.Unlock()
+		switch e.job.Overlap {
+		case models.OverlapQueue:
+			if e.queued < maxQueued {
+				e.queued++
+			} else {
+				log.Warnf("job %s has %d runs queued, skip this one.", e.job.Name, e.queued)
+			}
+		case models.OverlapReplace:
+			log.Infof("job %s is still running, replace it.", e.job.Name)
+			e.queued = 1
+			go s.processesService.Stop(processName(e.job.Name), "scheduler (replaced)")
+		default:
+			log.Infof("job %s is still running, skip this run.", e.job.Name)
+		}
+		return
+	}
+	e.running = true
+	e.lock.Unlock()
+	go s.run(e)
+}
+
+// run runs e, then the runs queued meanwhile.
+func (s *scheduler) run(e *entry) {
+	for {
+		s.runOnce(e.job)
+		e.lock.Lock()
+		if e.queued == 0 {
+			e.running = false
+			e.lock.Unlock()
+			return
+		}
+		e.queued--
+		e.lock.Unlock()
+	}
+}
+
+// runOnce launches a run of job and waits for it, stopping it after the max runtime. The run
+// is recorded in the process run history.
+func (s *scheduler) runOnce(job models.Job) {
+	_, err := s.processesService.Prepare(jobParam(job))
+	if err != nil {
+		log.Errorf("job %s prepare error: %v", job.Name, err)
+		return
+	}
+	err = s.processesService.Start(processName(job.Name))
+	if err != nil {
+		log.Errorf("job %s start error: %v", job.Name, err)
+		return
+	}
+	done := s.processesService.Wait(processName(job.Name))
+	var timeout <-chan time.Time
+	if job.MaxRuntime > 0 {
+		timeout = time.After(time.Duration(job.MaxRuntime) * time.Second)
+	}
+	select {
+	case <-done:
+	case <-timeout:
+		log.Warnf("job %s ran longer than %ds, stopping it.", job.Name, job.MaxRuntime)
+		_, err = s.processesService.Stop(processName(job.Name), "scheduler (max runtime)")
+		if err != nil && err != processes.ProcessNotRun {
+			log.Errorf("job %s stop error: %v", job.Name, err)
+		}
+		<-done
+	}
+}
+
+func (s *scheduler) List() ([]models.Job, error) {
+	err := initEntries(s)
+	if err != nil {
+		return nil, err
+	}
+	s.Lock()
+	defer s.Unlock()
+	jobs := make([]models.Job, 0, len(s.entries))
+	for _, e := range s.entries {
+		jobs = append(jobs, e.job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs, nil
+}
+
+func (s *scheduler) Add(j models.Job) (int64, error) {
+	err := initEntries(s)
+	if err != nil {
+		return 0, err
+	}
+	switch j.Overlap {
+	case "":
+		j.Overlap = models.OverlapSkip
+	case models.OverlapSkip, models.OverlapQueue, models.OverlapReplace:
+	default:
+		return 0, fmt.Errorf("unknown overlap policy %q", j.Overlap)
+	}
+	schedule, err := parseSchedule(j)
+	if err != nil {
+		return 0, err
+	}
+	s.Lock()
+	defer s.Unlock()
+	for _, e := range s.entries {
+		if e.job.Name == j.Name {
+			return 0, ErrJobExist
+		}
+	}
+	j.Enable = true
+	err = s.repo.InsertJob(&j)
+	if err != nil {
+		return 0, err
+	}
+	e := &entry{job: j, schedule: schedule}
+	s.entries[j.ID] = e
+	s.schedule(e)
+	return j.ID, nil
+}
+
+func (s *scheduler) Remove(id int64) error {
+	err := initEntries(s)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	e := s.entries[id]
+	if e == nil {
+		return ErrJobNotExist
+	}
+	e.unschedule()
+	delete(s.entries, id)
+	_, err = s.repo.DeleteJobByID(id)
+	return err
+}
+
+func (s *scheduler) setEnable(id int64, enable bool) error {
+	err := initEntries(s)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	e := s.entries[id]
+	if e == nil {
+		return ErrJobNotExist
+	}
+	if e.job.Enable == enable {
+		return nil
+	}
+	_, err = s.repo.UpdateJobByID(id, map[string]interface{}{
+		"Enable": enable,
+	})
+	if err != nil {
+		return err
+	}
+	e.job.Enable = enable
+	if enable {
+		s.schedule(e)
+	} else {
+		e.unschedule()
+	}
+	return nil
+}
+
+func (s *scheduler) Enable(id int64) error {
+	return s.setEnable(id, true)
+}
+
+func (s *scheduler) Disable(id int64) error {
+	return s.setEnable(id, false)
+}
+
+func (s *scheduler) Trigger(id int64) error {
+	err := initEntries(s)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	e := s.entries[id]
+	s.Unlock()
+	if e == nil {
+		return ErrJobNotExist
+	}
+	s.fire(e)
+	return nil
+}
+
+func (s *scheduler) ListRuns(id int64, page uint32, size uint32, sort string, order string) (
+	int64, []models.ProcessRun, error) {
+	err := initEntries(s)
+	if err != nil {
+		return 0, nil, err
+	}
+	s.Lock()
+	e := s.entries[id]
+	s.Unlock()
+	if e == nil {
+		return 0, nil, ErrJobNotExist
+	}
+	return s.processesService.ListRuns(processName(e.job.Name), page, size, sort, order)
+}
@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhsyourai/URCF-engine/models"
+	"github.com/zhsyourai/URCF-engine/services/processes"
+	"github.com/zhsyourai/URCF-engine/services/processes/types"
+)
+
+// fakeProcesses runs processes that only exit when stopped.
+type fakeProcesses struct {
+	processes.Service
+	lock    sync.Mutex
+	params  []models.ProcessParam
+	stopBy  []string
+	running chan struct{}
+}
+
+func (f *fakeProcesses) Prepare(param models.ProcessParam) (*types.Process, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.params = append(f.params, param)
+	f.running = make(chan struct{})
+	return &types.Process{}, nil
+}
+
+func (f *fakeProcesses) Start(name string) error {
+	return nil
+}
+
+func (f *fakeProcesses) Wait(name string) <-chan error {
+	f.lock.Lock()
+	running := f.running
+	f.lock.Unlock()
+	done := make(chan error)
+	go func() {
+		<-running
+		close(done)
+	}()
+	return done
+}
+
+func (f *fakeProcesses) Stop(name string, by string) (types.StopResult, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.stopBy = append(f.stopBy, by)
+	if len(f.stopBy) == 1 {
+		close(f.running)
+	}
+	return 0, nil
+}
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		job   models.Job
+		valid bool
+	}{
+		{models.Job{Schedule: "*/5 * * * *"}, true},
+		{models.Job{Schedule: "@hourly"}, true},
+		{models.Job{Schedule: "@every 90s"}, true},
+		{models.Job{Interval: 30}, true},
+		{models.Job{Schedule: "* * *"}, false},
+		{models.Job{Schedule: "@hourly", Interval: 30}, false},
+		{models.Job{}, false},
+	}
+	for _, test := range tests {
+		_, err := parseSchedule(test.job)
+		if (err == nil) != test.valid {
+			t.Fatalf("parseSchedule(%q, %d) got error %v", test.job.Schedule, test.job.Interval, err)
+		}
+	}
+	schedule, _ := parseSchedule(models.Job{Interval: 30})
+	now := time.Now()
+	if next := schedule.Next(now); next.Sub(now) > 30*time.Second {
+		t.Fatalf("interval schedule next run in %v", next.Sub(now))
+	}
+}
+
+func TestJobParam(t *testing.T) {
+	param := jobParam(models.Job{ProcessParam: models.ProcessParam{
+		Name:          "backup",
+		Option:        models.AutoRestart,
+		RestartPolicy: models.RestartPolicy{Mode: models.RestartAlways},
+	}})
+	if param.Name != "job/backup" {
+		t.Fatalf("jobParam name got %s", param.Name)
+	}
+	if param.Option != models.HookLog {
+		t.Fatalf("jobParam option got %s", param.Option)
+	}
+	if param.RestartPolicy.Mode != models.RestartNever {
+		t.Fatalf("jobParam restart mode got %s", param.RestartPolicy.Mode)
+	}
+}
+
+func TestMaxRuntime(t *testing.T) {
+	fake := &fakeProcesses{}
+	s := &scheduler{processesService: fake}
+	start := time.Now()
+	s.runOnce(models.Job{MaxRuntime: 1, ProcessParam: models.ProcessParam{Name: "stuck"}})
+	if time.Since(start) < time.Second {
+		t.Fatalf("runOnce returned before the max runtime")
+	}
+	if len(fake.stopBy) != 1 || fake.stopBy[0] != "scheduler (max runtime)" {
+		t.Fatalf("runOnce stops got %v", fake.stopBy)
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	tests := []struct {
+		overlap string
+		queued  int
+		stopped bool
+	}{
+		{models.OverlapSkip, 0, false},
+		{models.OverlapQueue, 2, false},
+		{models.OverlapReplace, 1, true},
+	}
+	for _, test := range tests {
+		fake := &fakeProcesses{running: make(chan struct{})}
+		s := &scheduler{processesService: fake}
+		e := &entry{job: models.Job{Overlap: test.overlap}, running: true}
+		s.fire(e)
+		s.fire(e)
+		if e.queued != test.queued {
+			t.Fatalf("%s overlap queued %d runs, want %d", test.overlap, e.queued, test.queued)
+		}
+		time.Sleep(50 * time.Millisecond)
+		fake.lock.Lock()
+		stopped := len(fake.stopBy) > 0
+		fake.lock.Unlock()
+		if stopped != test.stopped {
+			t.Fatalf("%s overlap stopped the run: %v", test.overlap, stopped)
+		}
+	}
+}
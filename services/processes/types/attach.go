@@ -0,0 +1,41 @@
+package types
+
+import (
+	"fmt"
+	"github.com/zhsyourai/URCF-engine/utils"
+	"strings"
+)
+
+type AttachMode int
+
+const (
+	// LineAttach terminates every write with a newline if it doesn't end with one already.
+	LineAttach AttachMode = iota
+	// RawAttach writes bytes to stdin as they are.
+	RawAttach
+)
+
+var attachModeStrings = []utils.IntName{
+	{0, "line"},
+	{1, "raw"},
+}
+
+func (i AttachMode) String() string {
+	return utils.StringName(uint32(i), attachModeStrings, "attachMode.", false)
+}
+func (i AttachMode) GoString() string {
+	return utils.StringName(uint32(i), attachModeStrings, "attachMode.", true)
+}
+func (i AttachMode) MarshalText() ([]byte, error) {
+	return []byte(utils.StringName(uint32(i), attachModeStrings, "attachMode.", false)), nil
+}
+
+func ParseAttachMode(mode string) (AttachMode, error) {
+	switch strings.ToLower(mode) {
+	case "", "line":
+		return LineAttach, nil
+	case "raw":
+		return RawAttach, nil
+	}
+	return LineAttach, fmt.Errorf("not a valid AttachMode: %q", mode)
+}
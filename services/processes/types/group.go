@@ -0,0 +1,44 @@
+package types
+
+// ProcessGroup is the aggregated status of the processes of a group.
+type ProcessGroup struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+	// Statuses counts the members in each status.
+	Statuses map[string]int `json:"statuses"`
+	Ready    int            `json:"ready"`
+	Restarts int            `json:"restart_count"`
+	// Usage sums the current resource usage of the members.
+	Usage ResourceSample `json:"usage"`
+}
+
+func NewProcessGroup(name string) *ProcessGroup {
+	return &ProcessGroup{
+		Name:     name,
+		Members:  []string{},
+		Statuses: make(map[string]int),
+	}
+}
+
+// Add aggregates proc into the group.
+func (g *ProcessGroup) Add(proc *Process) {
+	g.Members = append(g.Members, proc.Name)
+	g.Statuses[proc.Status.String()]++
+	if proc.Ready {
+		g.Ready++
+	}
+	g.Restarts += proc.Statistics.Restarts
+	if proc.Status != Running {
+		return
+	}
+	usage := proc.Statistics.Usage
+	if usage.Time.After(g.Usage.Time) {
+		g.Usage.Time = usage.Time
+	}
+	g.Usage.CPUPercent += usage.CPUPercent
+	g.Usage.RSS += usage.RSS
+	g.Usage.OpenFDs += usage.OpenFDs
+	g.Usage.Threads += usage.Threads
+	g.Usage.ReadBytes += usage.ReadBytes
+	g.Usage.WriteBytes += usage.WriteBytes
+}
@@ -0,0 +1,36 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/zhsyourai/URCF-engine/models"
+)
+
+func TestProcessGroupAdd(t *testing.T) {
+	group := NewProcessGroup("web")
+	running := &Process{
+		ProcessParam: models.ProcessParam{Name: "a"},
+		Status:       Running,
+		Ready:        true,
+	}
+	running.Statistics.Restarts = 2
+	running.Statistics.Usage = ResourceSample{CPUPercent: 10, RSS: 100, Threads: 3}
+	exited := &Process{
+		ProcessParam: models.ProcessParam{Name: "b"},
+		Status:       Exited,
+	}
+	exited.Statistics.Restarts = 1
+	exited.Statistics.Usage = ResourceSample{CPUPercent: 50, RSS: 1000}
+	group.Add(running)
+	group.Add(exited)
+
+	if len(group.Members) != 2 || group.Ready != 1 || group.Restarts != 3 {
+		t.Fatalf("group got %+v", group)
+	}
+	if group.Statuses["Running"] != 1 || group.Statuses["Exited"] != 1 {
+		t.Fatalf("group statuses got %v", group.Statuses)
+	}
+	if group.Usage.CPUPercent != 10 || group.Usage.RSS != 100 || group.Usage.Threads != 3 {
+		t.Fatalf("group usage should only sum running members, got %+v", group.Usage)
+	}
+}
@@ -0,0 +1,132 @@
+package types
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	StdOutStream = "stdout"
+	StdErrStream = "stderr"
+)
+
+const tailChanSize = 256
+
+// OutputLine is a single line a process wrote to its stdout or stderr.
+type OutputLine struct {
+	Stream string    `json:"stream"`
+	Text   string    `json:"text"`
+	Time   time.Time `json:"time"`
+}
+
+// Output fans out the lines written by a process to every tail and keeps the last
+// lines in a bounded ring buffer, so a new tail can start with some history.
+type Output struct {
+	lock  sync.Mutex
+	ring  []OutputLine
+	start int
+	count int
+	tails map[*OutputTail]struct{}
+	// closed is set once the output is dropped, see Close.
+	closed bool
+}
+
+func NewOutput(capacity int) *Output {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &Output{
+		ring:  make([]OutputLine, capacity),
+		tails: make(map[*OutputTail]struct{}),
+	}
+}
+
+// Publish appends line to the ring buffer and sends it to every tail. A tail that
+// doesn't keep up misses the line instead of blocking the process.
+func (o *Output) Publish(line OutputLine) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.count < len(o.ring) {
+		o.ring[(o.start+o.count)%len(o.ring)] = line
+		o.count++
+	} else {
+		o.ring[o.start] = line
+		o.start = (o.start + 1) % len(o.ring)
+	}
+	for t := range o.tails {
+		select {
+		case t.lines <- line:
+		default:
+		}
+	}
+}
+
+// Last returns up to n of the most recent lines, oldest first.
+func (o *Output) Last(n int) []OutputLine {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.last(n)
+}
+
+func (o *Output) last(n int) []OutputLine {
+	if n > o.count || n < 0 {
+		n = o.count
+	}
+	ret := make([]OutputLine, 0, n)
+	for i := o.count - n; i < o.count; i++ {
+		ret = append(ret, o.ring[(o.start+i)%len(o.ring)])
+	}
+	return ret
+}
+
+// Tail subscribes to new lines. History holds up to last lines published before the
+// subscription, and Close must be called once the tail isn't needed anymore.
+func (o *Output) Tail(last int) *OutputTail {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	t := &OutputTail{
+		History: o.last(last),
+		lines:   make(chan OutputLine, tailChanSize),
+		output:  o,
+	}
+	if o.closed {
+		t.once.Do(func() {
+			close(t.lines)
+		})
+		return t
+	}
+	o.tails[t] = struct{}{}
+	return t
+}
+
+// Close ends every tail, and the tails opened afterwards, once the output is dropped.
+func (o *Output) Close() {
+	o.lock.Lock()
+	tails := o.tails
+	o.tails = make(map[*OutputTail]struct{})
+	o.closed = true
+	o.lock.Unlock()
+	for t := range tails {
+		t.Close()
+	}
+}
+
+type OutputTail struct {
+	History []OutputLine
+	lines   chan OutputLine
+	output  *Output
+	once    sync.Once
+}
+
+func (t *OutputTail) Lines() <-chan OutputLine {
+	return t.lines
+}
+
+func (t *OutputTail) Close() {
+	t.once.Do(func() {
+		t.output.lock.Lock()
+		defer t.output.lock.Unlock()
+		delete(t.output.tails, t)
+		close(t.lines)
+	})
+}
@@ -0,0 +1,59 @@
+package types
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestOutputRing(t *testing.T) {
+	output := NewOutput(3)
+	for i := 0; i < 5; i++ {
+		output.Publish(OutputLine{Stream: StdOutStream, Text: fmt.Sprint(i)})
+	}
+	lines := output.Last(10)
+	if len(lines) != 3 {
+		t.Fatalf("Last error (len %d not equal 3)", len(lines))
+	}
+	for i, line := range lines {
+		if line.Text != fmt.Sprint(i+2) {
+			t.Fatalf("Last error (line %d is %s)", i, line.Text)
+		}
+	}
+	lines = output.Last(1)
+	if len(lines) != 1 || lines[0].Text != "4" {
+		t.Fatalf("Last error (%v)", lines)
+	}
+}
+
+func TestOutputTail(t *testing.T) {
+	output := NewOutput(10)
+	output.Publish(OutputLine{Stream: StdOutStream, Text: "history"})
+	tail := output.Tail(5)
+	if len(tail.History) != 1 || tail.History[0].Text != "history" {
+		t.Fatalf("Tail history error (%v)", tail.History)
+	}
+	output.Publish(OutputLine{Stream: StdErrStream, Text: "live"})
+	line := <-tail.Lines()
+	if line.Text != "live" || line.Stream != StdErrStream {
+		t.Fatalf("Tail error (%v)", line)
+	}
+	tail.Close()
+	if _, ok := <-tail.Lines(); ok {
+		t.Fatalf("%s", "Tail not closed")
+	}
+	output.Publish(OutputLine{Stream: StdOutStream, Text: "after close"})
+}
+
+func TestOutputClose(t *testing.T) {
+	output := NewOutput(10)
+	tail := output.Tail(5)
+	output.Close()
+	if _, ok := <-tail.Lines(); ok {
+		t.Fatalf("%s", "Tail not closed by Close")
+	}
+	tail.Close()
+	if _, ok := <-output.Tail(5).Lines(); ok {
+		t.Fatalf("%s", "Tail after Close not closed")
+	}
+	output.Publish(OutputLine{Stream: StdOutStream, Text: "after close"})
+}
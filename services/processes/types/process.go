@@ -15,6 +15,7 @@ const (
 	Running
 	Exiting
 	Exited
+	CrashLoop
 )
 
 var processStrings = []utils.IntName{
@@ -22,6 +23,7 @@ var processStrings = []utils.IntName{
 	{1, "Running"},
 	{2, "Exiting"},
 	{3, "Exited"},
+	{4, "CrashLoop"},
 }
 
 func (i ProcessStatus) String() string {
@@ -34,15 +36,41 @@ func (i ProcessStatus) MarshalText() ([]byte, error) {
 	return []byte(utils.StringName(uint32(i), processStrings, "processStatus.", false)), nil
 }
 
+type StopResult int
+
+const (
+	StopNone StopResult = iota
+	StopGraceful
+	StopKilled
+)
+
+var stopResultStrings = []utils.IntName{
+	{0, "None"},
+	{1, "Graceful"},
+	{2, "Killed"},
+}
+
+func (i StopResult) String() string {
+	return utils.StringName(uint32(i), stopResultStrings, "stopResult.", false)
+}
+func (i StopResult) GoString() string {
+	return utils.StringName(uint32(i), stopResultStrings, "stopResult.", true)
+}
+func (i StopResult) MarshalText() ([]byte, error) {
+	return []byte(utils.StringName(uint32(i), stopResultStrings, "stopResult.", false)), nil
+}
+
 type Process struct {
 	models.ProcessParam
 	Pid        int               `json:"pid"`
 	PidFile    string            `json:"pid_file"`
+	Cgroup     string            `json:"cgroup,omitempty"`
 	StdIn      io.WriteCloser    `json:"-"`
 	StdOut     io.ReadCloser     `json:"-"`
 	StdErr     io.ReadCloser     `json:"-"`
 	DataOut    io.ReadCloser     `json:"-"`
 	Statistics ProcessStatistics `json:"statistics"`
 	Status     ProcessStatus     `json:"status,string"`
+	Ready      bool              `json:"ready"`
 	Process    *os.Process       `json:"-"`
 }
@@ -4,11 +4,28 @@ import (
 	"time"
 )
 
+// ClockTicks is the USER_HZ the kernel reports CPU times in /proc with.
+const ClockTicks = 100
+
+// ResourceSample is the resource usage of a process at some point in time.
+type ResourceSample struct {
+	Time       time.Time `json:"time"`
+	CPUPercent float64   `json:"cpu_percent"`
+	RSS        int64     `json:"rss"`
+	OpenFDs    int       `json:"open_fds"`
+	Threads    int       `json:"threads"`
+	ReadBytes  int64     `json:"read_bytes"`
+	WriteBytes int64     `json:"write_bytes"`
+}
+
 // ProcessStatistics is a wrapper with the process current Statistics info.
 type ProcessStatistics struct {
-	Restarts  int           `json:"restart_count"`
-	StartTime time.Time     `json:"start_time"`
-	UpTime    time.Duration `json:"uptime"`
+	Restarts  int              `json:"restart_count"`
+	StartTime time.Time        `json:"start_time"`
+	UpTime    time.Duration    `json:"uptime"`
+	Usage     ResourceSample   `json:"usage"`
+	History   []ResourceSample `json:"history"`
+	cpuTicks  uint64
 }
 
 func (p *ProcessStatistics) AddRestart() {
@@ -26,3 +43,23 @@ func (p *ProcessStatistics) SetUpTime() {
 func (p *ProcessStatistics) ResetUpTime() {
 	p.UpTime = time.Duration(0)
 }
+
+// AddSample records sample as the current usage and appends it to a history of at most
+// historySize samples. cpuTicks is the CPU time the process used so far, in ClockTicks,
+// and CPUPercent is computed from its difference with the previous sample.
+func (p *ProcessStatistics) AddSample(sample ResourceSample, cpuTicks uint64, historySize int) {
+	if !p.Usage.Time.IsZero() && cpuTicks >= p.cpuTicks {
+		elapsed := sample.Time.Sub(p.Usage.Time).Seconds()
+		if elapsed > 0 {
+			sample.CPUPercent = float64(cpuTicks-p.cpuTicks) / ClockTicks / elapsed * 100
+		}
+	}
+	p.cpuTicks = cpuTicks
+	p.Usage = sample
+
+	history := p.History
+	if historySize > 0 && len(history) >= historySize {
+		history = history[len(history)-historySize+1:]
+	}
+	p.History = append(append(make([]ResourceSample, 0, len(history)+1), history...), sample)
+}
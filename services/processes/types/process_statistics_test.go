@@ -0,0 +1,50 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddSampleCPUPercent(t *testing.T) {
+	var stats ProcessStatistics
+	start := time.Now()
+	stats.AddSample(ResourceSample{Time: start}, 1000, 10)
+	if stats.Usage.CPUPercent != 0 {
+		t.Fatalf("AddSample error (%v not equal %v)", stats.Usage.CPUPercent, 0)
+	}
+	// 50 ticks in 2 seconds is a quarter of a CPU.
+	stats.AddSample(ResourceSample{Time: start.Add(2 * time.Second)}, 1050, 10)
+	if stats.Usage.CPUPercent != 25 {
+		t.Fatalf("AddSample error (%v not equal %v)", stats.Usage.CPUPercent, 25)
+	}
+	// A counter going backwards, such as a new pid, doesn't give a negative usage.
+	stats.AddSample(ResourceSample{Time: start.Add(4 * time.Second)}, 10, 10)
+	if stats.Usage.CPUPercent != 0 {
+		t.Fatalf("AddSample error (%v not equal %v)", stats.Usage.CPUPercent, 0)
+	}
+}
+
+func TestAddSampleHistory(t *testing.T) {
+	var stats ProcessStatistics
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		stats.AddSample(ResourceSample{Time: start.Add(time.Duration(i) * time.Second), RSS: int64(i)}, 0, 3)
+	}
+	if len(stats.History) != 3 {
+		t.Fatalf("AddSample error (%v not equal %v)", len(stats.History), 3)
+	}
+	for i, sample := range stats.History {
+		if sample.RSS != int64(i+2) {
+			t.Fatalf("AddSample error (%v not equal %v)", sample.RSS, i+2)
+		}
+	}
+	if stats.Usage.RSS != 4 {
+		t.Fatalf("AddSample error (%v not equal %v)", stats.Usage.RSS, 4)
+	}
+
+	history := stats.History
+	stats.AddSample(ResourceSample{Time: start.Add(5 * time.Second), RSS: 5}, 0, 3)
+	if history[0].RSS != 2 {
+		t.Fatalf("%s", "AddSample error (history published before a sample changed)")
+	}
+}
@@ -11,24 +11,30 @@ import (
 	"github.com/zhsyourai/URCF-engine/services/processes/types"
 )
 
+// Death is sent on the deaths channel when a watched process exits on its own.
+type Death struct {
+	Proc  *types.Process
+	State *os.ProcessState
+}
+
 type Service interface {
 	services.ServiceLifeCycle
-	StartWatch(proc *types.Process) error
+	StartWatch(proc *types.Process, exitNotify <-chan *os.ProcessState) error
 	StopWatch(proc *types.Process) error
-	GetDeathsChan() chan *types.Process
+	GetDeathsChan() chan Death
 }
 
 type dog struct {
 	Stopping   atomic.Value
 	StopNotify chan struct{}
-	ExitNotify chan *os.ProcessState
+	ExitNotify <-chan *os.ProcessState
 	Proc       *types.Process
 }
 
 type watchDog struct {
 	services.InitHelper
 	sync.Mutex
-	deathProcesses chan *types.Process
+	deathProcesses chan Death
 	watchProcesses map[string]*dog
 }
 
@@ -50,51 +56,57 @@ var once sync.Once
 func GetInstance() Service {
 	once.Do(func() {
 		instance = &watchDog{
-			deathProcesses: make(chan *types.Process),
+			deathProcesses: make(chan Death),
 			watchProcesses: make(map[string]*dog),
 		}
 	})
 	return instance
 }
 
-func waitTargetProcess(proc *types.Process, dog *dog) {
-	log.Infof("Starting watcher on process %s", proc.Name)
-	state, _ := proc.Process.Wait()
-	if dog.Stopping.Load().(bool) {
-		return
-	}
-	dog.Stopping.Store(true)
-	dog.ExitNotify <- state
-}
-
 func (watcher *watchDog) watch(proc *types.Process, dog *dog) {
-	defer delete(watcher.watchProcesses, proc.Name)
+	defer func() {
+		watcher.Lock()
+		defer watcher.Unlock()
+		if watcher.watchProcesses[proc.Name] == dog {
+			delete(watcher.watchProcesses, proc.Name)
+		}
+	}()
+	log.Infof("Starting watcher on process %s", proc.Name)
 	select {
 	case procStatus := <-dog.ExitNotify:
+		if dog.Stopping.Load().(bool) {
+			break
+		}
+		dog.Stopping.Store(true)
 		log.Infof("Proc %s is dead, advising master...", proc.Name)
-		log.Infof("State is %s", procStatus.String())
-		watcher.deathProcesses <- proc
+		if procStatus != nil {
+			log.Infof("State is %s", procStatus.String())
+		}
+		watcher.deathProcesses <- Death{
+			Proc:  proc,
+			State: procStatus,
+		}
 		break
 	case <-dog.StopNotify:
 		break
 	}
 }
 
-func (watcher *watchDog) StartWatch(proc *types.Process) (err error) {
+func (watcher *watchDog) StartWatch(proc *types.Process, exitNotify <-chan *os.ProcessState) (err error) {
 	watcher.Lock()
 	defer watcher.Unlock()
-	if _, ok := watcher.watchProcesses[proc.Name]; ok {
+	// A dog that is stopping is replaced, it only has to report a death left.
+	if dog, ok := watcher.watchProcesses[proc.Name]; ok && !dog.Stopping.Load().(bool) {
 		log.Warnf("A watcher for this process already exists.")
 		return
 	}
 	dog := &dog{
 		Proc:       proc,
-		ExitNotify: make(chan *os.ProcessState, 1),
+		ExitNotify: exitNotify,
 		StopNotify: make(chan struct{}, 1),
 	}
 	dog.Stopping.Store(false)
 	watcher.watchProcesses[proc.Name] = dog
-	go waitTargetProcess(proc, dog)
 	go watcher.watch(proc, dog)
 	return
 }
@@ -104,6 +116,9 @@ func (watcher *watchDog) StopWatch(proc *types.Process) error {
 	defer watcher.Unlock()
 	if dog, ok := watcher.watchProcesses[proc.Name]; ok {
 		log.Infof("Exiting watcher on proc %s", proc.Name)
+		// The entry goes now rather than when the dog exits, so that a new watch of the same
+		// process can start right away.
+		delete(watcher.watchProcesses, proc.Name)
 		if dog.Stopping.Load().(bool) {
 			return errors.New("watch is stopping")
 		}
@@ -114,6 +129,6 @@ func (watcher *watchDog) StopWatch(proc *types.Process) error {
 	return errors.New("process not watching")
 }
 
-func (watcher *watchDog) GetDeathsChan() chan *types.Process {
+func (watcher *watchDog) GetDeathsChan() chan Death {
 	return watcher.deathProcesses
 }